@@ -2,10 +2,30 @@
 /// <reference path="../libs/js/action.js" />
 /// <reference path="../libs/js/utils.js" />
 
+// Stream Deck replaces the whole settings object on every save, so keep the
+// last known settings and merge each change into them.
+let currentSettings = {};
+let globalSettings = {};
+
+const saveSettings = (changes) => {
+    currentSettings = { ...currentSettings, ...changes };
+    $PI.setSettings(currentSettings);
+};
+
+const fadingActions = [
+    'ca.michaelabon.logitech-litra-lights.set',
+    'ca.michaelabon.logitech-litra-lights.front.temperature',
+    'ca.michaelabon.logitech-litra-lights.front.brightness',
+    'ca.michaelabon.logitech-litra-lights.back.brightness',
+    'ca.michaelabon.logitech-litra-lights.back.color',
+    'ca.michaelabon.logitech-litra-lights.back.presets',
+];
+
 $PI.onConnected((jsn) => {
     const { actionInfo, appInfo, connection, messageType, port, uuid } = jsn;
     const { payload, context } = actionInfo;
     const { settings } = payload;
+    currentSettings = settings || {};
 
     if (actionInfo && actionInfo.action) {
         const section = document.getElementById(actionInfo.action)
@@ -18,16 +38,59 @@ $PI.onConnected((jsn) => {
                     'input',
                     Utils.debounce(150, () => {
                         const value = Utils.getFormValue(form);
-                        $PI.setSettings(value);
+                        saveSettings(value);
                     })
                 );
             }
+            // Every action except "Turn Off All Lights" can pick its light
+            if (actionInfo.action !== 'ca.michaelabon.logitech-litra-lights.off') {
+                document.getElementById('light-target').style.display = "block";
+                $PI.onSendToPropertyInspector(actionInfo.action, ({ payload }) => {
+                    if (payload.event === 'lights' && typeof updateLightPickerUI === 'function') {
+                        attachedLights = payload.lights || [];
+                        updateLightPickerUI();
+                    }
+                    if (payload.event === 'startup' && typeof updateStartupUI === 'function') {
+                        updateStartupUI(payload.startup || {});
+                    }
+                    if (payload.event === 'capturedScene' && typeof addCapturedScene === 'function') {
+                        addCapturedScene(payload.scene);
+                    }
+                });
+                updateLightPickerUI();
+                $PI.sendToPlugin({ event: 'getLights' });
+                $PI.sendToPlugin({ event: 'getStartup' });
+                $PI.getGlobalSettings();
+            }
+            // Actions that change brightness, temperature or colour can fade
+            if (fadingActions.includes(actionInfo.action)) {
+                const transition = document.getElementById('transition');
+                transition.style.display = "block";
+                const form = transition.querySelector('form');
+                Utils.setFormValue(settings, form);
+                form.addEventListener(
+                    'input',
+                    Utils.debounce(150, () => saveSettings(Utils.getFormValue(form)))
+                );
+            }
             // Back Gradient Cycle: load gradient presets
             if (actionInfo.action === 'ca.michaelabon.logitech-litra-lights.back.presets') {
                 if (settings.presets && typeof updatePresetsUI === 'function') {
                     updatePresetsUI(settings.presets);
                 }
             }
+            // Back Light Effect: load the palette
+            if (actionInfo.action === 'ca.michaelabon.logitech-litra-lights.back.effect') {
+                if (typeof updateEffectPaletteUI === 'function') {
+                    updateEffectPaletteUI([...(settings.palette || [])]);
+                }
+            }
+            // Apply Scene: load the scene
+            if (actionInfo.action === 'ca.michaelabon.logitech-litra-lights.scene') {
+                if (typeof updateSceneUI === 'function') {
+                    updateSceneUI();
+                }
+            }
             // Back Color Cycle: load color presets
             if (actionInfo.action === 'ca.michaelabon.logitech-litra-lights.back.color') {
                 if (typeof updateColorPresetsUI === 'function') {
@@ -44,6 +107,17 @@ $PI.onConnected((jsn) => {
 
 $PI.onDidReceiveGlobalSettings(({ payload }) => {
     console.log('onDidReceiveGlobalSettings', payload);
+    globalSettings = payload.settings || {};
+    if (typeof updateLightPickerUI === 'function') {
+        lightGroups = globalSettings.groups || {};
+        $PI.sendToPlugin({ event: 'setGroups', groups: lightGroups });
+        updateLightPickerUI();
+    }
+    if (typeof updateLibraryUI === 'function') {
+        library = globalSettings.library || { presets: {}, scenes: {} };
+        $PI.sendToPlugin({ event: 'setLibrary', library });
+        updateLibraryUI();
+    }
 })
 
 /**
@@ -0,0 +1,749 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/michaelabon/streamdeck-logitech-litra/internal/transport"
+	"github.com/samwho/streamdeck"
+)
+
+const testTimeout = 2 * time.Second
+
+// testDeck plays the Stream Deck application's side of the plugin websocket,
+// so tests drive the registered actions exactly like the real software does.
+type testDeck struct {
+	t      *testing.T
+	fake   *transport.Fake
+	conn   *websocket.Conn
+	events chan streamdeck.Event
+}
+
+func newTestDeck(t *testing.T, devices ...transport.DeviceInfo) *testDeck {
+	t.Helper()
+
+	dm, fake := newTestManager(devices...)
+	prev := deviceMgr
+	deviceMgr = dm
+	t.Cleanup(func() { deviceMgr = prev })
+
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- c
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := streamdeck.NewClient(context.Background(), streamdeck.RegistrationParams{
+		Port:          port,
+		PluginUUID:    "test-plugin",
+		RegisterEvent: "registerPlugin",
+		Info:          "{}",
+	})
+	setup(client, openStateFile(""), "test-plugin")
+	go client.Run()
+
+	d := &testDeck{t: t, fake: fake, events: make(chan streamdeck.Event, 64)}
+	select {
+	case d.conn = <-conns:
+	case <-time.After(testTimeout):
+		t.Fatal("plugin never connected")
+	}
+	t.Cleanup(func() { d.conn.Close() })
+
+	go func() {
+		defer close(d.events)
+		for {
+			var e streamdeck.Event
+			if err := d.conn.ReadJSON(&e); err != nil {
+				return
+			}
+			d.events <- e
+		}
+	}()
+
+	if reg := d.next(); reg.Event != "registerPlugin" {
+		t.Fatalf("Expected registration, got %q", reg.Event)
+	}
+	return d
+}
+
+// send delivers an event for one key to the plugin.
+func (d *testDeck) send(action, event, ctx string, settings any) {
+	d.t.Helper()
+	if settings == nil {
+		settings = map[string]any{}
+	}
+	s, err := json.Marshal(settings)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	d.sendPayload(action, event, ctx, streamdeck.KeyDownPayload{Settings: s})
+}
+
+// sendPayload delivers an event with an arbitrary payload, e.g. from the Property Inspector.
+func (d *testDeck) sendPayload(action, event, ctx string, payload any) {
+	d.t.Helper()
+	p, err := json.Marshal(payload)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	msg := streamdeck.Event{Action: action, Event: event, Context: ctx, Payload: p}
+	if err := d.conn.WriteJSON(msg); err != nil {
+		d.t.Fatal(err)
+	}
+}
+
+// next returns the next message the plugin sent.
+func (d *testDeck) next() streamdeck.Event {
+	d.t.Helper()
+	select {
+	case e, ok := <-d.events:
+		if !ok {
+			d.t.Fatal("plugin connection closed")
+		}
+		return e
+	case <-time.After(testTimeout):
+		d.t.Fatal("timed out waiting for the plugin")
+	}
+	return streamdeck.Event{}
+}
+
+// expect skips messages until one named event arrives for ctx.
+func (d *testDeck) expect(event, ctx string) streamdeck.Event {
+	d.t.Helper()
+	for {
+		e := d.next()
+		if e.Event == event && e.Context == ctx {
+			return e
+		}
+	}
+}
+
+// expectTitle waits for the title of ctx to be set and returns it.
+func (d *testDeck) expectTitle(ctx string) string {
+	d.t.Helper()
+	var p streamdeck.SetTitlePayload
+	if err := json.Unmarshal(d.expect(streamdeck.SetTitle, ctx).Payload, &p); err != nil {
+		d.t.Fatal(err)
+	}
+	return p.Title
+}
+
+// waitTitle waits for ctx to be given the title want, skipping the titles it
+// gets on the way, such as a fade's steps.
+func (d *testDeck) waitTitle(ctx, want string) {
+	d.t.Helper()
+	for d.expectTitle(ctx) != want {
+	}
+}
+
+// waitTitles waits until every key in want is given its title, in whatever
+// order they arrive.
+func (d *testDeck) waitTitles(want map[string]string) {
+	d.t.Helper()
+	pending := maps.Clone(want)
+	for len(pending) > 0 {
+		e := d.next()
+		if e.Event != streamdeck.SetTitle {
+			continue
+		}
+		var p streamdeck.SetTitlePayload
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			d.t.Fatal(err)
+		}
+		if title, ok := pending[e.Context]; ok && p.Title == title {
+			delete(pending, e.Context)
+		}
+	}
+}
+
+// waitReports waits until at least n reports reached the test light and returns them.
+func (d *testDeck) waitReports(n int) [][]byte {
+	d.t.Helper()
+	return d.waitReportsOn(testPath, n)
+}
+
+// waitReportsOn waits until at least n reports reached the light at path and returns them.
+func (d *testDeck) waitReportsOn(path string, n int) [][]byte {
+	d.t.Helper()
+	deadline := time.Now().Add(testTimeout)
+	for {
+		written := d.fake.Written(path)
+		if len(written) >= n {
+			return written
+		}
+		if time.Now().After(deadline) {
+			d.t.Fatalf("Expected %d reports, got %d", n, len(written))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func assertReports(t *testing.T, got [][]byte, want ...[]byte) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d reports, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !bytes.Equal(got[i], want[i]) {
+			t.Errorf("Report %d: expected %s, got %s", i,
+				logitech.LitraBeamLX.Describe(want[i]), logitech.LitraBeamLX.Describe(got[i]))
+		}
+	}
+}
+
+func mustBytes(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func mustAll(b [][]byte, err error) [][]byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestFrontPowerAction(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	const action = "ca.michaelabon.logitech-litra-lights.front.power"
+
+	d.send(action, streamdeck.KeyDown, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "ON" {
+		t.Errorf("Expected ON, got %q", title)
+	}
+	d.send(action, streamdeck.KeyDown, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "OFF" {
+		t.Errorf("Expected OFF, got %q", title)
+	}
+
+	assertReports(t, d.fake.Written(testPath),
+		logitech.ConvertLightsOnTarget(logitech.FrontLight, logitech.FrontLightFID),
+		logitech.ConvertLightsOffTarget(logitech.FrontLight, logitech.FrontLightFID),
+	)
+}
+
+func TestPowerKeyFollowsLightAfterReappearing(t *testing.T) {
+	d := newTestDeck(t, testLight)
+
+	for _, ctx := range []string{"a", "b"} {
+		d.send(frontPowerUUID, streamdeck.WillAppear, ctx, nil)
+		d.waitTitle(ctx, "ON")
+	}
+	d.send(frontPowerUUID, streamdeck.KeyDown, "b", nil)
+	d.waitTitles(map[string]string{"a": "OFF", "b": "OFF"})
+
+	// Key a misses the light coming back on while it's hidden, but still
+	// follows it going off again once it's shown.
+	d.send(frontPowerUUID, streamdeck.WillDisappear, "a", nil)
+	d.send(frontPowerUUID, streamdeck.KeyDown, "b", nil)
+	d.waitTitle("b", "ON")
+	d.send(frontPowerUUID, streamdeck.WillAppear, "a", nil)
+	d.waitTitle("a", "ON")
+	d.send(frontPowerUUID, streamdeck.KeyDown, "b", nil)
+	d.waitTitles(map[string]string{"a": "OFF", "b": "OFF"})
+}
+
+func TestKeysAppearWithoutWaitingForSilentLights(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	deviceMgr.queryTimeout = 200 * time.Millisecond
+	// The light is found, but answers nothing more.
+	d.fake.Respond(func(info transport.DeviceInfo, report []byte) [][]byte {
+		if isDiscovery(report) {
+			return emulateLitras(info, report)
+		}
+		return nil
+	})
+
+	start := time.Now()
+	for _, ctx := range []string{"a", "b", "c"} {
+		d.send(frontPowerUUID, streamdeck.WillAppear, ctx, nil)
+	}
+	d.send(frontBrightnessUUID, streamdeck.WillAppear, t.Name(), CycleSettings{Values: "0"})
+	if title := d.expectTitle(t.Name()); title != "Check\nValues" {
+		t.Errorf("Expected Check Values, got %q", title)
+	}
+	if elapsed := time.Since(start); elapsed >= deviceMgr.queryTimeout {
+		t.Errorf("Expected the key to appear without waiting on the light, took %v", elapsed)
+	}
+}
+
+func TestBackPowerActionRestoresLastColor(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	colorCtx := t.Name() + "-color"
+	powerCtx := t.Name() + "-power"
+
+	d.send("ca.michaelabon.logitech-litra-lights.back.color", streamdeck.KeyDown, colorCtx,
+		map[string]any{"colorPresets": []string{"#0000FF"}})
+	d.expectTitle(colorCtx)
+	d.fake.Reset()
+
+	// The color key turned the back light on, so the power key turns it off.
+	d.send("ca.michaelabon.logitech-litra-lights.back.power", streamdeck.KeyDown, powerCtx, nil)
+	if title := d.expectTitle(powerCtx); title != "OFF" {
+		t.Errorf("Expected OFF, got %q", title)
+	}
+	assertReports(t, d.fake.Written(testPath), logitech.ConvertLightsOffTarget(logitech.BackLight, logitech.BackLightFID))
+
+	d.fake.Reset()
+	d.send("ca.michaelabon.logitech-litra-lights.back.power", streamdeck.KeyDown, powerCtx, nil)
+	d.waitTitle(powerCtx, "ON")
+	want := append(
+		[][]byte{logitech.ConvertLightsOnTarget(logitech.BackLight, logitech.BackLightFID)},
+		logitech.ConvertBackColorAllZones(0, 0, 255)...,
+	)
+	assertReports(t, d.fake.Written(testPath), want...)
+}
+
+func TestFrontTemperatureCycleAction(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	const action = "ca.michaelabon.logitech-litra-lights.front.temperature"
+
+	for _, want := range []string{"2700K", "3200K", "4000K", "5000K", "6500K", "2700K"} {
+		d.send(action, streamdeck.KeyDown, t.Name(), nil)
+		if title := d.expectTitle(t.Name()); title != want {
+			t.Errorf("Expected %s, got %q", want, title)
+		}
+	}
+
+	written := d.fake.Written(testPath)
+	assertReports(t, written[:2],
+		logitech.ConvertLightsOnTarget(logitech.FrontLight, logitech.FrontLightFID),
+		mustBytes(logitech.ConvertTemperatureTarget(logitech.FrontLight, logitech.FrontLightFID, 2700)),
+	)
+}
+
+func TestBrightnessCycleActions(t *testing.T) {
+	tests := []struct {
+		action string
+		target logitech.LightTarget
+	}{
+		{"ca.michaelabon.logitech-litra-lights.front.brightness", logitech.FrontLight},
+		{"ca.michaelabon.logitech-litra-lights.back.brightness", logitech.BackLight},
+	}
+
+	for _, test := range tests {
+		t.Run(test.action, func(t *testing.T) {
+			d := newTestDeck(t, testLight)
+
+			for _, want := range []string{"20%", "40%"} {
+				d.send(test.action, streamdeck.KeyDown, t.Name(), nil)
+				if title := d.expectTitle(t.Name()); title != want {
+					t.Errorf("Expected %s, got %q", want, title)
+				}
+			}
+			assertReports(t, d.fake.Written(testPath),
+				mustBytes(logitech.LitraBeamLX.Brightness(test.target, 20)),
+				mustBytes(logitech.LitraBeamLX.Brightness(test.target, 40)),
+			)
+		})
+	}
+}
+
+func TestBackColorCycleAction(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	const action = "ca.michaelabon.logitech-litra-lights.back.color"
+
+	// No presets configured: the defaults start with red.
+	d.send(action, streamdeck.WillAppear, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "1/6" {
+		t.Errorf("Expected 1/6, got %q", title)
+	}
+	d.send(action, streamdeck.KeyDown, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "1/6" {
+		t.Errorf("Expected 1/6, got %q", title)
+	}
+
+	want := append(
+		[][]byte{logitech.ConvertLightsOnTarget(logitech.BackLight, logitech.BackLightFID)},
+		logitech.ConvertBackColorAllZones(255, 0, 0)...,
+	)
+	assertReports(t, d.fake.Written(testPath), want...)
+}
+
+func TestBackGradientCycleAction(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	const action = "ca.michaelabon.logitech-litra-lights.back.presets"
+	settings := map[string]any{"presets": []Preset{
+		{Mode: "gradient", Color: "#ff0000", Color2: "#0000ff"},
+		{Mode: "solid", Color: "#00ff00"},
+	}}
+
+	d.send(action, streamdeck.WillAppear, t.Name(), settings)
+	if title := d.expectTitle(t.Name()); title != "Grad\n1/2" {
+		t.Errorf("Expected Grad 1/2, got %q", title)
+	}
+
+	d.send(action, streamdeck.KeyDown, t.Name(), settings)
+	d.expectTitle(t.Name())
+	want := append(
+		[][]byte{logitech.ConvertLightsOnTarget(logitech.BackLight, logitech.BackLightFID)},
+		logitech.ConvertBackColorGradient(255, 0, 0, 0, 0, 255)...,
+	)
+	assertReports(t, d.waitReports(len(want)), want...)
+}
+
+func TestBackGradientCycleZonesAndStops(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	const action = "ca.michaelabon.logitech-litra-lights.back.presets"
+	zones := []string{"#ff0000", "#ff8000", "#ffff00", "#00ff00", "#0000ff", "#4b0082", "#8f00ff"}
+	stops := []PresetStop{{0, "#0000ff"}, {0.5, "#ffffff"}, {1, "#0000ff"}}
+	settings := map[string]any{"presets": []Preset{
+		{Mode: "zones", Colors: zones},
+		{Mode: "gradient", Stops: stops},
+	}}
+
+	d.send(action, streamdeck.WillAppear, t.Name(), settings)
+	if title := d.expectTitle(t.Name()); title != "Zones\n1/2" {
+		t.Errorf("Expected Zones 1/2, got %q", title)
+	}
+
+	var frame logitech.Frame
+	for i, hex := range zones {
+		frame[i] = hexToRGBA(hex)
+	}
+	d.send(action, streamdeck.KeyDown, t.Name(), settings)
+	d.expectTitle(t.Name())
+	want := append(
+		[][]byte{logitech.ConvertLightsOnTarget(logitech.BackLight, logitech.BackLightFID)},
+		logitech.ConvertBackColorFrame(frame)...,
+	)
+	assertReports(t, d.waitReports(len(want)), want...)
+
+	d.send(action, streamdeck.KeyDown, t.Name(), map[string]any{"presets": settings["presets"], "cycleIndex": 1})
+	d.expectTitle(t.Name())
+	gradient := logitech.Gradient{Stops: []logitech.Stop{
+		{Position: 0, Color: hexToRGBA("#0000ff")},
+		{Position: 0.5, Color: hexToRGBA("#ffffff")},
+		{Position: 1, Color: hexToRGBA("#0000ff")},
+	}}
+	want = append(want,
+		append(
+			[][]byte{logitech.ConvertLightsOnTarget(logitech.BackLight, logitech.BackLightFID)},
+			logitech.ConvertBackColorFrame(gradient.Frame())...,
+		)...,
+	)
+	assertReports(t, d.waitReports(len(want)), want...)
+}
+
+func TestPresetInterpolation(t *testing.T) {
+	preset := Preset{Mode: "gradient", Color: "#ff0000", Color2: "#00ff00"}
+	if got := preset.Frame()[3]; got != hexToRGBA("#808000") {
+		t.Errorf("Expected presets without an interpolation to blend sRGB, got %v", got)
+	}
+
+	preset.Interpolation = "hsv"
+	if got := preset.Frame()[3]; got != hexToRGBA("#ffff00") {
+		t.Errorf("Expected an HSV blend through yellow, got %v", got)
+	}
+
+	preset.Stops = []PresetStop{{0, "#ff0000"}, {1, "#00ff00"}}
+	preset.Interpolation = "hsv-longer"
+	if got := preset.Frame()[3]; got != hexToRGBA("#0000ff") {
+		t.Errorf("Expected stops to blend the long way through blue, got %v", got)
+	}
+}
+
+func TestTurnOffLightsAction(t *testing.T) {
+	d := newTestDeck(t, testLight)
+
+	d.send("ca.michaelabon.logitech-litra-lights.off", streamdeck.KeyDown, t.Name(), nil)
+	assertReports(t, d.waitReports(2),
+		logitech.ConvertLightsOffTarget(logitech.FrontLight, logitech.FrontLightFID),
+		logitech.ConvertLightsOffTarget(logitech.BackLight, logitech.BackLightFID),
+	)
+}
+
+func TestSetLightsAction(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	const action = "ca.michaelabon.logitech-litra-lights.set"
+	settings := map[string]any{"temperature": "4000", "brightness": "50"}
+
+	d.send(action, streamdeck.WillAppear, t.Name(), settings)
+	d.expect(streamdeck.SetImage, t.Name())
+	if title := d.expectTitle(t.Name()); title != "4000" {
+		t.Errorf("Expected 4000, got %q", title)
+	}
+	if len(d.fake.Reports()) != 0 {
+		t.Errorf("WillAppear should not touch the light")
+	}
+
+	d.send(action, streamdeck.KeyDown, t.Name(), settings)
+	d.expect(streamdeck.SetSettings, t.Name())
+	if title := d.expectTitle(t.Name()); title != "4000" {
+		t.Errorf("Expected 4000, got %q", title)
+	}
+	assertReports(t, d.fake.Written(testPath),
+		logitech.ConvertLightsOn(),
+		mustBytes(logitech.ConvertBrightness(50)),
+		mustBytes(logitech.ConvertTemperature(4000)),
+	)
+}
+
+func TestActionShowsErrWithoutDevice(t *testing.T) {
+	d := newTestDeck(t)
+
+	d.send("ca.michaelabon.logitech-litra-lights.front.brightness", streamdeck.KeyDown, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "Err" {
+		t.Errorf("Expected Err, got %q", title)
+	}
+}
+
+func TestActionsTargetOneLight(t *testing.T) {
+	second := testLight
+	second.Path = "/dev/hidraw-litra-2"
+	second.SerialNbr = "2403FE0002"
+	d := newTestDeck(t, testLight, second)
+	settings := map[string]any{"device": second.SerialNbr}
+
+	d.send("ca.michaelabon.logitech-litra-lights.front.power", streamdeck.KeyDown, t.Name(), settings)
+	d.expectTitle(t.Name())
+
+	if len(d.fake.Written(testLight.Path)) != 0 {
+		t.Errorf("The other light should be untouched")
+	}
+	assertReports(t, d.fake.Written(second.Path), logitech.ConvertLightsOnTarget(logitech.FrontLight, logitech.FrontLightFID))
+}
+
+func TestTurnOffLightsActionReachesEveryLight(t *testing.T) {
+	second := testLight
+	second.Path = "/dev/hidraw-litra-2"
+	second.SerialNbr = "2403FE0002"
+	d := newTestDeck(t, testLight, second)
+
+	d.send("ca.michaelabon.logitech-litra-lights.off", streamdeck.KeyDown, t.Name(), nil)
+	d.waitReports(2)
+	assertReports(t, d.waitReportsOn(second.Path, 2),
+		logitech.ConvertLightsOffTarget(logitech.FrontLight, logitech.FrontLightFID),
+		logitech.ConvertLightsOffTarget(logitech.BackLight, logitech.BackLightFID),
+	)
+}
+
+func TestLightPickerListsLightsAndTargetsGroups(t *testing.T) {
+	second := testLight
+	second.Path = "/dev/hidraw-litra-2"
+	second.SerialNbr = "2403FE0002"
+	d := newTestDeck(t, testLight, second)
+	const action = "ca.michaelabon.logitech-litra-lights.front.brightness"
+
+	d.sendPayload(action, streamdeck.SendToPlugin, t.Name(), piMessage{Event: "getLights"})
+	var msg piMessage
+	if err := json.Unmarshal(d.expect(streamdeck.SendToPropertyInspector, t.Name()).Payload, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Event != "lights" || len(msg.Lights) != 2 || msg.Lights[1].ID != second.SerialNbr {
+		t.Fatalf("Unexpected light list %+v", msg)
+	}
+
+	d.sendPayload(action, streamdeck.SendToPlugin, t.Name(), piMessage{
+		Event:  "setGroups",
+		Groups: map[string][]string{"Key light": {second.SerialNbr}},
+	})
+	d.send(action, streamdeck.KeyDown, t.Name(), map[string]any{"device": "group:Key light"})
+	if title := d.expectTitle(t.Name()); title != "20%" {
+		t.Errorf("Expected 20%%, got %q", title)
+	}
+	if len(d.fake.Written(testLight.Path)) != 0 || len(d.fake.Written(second.Path)) != 1 {
+		t.Errorf("Expected only the grouped light to change")
+	}
+}
+
+func TestActionsSkipLightsWithoutTheFeature(t *testing.T) {
+	glow := testLight
+	glow.Path = "/dev/hidraw-glow"
+	glow.SerialNbr = "2201FE0001"
+	glow.ProductID = logitech.LitraGlow.ProductID
+	d := newTestDeck(t, testLight, glow)
+
+	// Only the Beam LX has a back light to turn on.
+	d.send("ca.michaelabon.logitech-litra-lights.back.power", streamdeck.KeyDown, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "ON" {
+		t.Errorf("Expected ON, got %q", title)
+	}
+	if len(d.fake.Written(glow.Path)) != 0 {
+		t.Errorf("Expected nothing sent to the Glow")
+	}
+	assertReports(t, d.fake.Written(testPath), logitech.ConvertLightsOnTarget(logitech.BackLight, logitech.BackLightFID))
+
+	// Turning everything off only sends the Glow its front light.
+	d.fake.Reset()
+	d.send("ca.michaelabon.logitech-litra-lights.off", streamdeck.KeyDown, t.Name(), nil)
+	d.waitReports(2)
+	assertReports(t, d.waitReportsOn(glow.Path, 1), mustBytes(logitech.LitraGlow.LightsOff(logitech.FrontLight)))
+}
+
+func TestActionsShowUnsupportedFeature(t *testing.T) {
+	glow := testLight
+	glow.ProductID = logitech.LitraGlow.ProductID
+	d := newTestDeck(t, glow)
+
+	d.send("ca.michaelabon.logitech-litra-lights.back.color", streamdeck.KeyDown, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "N/A" {
+		t.Errorf("Expected N/A, got %q", title)
+	}
+	if len(d.fake.Reports()) != 0 {
+		t.Errorf("Expected no reports, got %d", len(d.fake.Reports()))
+	}
+}
+
+func TestKeysShowLightStateWhenTheyAppear(t *testing.T) {
+	d := newTestDeck(t, testLight)
+
+	tests := []struct {
+		action string
+		want   string
+	}{
+		{"ca.michaelabon.logitech-litra-lights.front.power", "ON"},
+		{"ca.michaelabon.logitech-litra-lights.back.power", "OFF"},
+		{"ca.michaelabon.logitech-litra-lights.front.temperature", "4000K"},
+		{"ca.michaelabon.logitech-litra-lights.front.brightness", "50%"},
+		{"ca.michaelabon.logitech-litra-lights.back.brightness", "40%"},
+	}
+	for _, test := range tests {
+		d.send(test.action, streamdeck.WillAppear, test.action, nil)
+		if title := d.expectTitle(test.action); title != test.want {
+			t.Errorf("%s: expected %s, got %q", test.action, test.want, title)
+		}
+	}
+
+	// The cycles and toggles continue from the real state.
+	d.send("ca.michaelabon.logitech-litra-lights.front.power", streamdeck.KeyDown, tests[0].action, nil)
+	if title := d.expectTitle(tests[0].action); title != "OFF" {
+		t.Errorf("Expected the front light to turn OFF, got %q", title)
+	}
+	d.send("ca.michaelabon.logitech-litra-lights.front.temperature", streamdeck.KeyDown, tests[2].action, nil)
+	if title := d.expectTitle(tests[2].action); title != "5000K" {
+		t.Errorf("Expected 5000K, got %q", title)
+	}
+	d.send("ca.michaelabon.logitech-litra-lights.front.brightness", streamdeck.KeyDown, tests[3].action, nil)
+	if title := d.expectTitle(tests[3].action); title != "60%" {
+		t.Errorf("Expected 60%%, got %q", title)
+	}
+}
+
+func TestKeysFollowChangesMadeOnTheLight(t *testing.T) {
+	d := newTestDeck(t, testLight)
+
+	d.send(frontBrightnessUUID, streamdeck.WillAppear, "brightness", nil)
+	if title := d.expectTitle("brightness"); title != "50%" {
+		t.Fatalf("Expected 50%%, got %q", title)
+	}
+	d.send(frontPowerUUID, streamdeck.WillAppear, "power", nil)
+	if title := d.expectTitle("power"); title != "ON" {
+		t.Fatalf("Expected ON, got %q", title)
+	}
+
+	// Someone turns the brightness dial, then the light off, on the light itself.
+	if err := d.fake.QueueRead(testPath, []byte{0x11, 0xff, 0x06, 0x10, 0x01, 0x90}); err != nil {
+		t.Fatal(err)
+	}
+	if title := d.expectTitle("brightness"); title != "100%" {
+		t.Errorf("Expected 100%%, got %q", title)
+	}
+	if err := d.fake.QueueRead(testPath, []byte{0x11, 0xff, 0x06, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if title := d.expectTitle("power"); title != "OFF" {
+		t.Errorf("Expected OFF, got %q", title)
+	}
+
+	// The toggle continues from what the light reported.
+	d.send(frontPowerUUID, streamdeck.KeyDown, "power", nil)
+	if title := d.expectTitle("power"); title != "ON" {
+		t.Errorf("Expected the toggle to turn the light ON, got %q", title)
+	}
+
+	// Hidden keys are left alone.
+	d.send(frontPowerUUID, streamdeck.WillDisappear, "power", nil)
+	d.send(frontBrightnessUUID, streamdeck.WillAppear, "brightness", nil)
+	d.expectTitle("brightness") // handlers run in order, so the power key is gone
+	if err := d.fake.QueueRead(testPath, []byte{0x11, 0xff, 0x06, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.fake.QueueRead(testPath, []byte{0x11, 0xff, 0x06, 0x10, 0x00, 0x1e}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		e := d.next()
+		if e.Event != streamdeck.SetTitle {
+			continue
+		}
+		if e.Context == "power" {
+			t.Fatal("Expected the hidden key not to be updated")
+		}
+		if e.Context == "brightness" {
+			break
+		}
+	}
+}
+
+func TestKeysShowWhenTheirLightIsUnplugged(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	deviceMgr.watchInterval = 5 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	deviceMgr.Watch(ctx)
+
+	d.send(frontBrightnessUUID, streamdeck.WillAppear, "brightness", nil)
+	if title := d.expectTitle("brightness"); title != "50%" {
+		t.Fatalf("Expected 50%%, got %q", title)
+	}
+	d.send(frontBrightnessUUID, streamdeck.KeyDown, "brightness", nil)
+	if title := d.expectTitle("brightness"); title != "60%" {
+		t.Fatalf("Expected 60%%, got %q", title)
+	}
+
+	d.fake.Detach(testPath)
+	d.waitTitle("brightness", offlineTitle)
+
+	// Plugged back in, the light is put back at 60% and the key shows what
+	// the light then reports (the fake always reports 50%).
+	d.fake.Reset()
+	d.fake.Attach(testLight)
+	if title := d.expectTitle("brightness"); title != "50%" {
+		t.Errorf("Expected the reported 50%%, got %q", title)
+	}
+	assertReports(t, d.fake.Written(testPath)[:1], mustBytes(logitech.LitraBeamLX.Brightness(logitech.FrontLight, 60)))
+}
+
+func TestActionsShowRefusedCommands(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	d.fake.Respond(func(info transport.DeviceInfo, report []byte) [][]byte {
+		if len(report) > 3 && report[3] == 0x4c {
+			return [][]byte{hidError(report, logitech.ErrBusy)}
+		}
+		return emulateLitras(info, report)
+	})
+
+	d.send(frontBrightnessUUID, streamdeck.KeyDown, "ctx", nil)
+	if title := d.expectTitle("ctx"); title != "Err\nbusy" {
+		t.Errorf("Expected the refusal on the key, got %q", title)
+	}
+}
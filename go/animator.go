@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+const (
+	// defaultFrameRate is how many frames an effect renders per second
+	// unless it asks for another rate, limited to maxFrameRate. Each frame is
+	// three acknowledged reports, which a light takes a few milliseconds to answer.
+	defaultFrameRate = 20
+	maxFrameRate     = 30
+)
+
+// animation is one effect running on the back lights of a target.
+type animation struct {
+	target string
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// ended is called once when the animation stops, with the error that
+	// stopped it, or nil when it was stopped on purpose.
+	ended func(error)
+	once  sync.Once
+}
+
+func (a *animation) end(err error) {
+	a.once.Do(func() {
+		if a.ended != nil {
+			a.ended(err)
+		}
+	})
+}
+
+// animator runs the effects streaming frames to the back lights.
+type animator struct {
+	mu      sync.Mutex
+	running []*animation
+}
+
+// StartEffect streams effect's frames to the back lights of target, fps times
+// a second, until StopEffects stops it. It first stops any effect on the
+// same lights. ended is called when the effect stops.
+func (dm *DeviceManager) StartEffect(target, name string, effect Effect, fps int, ended func(error)) {
+	if fps <= 0 {
+		fps = defaultFrameRate
+	}
+	fps = min(fps, maxFrameRate)
+
+	dm.StopEffects(target)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a := &animation{target: target, name: name, cancel: cancel, done: make(chan struct{}), ended: ended}
+
+	dm.effects.mu.Lock()
+	dm.effects.running = append(dm.effects.running, a)
+	dm.effects.mu.Unlock()
+
+	go dm.animate(ctx, a, effect, time.Second/time.Duration(fps))
+}
+
+// StopEffects stops the effects running on any of target's lights and waits
+// for them to finish, so a command submitted next isn't overwritten by a
+// frame. It reports whether any effect was running.
+func (dm *DeviceManager) StopEffects(target string) bool {
+	dm.effects.mu.Lock()
+	var stopped, kept []*animation
+	for _, a := range dm.effects.running {
+		if dm.overlaps(a.target, target) {
+			stopped = append(stopped, a)
+		} else {
+			kept = append(kept, a)
+		}
+	}
+	dm.effects.running = kept
+	dm.effects.mu.Unlock()
+
+	for _, a := range stopped {
+		a.cancel()
+		<-a.done
+		a.end(nil)
+	}
+	return len(stopped) > 0
+}
+
+// RunningEffect returns the name of the effect running on exactly target.
+func (dm *DeviceManager) RunningEffect(target string) (string, bool) {
+	dm.effects.mu.Lock()
+	defer dm.effects.mu.Unlock()
+	for _, a := range dm.effects.running {
+		if a.target == target {
+			return a.name, true
+		}
+	}
+	return "", false
+}
+
+// animate renders a frame every interval and submits it. A frame that is due
+// while the last one is still being written is skipped, so a slow light
+// drops frames instead of falling behind. When it stops, the last frame
+// written is recorded as the back lights' colours.
+func (dm *DeviceManager) animate(ctx context.Context, a *animation, effect Effect, interval time.Duration) {
+	defer close(a.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	lit := false // whether a frame, which turns the back lights on, got through
+	var last, shown logitech.Frame
+	var pending <-chan error
+	defer func() {
+		if pending != nil && <-pending == nil {
+			lit, shown = true, last
+		}
+		if lit {
+			dm.settle(a.target, shown)
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-pending:
+			pending = nil
+			if err == nil {
+				lit, shown = true, last
+			}
+			if err == nil || ctx.Err() != nil {
+				continue
+			}
+			var deviceErr *logitech.DeviceError
+			if errors.Is(err, logitech.ErrUnsupported) || errors.As(err, &deviceErr) {
+				log.Printf("Stopping the %s effect: %v", a.name, err)
+				dm.finish(a, err)
+				return
+			}
+			log.Printf("Effect frame for %q failed: %v", a.target, err)
+		case <-ticker.C:
+			if pending != nil {
+				continue
+			}
+			frame := effect.Frame(time.Since(start))
+			if frame == last && lit {
+				continue
+			}
+			pending = dm.Submit(ctx, frameCommand(a.target, frame, !lit))
+			last = frame
+		}
+	}
+}
+
+// settle records frame as the colours of the back lights of target, for
+// back power restore, once an effect stops on it. Only that frame is
+// recorded, so the state's watchers aren't sent every frame.
+func (dm *DeviceManager) settle(target string, frame logitech.Frame) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	for id, l := range dm.lights {
+		if !dm.selects(target, id) || !l.Model.HasColor() {
+			continue
+		}
+		if commands, err := l.Model.Frame(logitech.BackLight, frame); err == nil {
+			l.recordBackColors(commands)
+		}
+	}
+}
+
+// finish removes an animation that stopped on its own and reports why.
+func (dm *DeviceManager) finish(a *animation, err error) {
+	dm.effects.mu.Lock()
+	for i, running := range dm.effects.running {
+		if running == a {
+			dm.effects.running = append(dm.effects.running[:i:i], dm.effects.running[i+1:]...)
+			break
+		}
+	}
+	dm.effects.mu.Unlock()
+	a.end(err)
+}
+
+// frameCommand sets the back lights of target to frame, turning them on first
+// when on is set. Unlike a static colour, the frame isn't remembered: the
+// effect records the frame it stops on.
+func frameCommand(target string, frame logitech.Frame, on bool) Command {
+	return Command{Target: target, Attribute: "back.effect", Build: func(l *Light) ([][]byte, error) {
+		colorCmds, err := l.Model.Frame(logitech.BackLight, frame)
+		if err != nil {
+			return nil, err
+		}
+		if !on {
+			return colorCmds, nil
+		}
+		onBytes, err := l.Model.LightsOn(logitech.BackLight)
+		if err != nil {
+			return nil, err
+		}
+		return append([][]byte{onBytes}, colorCmds...), nil
+	}}
+}
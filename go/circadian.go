@@ -0,0 +1,157 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+const (
+	// defaultSunInterval is how often a schedule is checked for a new temperature.
+	defaultSunInterval = time.Minute
+	// defaultSunFade is how long the light takes to reach each new temperature.
+	defaultSunFade = 2 * time.Second
+)
+
+// follower keeps the front lights of a target on a schedule.
+type follower struct {
+	target string
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// ended is called once when the schedule stops, with the error that
+	// stopped it, or nil when it was stopped on purpose.
+	ended func(error)
+	once  sync.Once
+}
+
+func (f *follower) end(err error) {
+	f.once.Do(func() {
+		if f.ended != nil {
+			f.ended(err)
+		}
+	})
+}
+
+// followers are the schedules running, like animator for effects.
+type followers struct {
+	mu       sync.Mutex
+	running  []*follower
+	interval time.Duration // defaultSunInterval unless a test shortens it
+	fade     time.Duration // defaultSunFade unless a test shortens it
+}
+
+// FollowSchedule sets the front lights of target to the schedule's
+// temperature now and whenever it changes, until StopFollowing stops it. It
+// first stops any schedule on the same lights. changed is called with each
+// temperature the lights took, and ended when the schedule stops.
+func (dm *DeviceManager) FollowSchedule(target string, schedule Schedule, changed func(kelvin uint16), ended func(error)) {
+	dm.StopFollowing(target)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &follower{target: target, cancel: cancel, done: make(chan struct{}), ended: ended}
+
+	dm.schedules.mu.Lock()
+	dm.schedules.running = append(dm.schedules.running, f)
+	interval := cmp.Or(dm.schedules.interval, defaultSunInterval)
+	tr := Transition{Duration: cmp.Or(dm.schedules.fade, defaultSunFade)}
+	dm.schedules.mu.Unlock()
+
+	go dm.follow(ctx, f, schedule, interval, tr, changed)
+}
+
+// StopFollowing stops the schedules on any of target's lights and waits for
+// them to finish. It reports whether any schedule was running.
+func (dm *DeviceManager) StopFollowing(target string) bool {
+	dm.schedules.mu.Lock()
+	var stopped, kept []*follower
+	for _, f := range dm.schedules.running {
+		if dm.overlaps(f.target, target) {
+			stopped = append(stopped, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	dm.schedules.running = kept
+	dm.schedules.mu.Unlock()
+
+	for _, f := range stopped {
+		f.cancel()
+		<-f.done
+		f.end(nil)
+	}
+	return len(stopped) > 0
+}
+
+// Following reports whether a schedule runs on exactly target.
+func (dm *DeviceManager) Following(target string) bool {
+	dm.schedules.mu.Lock()
+	defer dm.schedules.mu.Unlock()
+	for _, f := range dm.schedules.running {
+		if f.target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// follow checks the schedule every interval and fades the lights to a new
+// temperature over tr. A light that is unplugged or busy is tried again next time.
+func (dm *DeviceManager) follow(ctx context.Context, f *follower, schedule Schedule, interval time.Duration, tr Transition, changed func(uint16)) {
+	defer close(f.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last uint16
+	for {
+		if kelvin := schedule.Temperature(time.Now()); kelvin != last {
+			fade := Fade{Target: f.target, Attribute: "front.temperature", Fader: scheduledTemperature(kelvin)}
+			err := <-dm.SubmitFade(ctx, fade, tr)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case errors.Is(err, logitech.ErrUnsupported):
+				log.Printf("Stopping the schedule on %q: %v", f.target, err)
+				dm.unfollow(f)
+				f.end(err)
+				return
+			case err != nil:
+				log.Printf("Scheduled temperature for %q failed: %v", f.target, err)
+			default:
+				last = kelvin
+				changed(kelvin)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// unfollow removes a follower that stopped on its own.
+func (dm *DeviceManager) unfollow(f *follower) {
+	dm.schedules.mu.Lock()
+	defer dm.schedules.mu.Unlock()
+	for i, running := range dm.schedules.running {
+		if running == f {
+			dm.schedules.running = append(dm.schedules.running[:i:i], dm.schedules.running[i+1:]...)
+			return
+		}
+	}
+}
+
+// scheduledTemperature fades to kelvin, kept within what each light can show.
+func scheduledTemperature(kelvin uint16) fader {
+	return func(l *Light) (func(float64) ([][]byte, error), error) {
+		return fadeTemperature(min(max(kelvin, l.Model.MinTemperature), l.Model.MaxTemperature))(l)
+	}
+}
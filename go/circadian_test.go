@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+// fixedSchedule asks for whatever temperature it was last set to.
+type fixedSchedule struct {
+	kelvin atomic.Uint32
+}
+
+func (s *fixedSchedule) Temperature(time.Time) uint16 {
+	return uint16(s.kelvin.Load())
+}
+
+func TestFollowScheduleSetsEachNewTemperature(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.schedules.interval = 10 * time.Millisecond
+	dm.schedules.fade = 20 * time.Millisecond
+	m := logitech.LitraBeamLX
+	schedule := &fixedSchedule{}
+	schedule.kelvin.Store(3000)
+	changes := make(chan uint16, 10)
+	ended := make(chan error, 1)
+
+	dm.FollowSchedule(AllLights, schedule, func(k uint16) { changes <- k }, func(err error) { ended <- err })
+	if k := <-changes; k != 3000 {
+		t.Errorf("Expected 3000K first, got %dK", k)
+	}
+	schedule.kelvin.Store(9000) // beyond what the light can show
+	if k := <-changes; k != 9000 {
+		t.Errorf("Expected the schedule's next temperature, got %dK", k)
+	}
+	if !dm.Following(AllLights) {
+		t.Error("Expected the schedule running")
+	}
+
+	written := fake.Written(testPath)
+	assertReports(t, written[len(written)-1:], mustBytes(m.Temperature(logitech.FrontLight, m.MaxTemperature)))
+
+	// A schedule that doesn't change writes nothing more.
+	time.Sleep(5 * dm.schedules.interval)
+	if got := len(fake.Written(testPath)); got != len(written) {
+		t.Errorf("Expected no writes while the temperature holds, got %d more", got-len(written))
+	}
+
+	if !dm.StopFollowing(AllLights) {
+		t.Error("Expected StopFollowing to report the running schedule")
+	}
+	if err := <-ended; err != nil {
+		t.Errorf("Expected no error when stopped, got %v", err)
+	}
+	if dm.Following(AllLights) {
+		t.Error("Expected no schedule running")
+	}
+}
+
+func TestTemperatureKeyStopsFollowingTheSun(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	deviceMgr.schedules.fade = 50 * time.Millisecond
+	settings := map[string]any{"latitude": "43.65", "longitude": "-79.38", "timezone": "America/Toronto", "warm": "3000", "cool": "6000"}
+	schedule, err := (&CircadianSettings{Latitude: "43.65", Longitude: "-79.38", TimeZone: "America/Toronto", Warm: 3000, Cool: 6000}).schedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.send(frontCircadianUUID, streamdeck.KeyDown, t.Name(), settings)
+	if title := d.expectTitle(t.Name()); title != "Sun\nON" {
+		t.Errorf("Expected Sun ON, got %q", title)
+	}
+	want := "Sun\n" + strconv.Itoa(int(schedule.Temperature(time.Now()))) + "K"
+	if title := d.expectTitle(t.Name()); title != want {
+		t.Errorf("Expected %q, got %q", want, title)
+	}
+
+	// Picking a temperature by hand stops following the sun.
+	d.send(frontTemperatureUUID, streamdeck.KeyDown, "temperature", nil)
+	if title := d.expectTitle(t.Name()); title != "Sun\nOFF" {
+		t.Errorf("Expected Sun OFF, got %q", title)
+	}
+	d.expectTitle("temperature")
+	if deviceMgr.Following(AllLights) {
+		t.Error("Expected the sun no longer followed")
+	}
+}
+
+func TestFollowTheSunNeedsALocation(t *testing.T) {
+	d := newTestDeck(t, testLight)
+
+	d.send(frontCircadianUUID, streamdeck.KeyDown, t.Name(), map[string]any{"latitude": "north"})
+	if title := d.expectTitle(t.Name()); title != "Set\nLocation" {
+		t.Errorf("Expected Set Location, got %q", title)
+	}
+	if deviceMgr.Following(AllLights) {
+		t.Error("Expected nothing followed")
+	}
+
+	_, err := (&CircadianSettings{Latitude: "45", Longitude: "-75", TimeZone: "Mars/Olympus"}).schedule()
+	if err == nil || errors.Is(err, errNoLocation) {
+		t.Errorf("Expected an unknown time zone error, got %v", err)
+	}
+}
@@ -7,14 +7,13 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
 	"github.com/sstallion/go-hid"
 )
 
-const (
-	VID = 0x046d
-	PID = 0xc903
-)
+const VID = logitech.VendorID
 
 func main() {
 	if err := hid.Init(); err != nil {
@@ -22,18 +21,22 @@ func main() {
 	}
 	defer hid.Exit()
 
-	fmt.Printf("Searching for Litra Beam LX (0x%04x:0x%04x)...\n", VID, PID)
+	fmt.Printf("Searching for Litra lights (0x%04x)...\n", VID)
 	var devices []*hid.DeviceInfo
-	hid.Enumerate(VID, PID, func(info *hid.DeviceInfo) error {
+	hid.Enumerate(VID, hid.ProductIDAny, func(info *hid.DeviceInfo) error {
+		model, ok := logitech.ModelByProductID(info.ProductID)
+		if !ok {
+			return nil
+		}
 		devices = append(devices, info)
-		fmt.Printf("[%d] Path: %s\n", len(devices)-1, info.Path)
+		fmt.Printf("[%d] %s (0x%04x) Path: %s\n", len(devices)-1, model.Name, info.ProductID, info.Path)
 		fmt.Printf("    Interface: %d | UsagePage: 0x%04x | Usage: 0x%04x\n", info.InterfaceNbr, info.UsagePage, info.Usage)
 		fmt.Printf("    Product: %s\n", info.ProductStr)
 		return nil
 	})
 
 	if len(devices) == 0 {
-		fmt.Println("Litra Beam LX not found.")
+		fmt.Println("No Litra light found.")
 		return
 	}
 
@@ -53,6 +56,8 @@ func main() {
 	defer dev.Close()
 
 	fmt.Printf("\nConnected to [%d] (UsagePage: 0x%04x)\n", idx, selected.UsagePage)
+	features := printFeatures(dev)
+	printDeviceInfo(dev, features)
 	fmt.Println("\nCommands (back light FID=0x0a):")
 	fmt.Println("  1. Back Power ON:    11 ff 0a 1c 01")
 	fmt.Println("  2. Back Power OFF:   11 ff 0a 1c 00")
@@ -62,14 +67,7 @@ func main() {
 	fmt.Println("\nCommands (front light FID=0x06):")
 	fmt.Println("  6. Front Power ON:   11 ff 06 1c 01")
 	fmt.Println("  7. Front Power OFF:  11 ff 06 1c 00")
-	fmt.Println("\nAlternate back light FIDs to try:")
-	fmt.Println("  8.  FID=0x07 ON:     11 ff 07 1c 01")
-	fmt.Println("  9.  FID=0x08 ON:     11 ff 08 1c 01")
-	fmt.Println("  10. FID=0x09 ON:     11 ff 09 1c 01")
-	fmt.Println("  11. FID=0x0b ON:     11 ff 0b 1c 01")
-	fmt.Println("  12. FID=0x0c ON:     11 ff 0c 1c 01")
-	fmt.Println("  13. FID=0x0d ON:     11 ff 0d 1c 01")
-	fmt.Println("\nOr type hex directly (e.g. '11 ff 0a 1c 01'). 'r' to read response. 'q' to quit.")
+	fmt.Println("\nOr type hex directly (e.g. '11 ff 0a 1c 01'). 'r' to read response. 'f' to list features and device info. 'q' to quit.")
 
 	for {
 		fmt.Print("> ")
@@ -78,6 +76,10 @@ func main() {
 		if input == "q" {
 			break
 		}
+		if input == "f" {
+			printDeviceInfo(dev, printFeatures(dev))
+			continue
+		}
 		if input == "r" {
 			buf := make([]byte, 20)
 			n, err := dev.ReadWithTimeout(buf, 1000)
@@ -105,18 +107,6 @@ func main() {
 			cmd = []byte{0x11, 0xff, 0x06, 0x1c, 0x01}
 		case "7":
 			cmd = []byte{0x11, 0xff, 0x06, 0x1c, 0x00}
-		case "8":
-			cmd = []byte{0x11, 0xff, 0x07, 0x1c, 0x01}
-		case "9":
-			cmd = []byte{0x11, 0xff, 0x08, 0x1c, 0x01}
-		case "10":
-			cmd = []byte{0x11, 0xff, 0x09, 0x1c, 0x01}
-		case "11":
-			cmd = []byte{0x11, 0xff, 0x0b, 0x1c, 0x01}
-		case "12":
-			cmd = []byte{0x11, 0xff, 0x0c, 0x1c, 0x01}
-		case "13":
-			cmd = []byte{0x11, 0xff, 0x0d, 0x1c, 0x01}
 		default:
 			parts := strings.Fields(input)
 			for _, p := range parts {
@@ -131,6 +121,9 @@ func main() {
 			padded := make([]byte, 20)
 			copy(padded, cmd)
 			fmt.Printf("Sending: % x\n", padded)
+			if c, err := logitech.Decode(padded); err == nil {
+				fmt.Printf("         %s\n", c)
+			}
 			n, err := dev.Write(padded)
 			if err != nil {
 				fmt.Printf("Write error: %v\n", err)
@@ -142,7 +135,121 @@ func main() {
 			rn, rerr := dev.ReadWithTimeout(resp, 500)
 			if rerr == nil && rn > 0 {
 				fmt.Printf("Response: % x\n", resp[:rn])
+				if _, err := logitech.ParseResponse(resp[:rn]); err != nil {
+					fmt.Printf("Refused: %v\n", err)
+				}
 			}
 		}
 	}
 }
+
+// query sends a request and waits for the answer to it, skipping other reports.
+func query(dev *hid.Device, request []byte) (logitech.Response, error) {
+	if _, err := dev.Write(request); err != nil {
+		return logitech.Response{}, err
+	}
+	buf := make([]byte, 20)
+	for {
+		n, err := dev.ReadWithTimeout(buf, time.Second)
+		if err != nil {
+			return logitech.Response{}, err
+		}
+		r, err := logitech.ParseResponse(buf[:n])
+		if r.Answers(request) {
+			return r, err
+		}
+	}
+}
+
+// printFeatures lists the light's features through IRoot and IFeatureSet.
+func printFeatures(dev *hid.Device) logitech.Features {
+	r, err := query(dev, logitech.GetFeatureIndex(logitech.FeatureSet))
+	if err != nil {
+		fmt.Printf("IRoot error: %v\n", err)
+		return nil
+	}
+	setIndex, err := logitech.DecodeFeatureIndex(r)
+	if err != nil {
+		fmt.Printf("IFeatureSet: %v\n", err)
+		return nil
+	}
+	r, err = query(dev, logitech.GetFeatureCount(setIndex))
+	if err != nil {
+		fmt.Printf("IFeatureSet error: %v\n", err)
+		return nil
+	}
+	count, _ := logitech.DecodeFeatureCount(r)
+
+	names := map[uint16]string{
+		logitech.FeatureRoot:              "IRoot",
+		logitech.FeatureSet:               "IFeatureSet",
+		logitech.FeatureDeviceInformation: "DeviceInformation",
+		logitech.FeatureDeviceName:        "DeviceName",
+		logitech.FeatureIllumination:      "Illumination (front light)",
+		logitech.FeatureBrightnessControl: "BrightnessControl (back light)",
+		logitech.FeaturePerKeyLighting:    "PerKeyLighting (back light colour)",
+	}
+	fmt.Println("\nFeatures:")
+	features := logitech.Features{logitech.FeatureRoot: 0}
+	fmt.Printf("  0x00: 0x0000 %s\n", names[logitech.FeatureRoot])
+	for i := 1; i <= count; i++ {
+		r, err := query(dev, logitech.GetFeatureID(setIndex, byte(i)))
+		if err != nil {
+			fmt.Printf("  0x%02x: error %v\n", i, err)
+			continue
+		}
+		id, _ := logitech.DecodeFeatureID(r)
+		features[id] = byte(i)
+		fmt.Printf("  0x%02x: 0x%04x %s\n", i, id, names[id])
+	}
+	return features
+}
+
+// printDeviceInfo shows the light's name, firmware versions and serial number.
+func printDeviceInfo(dev *hid.Device, features logitech.Features) {
+	if index, ok := features[logitech.FeatureDeviceName]; ok {
+		if r, err := query(dev, logitech.GetNameLength(index)); err == nil {
+			length, _ := logitech.DecodeNameLength(r)
+			name := ""
+			for len(name) < length {
+				r, err := query(dev, logitech.GetNamePart(index, byte(len(name))))
+				part := logitech.DecodeNamePart(r)
+				if err != nil || part == "" {
+					break
+				}
+				name += part
+			}
+			fmt.Printf("\nName:     %s\n", name)
+		}
+	}
+
+	index, ok := features[logitech.FeatureDeviceInformation]
+	if !ok {
+		return
+	}
+	r, err := query(dev, logitech.GetDeviceInfo(index))
+	if err != nil {
+		fmt.Printf("DeviceInformation error: %v\n", err)
+		return
+	}
+	entities, hasSerial, _ := logitech.DecodeDeviceInfo(r)
+	for entity := 0; entity < entities; entity++ {
+		r, err := query(dev, logitech.GetFirmwareInfo(index, byte(entity)))
+		if err != nil {
+			fmt.Printf("Firmware %d: error %v\n", entity, err)
+			continue
+		}
+		version, application, _ := logitech.DecodeFirmwareInfo(r)
+		kind := "other"
+		if application {
+			kind = "application"
+		}
+		fmt.Printf("Firmware: %s (%s)\n", version, kind)
+	}
+	if hasSerial {
+		if r, err := query(dev, logitech.GetSerialNumber(index)); err == nil {
+			serial, _ := logitech.DecodeSerialNumber(r)
+			fmt.Printf("Serial:   %s\n", serial)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/samwho/streamdeck"
+)
+
+// Values the Front Temperature, Front Brightness and Back Brightness Cycle
+// keys step through until they're given their own.
+var (
+	defaultTemperatures = []uint16{2700, 3200, 4000, 5000, 6500}
+	defaultBrightnesses = []uint8{20, 40, 60, 80, 100}
+)
+
+// CycleSettings for the Front Temperature, Front Brightness and Back
+// Brightness Cycle actions. The Property Inspector saves them as text.
+type CycleSettings struct {
+	TargetSettings
+	TransitionSettings
+	Values    string `json:"values"`    // in the order they're stepped through, separated by commas or spaces
+	Direction string `json:"direction"` // "forward" (default) or "reverse"
+	Mode      string `json:"mode"`      // "wrap" (default) goes back to the first value after the last; "bounce" turns back
+	Start     string `json:"start"`     // the value to start at, counting from 1; empty follows the light
+}
+
+// valueCycle is the order a cycling key steps through its values. Positions
+// count presses: with bounce, the way back has positions of its own.
+type valueCycle[T uint8 | uint16] struct {
+	values  []T  // in the order they're stepped through
+	reverse bool // stepping from the last value listed to the first
+	bounce  bool
+	start   int // the position of the first press, or -1 to follow the light
+}
+
+// newValueCycle reads a key's cycle from its settings. Every value must pass
+// check; a key without values steps through defaults.
+func newValueCycle[T uint8 | uint16](s CycleSettings, defaults []T, check func(T) error) (valueCycle[T], error) {
+	c := valueCycle[T]{
+		values:  slices.Clone(defaults),
+		reverse: s.Direction == "reverse",
+		bounce:  s.Mode == "bounce",
+		start:   -1,
+	}
+	fields := strings.FieldsFunc(s.Values, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) > 0 {
+		c.values = c.values[:0]
+		for _, field := range fields {
+			v, err := strconv.ParseUint(field, 10, 16)
+			if err == nil && uint64(T(v)) != v {
+				err = strconv.ErrRange
+			}
+			if err == nil {
+				err = check(T(v))
+			}
+			if err != nil {
+				return c, fmt.Errorf("value %q: %w", field, err)
+			}
+			c.values = append(c.values, T(v))
+		}
+	}
+	if s.Start != "" {
+		i, err := strconv.Atoi(s.Start)
+		if err != nil || i < 1 || i > len(c.values) {
+			return c, fmt.Errorf("start %q: not between 1 and %d", s.Start, len(c.values))
+		}
+		c.start = i - 1
+	}
+	if c.reverse {
+		slices.Reverse(c.values)
+		if c.start >= 0 {
+			c.start = len(c.values) - 1 - c.start
+		}
+	}
+	return c, nil
+}
+
+// cycleFromEvent reads a cycling key's cycle from an event carrying its settings.
+func cycleFromEvent[T uint8 | uint16](event streamdeck.Event, defaults []T, check func(T) error) (valueCycle[T], error) {
+	p := streamdeck.KeyDownPayload{}
+	s := CycleSettings{}
+	if err := json.Unmarshal(event.Payload, &p); err != nil {
+		return valueCycle[T]{}, err
+	}
+	if len(p.Settings) > 0 {
+		if err := json.Unmarshal(p.Settings, &s); err != nil {
+			return valueCycle[T]{}, err
+		}
+	}
+	return newValueCycle(s, defaults, check)
+}
+
+// len returns the number of presses before the cycle repeats.
+func (c valueCycle[T]) len() int {
+	if c.bounce && len(c.values) > 2 {
+		return 2*len(c.values) - 2
+	}
+	return len(c.values)
+}
+
+// at returns the value at position i.
+func (c valueCycle[T]) at(i int) T {
+	i %= c.len()
+	if i >= len(c.values) {
+		i = c.len() - i
+	}
+	return c.values[i]
+}
+
+// startAt returns the position of a key's first press. Unless the key has a
+// start, it's the first value past the light's current one, in the cycle's
+// direction, so the cycle continues from the light's real value.
+func (c valueCycle[T]) startAt(current T, known bool) int {
+	if c.start >= 0 {
+		return c.start
+	}
+	if !known {
+		return 0
+	}
+	for i, v := range c.values {
+		if c.reverse && v < current || !c.reverse && v > current {
+			return i
+		}
+	}
+	return 0
+}
+
+// placeCycle moves a cycling key to the position its next press starts from:
+// where it was before the plugin restarted, when it first appears, or else
+// from where the light is.
+func placeCycle[T uint8 | uint16](positions *cycles, event streamdeck.Event, c valueCycle[T], current T, known bool) {
+	if event.Event == streamdeck.WillAppear && positions.resume(event.Context) {
+		return
+	}
+	positions.set(event.Context, c.startAt(current, known))
+}
+
+// showCycleError marks a cycling key whose values the lights can't take.
+func showCycleError(ctx context.Context, client *streamdeck.Client, err error) error {
+	log.Printf("Invalid cycle: %v\n", err)
+	return client.SetTitle(ctx, "Check\nValues", streamdeck.HardwareAndSoftware)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+func TestValueCycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings CycleSettings
+		want     []uint8
+	}{
+		{"defaults", CycleSettings{}, []uint8{20, 40, 60, 80, 100, 20}},
+		{"own values", CycleSettings{Values: "10, 50,90"}, []uint8{10, 50, 90, 10}},
+		{"reverse", CycleSettings{Values: "10 50 90", Direction: "reverse"}, []uint8{90, 50, 10, 90}},
+		{"bounce", CycleSettings{Values: "10, 50, 90", Mode: "bounce"}, []uint8{10, 50, 90, 50, 10, 50}},
+		{"start", CycleSettings{Values: "10, 50, 90", Start: "2"}, []uint8{50, 90, 10}},
+		{"reverse start", CycleSettings{Values: "10, 50, 90", Direction: "reverse", Start: "1"}, []uint8{10, 90, 50}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := newValueCycle(test.settings, defaultBrightnesses, logitech.CheckBrightness)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint8
+			for i := c.startAt(0, false); len(got) < len(test.want); i++ {
+				got = append(got, c.at(i))
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestValueCycleRejectsValuesTheLightsCantTake(t *testing.T) {
+	for _, s := range []CycleSettings{
+		{Values: "2700, 7000"},
+		{Values: "warm"},
+		{Values: "2700, 4000", Start: "3"},
+	} {
+		if _, err := newValueCycle(s, defaultTemperatures, logitech.CheckTemperature); err == nil {
+			t.Errorf("Expected %+v to be refused", s)
+		}
+	}
+	if _, err := newValueCycle(CycleSettings{Values: "0, 50"}, defaultBrightnesses, logitech.CheckBrightness); err == nil {
+		t.Error("Expected 0% to be refused")
+	}
+}
+
+func TestFrontTemperatureCycleSettings(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	ctx := t.Name()
+	settings := CycleSettings{Values: "3000, 4500, 6000", Mode: "bounce", Start: "2"}
+
+	d.send(frontTemperatureUUID, streamdeck.WillAppear, ctx, settings)
+	d.expectTitle(ctx)
+	// The key also follows what the light reports, so titles can repeat.
+	for _, want := range []string{"4500K", "6000K", "4500K", "3000K"} {
+		d.send(frontTemperatureUUID, streamdeck.KeyDown, ctx, settings)
+		d.waitTitle(ctx, want)
+	}
+
+	// New settings start the cycle again.
+	settings = CycleSettings{Values: "5000, 2700", Start: "2"}
+	d.send(frontTemperatureUUID, streamdeck.DidReceiveSettings, ctx, settings)
+	d.expectTitle(ctx)
+	d.send(frontTemperatureUUID, streamdeck.KeyDown, ctx, settings)
+	d.waitTitle(ctx, "2700K")
+
+	settings.Values = "5000, 9000"
+	d.send(frontTemperatureUUID, streamdeck.KeyDown, ctx, settings)
+	d.waitTitle(ctx, "Check\nValues")
+}
@@ -0,0 +1,1004 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/michaelabon/streamdeck-logitech-litra/internal/transport"
+)
+
+const (
+	VID       = logitech.VendorID
+	UsagePage = 0xff43
+
+	maxRetries = 3
+	retryDelay = 500 * time.Millisecond
+
+	// scanInterval limits how often a write re-enumerates to find newly attached lights.
+	scanInterval = 2 * time.Second
+
+	// watchInterval is how often Watch looks for lights being plugged in or unplugged.
+	watchInterval = 2 * time.Second
+
+	// queryTimeout is how long to wait for a light to answer a request or
+	// acknowledge a command.
+	queryTimeout = 500 * time.Millisecond
+)
+
+// Targets accepted by DeviceManager.WriteTo besides a light ID.
+const (
+	AllLights   = ""       // every attached light
+	groupPrefix = "group:" // followed by a group name
+)
+
+// Light is one attached Litra, identified by its serial number, or by its
+// HID path when the firmware reports no serial.
+type Light struct {
+	ID    string
+	Info  transport.DeviceInfo
+	Model logitech.Model // with the feature indices the light reported
+
+	// Features is the light's feature table, read once when it connects.
+	// Nil if the light didn't answer.
+	Features logitech.Features
+
+	// DeviceInfo is the light's name, firmware and serial as it reports them.
+	// Fields the light didn't report are empty.
+	DeviceInfo logitech.DeviceInfo
+
+	device     transport.Device
+	reader     *reader // reads everything the open device sends
+	discovered bool
+
+	// state is the last state the plugin set or the light reported, replayed
+	// when the light is plugged back in.
+	state     *StateStore
+	unplugged bool // set while detached, until the state is replayed
+
+	// newColors are the back light colour commands being built, recorded
+	// by write once they're sent.
+	newColors [][]byte
+}
+
+// setting is one value of a light that can be replayed, e.g. the front light's brightness.
+type setting struct {
+	target logitech.LightTarget
+	kind   logitech.EventKind
+}
+
+// remember records e as the light's current state.
+func (l *Light) remember(e logitech.Event) {
+	l.state.Remember(l.ID, e)
+}
+
+// recall returns the light's last known value of a setting.
+func (l *Light) recall(target logitech.LightTarget, kind logitech.EventKind) (logitech.Event, bool) {
+	return l.state.Recall(l.ID, target, kind)
+}
+
+// rememberBackColors records colour commands for the back light, re-sent
+// when it is turned back on. It's called while building commands, and the
+// colours are only recorded once the write sending them succeeds.
+func (l *Light) rememberBackColors(commands [][]byte) {
+	l.newColors = commands
+}
+
+// recordBackColors records the colour commands sent to the back light.
+func (l *Light) recordBackColors(commands [][]byte) {
+	l.state.RememberBackColors(l.ID, commands, l.Model.ZoneColors(commands))
+}
+
+// backColors returns the colour commands last sent to the back light. Colours
+// loaded from the state file are rebuilt into commands.
+func (l *Light) backColors() [][]byte {
+	commands, colors := l.state.BackColors(l.ID)
+	if commands != nil || len(colors) == 0 || !l.Model.HasColor() {
+		return commands
+	}
+	commands, err := l.Model.Frame(logitech.BackLight, zoneFrame(colors))
+	if err != nil {
+		log.Printf("Unable to restore the back light colours of %s: %v", l.ID, err)
+		return nil
+	}
+	return commands
+}
+
+// LightInfo describes an attached light for the Property Inspector.
+type LightInfo struct {
+	ID       string `json:"id"`
+	Product  string `json:"product"`
+	Model    string `json:"model"`
+	Name     string `json:"name"`
+	Firmware string `json:"firmware"`
+	Serial   string `json:"serial"`
+	Path     string `json:"path"`
+}
+
+// DeviceManager maintains persistent HID connections to every attached Litra device.
+// All writes are serialized through a mutex to prevent overlapped I/O conflicts.
+type DeviceManager struct {
+	mu        sync.Mutex
+	transport transport.Transport
+	lights    map[string]*Light
+	groups    map[string][]string
+	lastScan  time.Time
+
+	// Lights that were unplugged, kept by ID so their state can be replayed
+	// when they come back.
+	detached map[string]*Light
+
+	// Delays between attempts; tests shorten them.
+	retryDelay    time.Duration
+	queryTimeout  time.Duration
+	readPoll      time.Duration
+	watchInterval time.Duration
+
+	// Changes the lights report on their own, and lights being plugged in or
+	// unplugged, passed to subscribers in order.
+	events          chan lightEvent
+	subMu           sync.Mutex
+	subscribers     []func(id string, e logitech.Event)
+	connSubscribers []func(id string, connected bool)
+	dispatch        sync.Once
+
+	queue     commandQueue // see Submit
+	fades     fades        // see SubmitFade
+	effects   animator     // see StartEffect
+	schedules followers    // see FollowSchedule
+	links     links        // see LinkBackToFront
+
+	// State is every light's last known state, including unplugged ones.
+	State *StateStore
+}
+
+// connectionChange marks a lightEvent that is about a light being plugged in
+// or unplugged rather than a change the light reported.
+type connectionChange int
+
+const (
+	noConnectionChange connectionChange = iota
+	lightAttached
+	lightDetached
+)
+
+// NewDeviceManager returns a DeviceManager that talks to lights through t.
+func NewDeviceManager(t transport.Transport) *DeviceManager {
+	return &DeviceManager{
+		transport:     t,
+		lights:        make(map[string]*Light),
+		groups:        make(map[string][]string),
+		detached:      make(map[string]*Light),
+		retryDelay:    retryDelay,
+		queryTimeout:  queryTimeout,
+		readPoll:      readPoll,
+		watchInterval: watchInterval,
+		events:        make(chan lightEvent, 64),
+		State:         NewStateStore(),
+	}
+}
+
+var deviceMgr = NewDeviceManager(transport.HID{})
+
+func lightID(info transport.DeviceInfo) string {
+	if info.SerialNbr != "" {
+		return info.SerialNbr
+	}
+	return info.Path
+}
+
+// scan enumerates the Litra devices, opens any new ones and sets aside the
+// ones that were unplugged. A light that comes back is put into its last known
+// state. Must be called with mu held.
+func (dm *DeviceManager) scan() error {
+	dm.lastScan = time.Now()
+
+	if err := dm.transport.Init(); err != nil {
+		return fmt.Errorf("hid.Init: %w", err)
+	}
+
+	infos, err := dm.transport.Enumerate(VID, 0)
+	if err != nil {
+		return fmt.Errorf("hid.Enumerate: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var errs []error
+	for _, info := range infos {
+		if info.UsagePage != UsagePage {
+			continue
+		}
+		model, ok := logitech.ModelByProductID(info.ProductID)
+		if !ok {
+			continue
+		}
+		id := lightID(info)
+		seen[id] = true
+
+		l, ok := dm.lights[id]
+		if !ok {
+			if l, ok = dm.detached[id]; ok {
+				delete(dm.detached, id)
+			} else {
+				l = &Light{ID: id, state: dm.State}
+			}
+			dm.lights[id] = l
+		}
+		if l.device != nil && l.Info.Path == info.Path && !l.reader.failed() {
+			continue
+		}
+		dm.closeLight(l)
+		l.Info = info
+		l.Model = model
+		l.Features = nil
+		l.DeviceInfo = logitech.DeviceInfo{}
+		l.discovered = false
+		if err := dm.open(l); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if l.unplugged {
+			l.unplugged = false
+			dm.replay(l)
+			dm.publish(lightEvent{id: id, connection: lightAttached})
+		}
+	}
+
+	for id, l := range dm.lights {
+		if !seen[id] {
+			dm.closeLight(l)
+			delete(dm.lights, id)
+			l.unplugged = true
+			dm.detached[id] = l
+			log.Printf("HID device disconnected: %s", id)
+			dm.publish(lightEvent{id: id, connection: lightDetached})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// replay puts a light that was plugged back in into the state the plugin last
+// knew, since the light comes back in its default state. Must be called with mu held.
+func (dm *DeviceManager) replay(l *Light) {
+	var commands [][]byte
+	backOn := false
+	for _, target := range l.Model.Targets() {
+		// Power last, so the light doesn't flash at its default brightness.
+		for _, kind := range []logitech.EventKind{logitech.BrightnessChanged, logitech.TemperatureChanged, logitech.PowerChanged} {
+			e, ok := l.recall(target, kind)
+			if !ok {
+				continue
+			}
+			cmd, err := l.Model.Restore(e)
+			if err != nil {
+				log.Printf("Unable to restore the %s %s of %s: %v", target, kind, l.ID, err)
+				continue
+			}
+			commands = append(commands, cmd)
+			if target == logitech.BackLight && kind == logitech.PowerChanged {
+				backOn = e.On
+			}
+		}
+	}
+	if backOn {
+		commands = append(commands, l.backColors()...)
+	}
+	if len(commands) == 0 {
+		return
+	}
+
+	log.Printf("Restoring the last known state of %s", l.ID)
+	if err := dm.write(context.Background(), l, commands); err != nil {
+		log.Printf("Unable to restore the state of %s: %v", l.ID, err)
+	}
+}
+
+// Restore puts the lights with the given IDs into their state in State once
+// they are found, as if they had been unplugged. It's for state loaded when
+// the plugin starts, and must be called before the first scan.
+func (dm *DeviceManager) Restore(ids ...string) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	for _, id := range ids {
+		if _, ok := dm.lights[id]; !ok {
+			dm.detached[id] = &Light{ID: id, state: dm.State, unplugged: true}
+		}
+	}
+}
+
+// Watch rescans for lights in the background until ctx is done, so lights
+// being plugged in or unplugged are noticed without waiting for an action.
+func (dm *DeviceManager) Watch(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(dm.watchInterval)
+		defer ticker.Stop()
+
+		var lastErr string
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			dm.mu.Lock()
+			err := dm.scan()
+			dm.mu.Unlock()
+
+			// A light that can't be opened fails every scan; say so once.
+			msg := ""
+			if err != nil {
+				msg = err.Error()
+			}
+			if msg != "" && msg != lastErr {
+				log.Printf("Scan failed: %v", err)
+			}
+			lastErr = msg
+		}
+	}()
+}
+
+// open opens a known light, discovering its features the first time.
+// Must be called with mu held.
+func (dm *DeviceManager) open(l *Light) error {
+	d, err := dm.transport.Open(l.Info.Path)
+	if err != nil {
+		return fmt.Errorf("hid.OpenPath(%s): %w", l.Info.Path, err)
+	}
+	l.device = d
+	l.reader = startReader(l, dm.readPoll, dm.events)
+	log.Printf("HID device connected: %s %s (%s)", l.Model.Name, l.ID, l.Info.Path)
+
+	if !l.discovered {
+		l.discovered = true
+		if err := dm.discover(l); err != nil && l.device == nil {
+			// The connection broke rather than the light not answering: try again on reopen.
+			l.discovered = false
+			return fmt.Errorf("feature discovery on %s: %w", l.ID, err)
+		}
+	}
+	return nil
+}
+
+// discover reads the light's feature table through IRoot and IFeatureSet and
+// points its model at the reported indices. If the light doesn't answer, the
+// model table's defaults stay in use. Must be called with mu held.
+func (dm *DeviceManager) discover(l *Light) error {
+	features, err := dm.readFeatures(l)
+	if err != nil {
+		log.Printf("Feature discovery failed for %s, using the %s defaults: %v", l.ID, l.Model.Name, err)
+		return err
+	}
+	l.Features = features
+	l.Model = l.Model.WithFeatures(features)
+	l.reader.setModel(l.Model)
+	log.Printf("Features of %s: front 0x%02x, back 0x%02x, colour 0x%02x",
+		l.ID, l.Model.FrontIndex, l.Model.BackIndex, l.Model.BackColorIndex)
+
+	l.DeviceInfo = dm.readDeviceInfo(l)
+	log.Printf("Device info of %s: %s", l.ID, l.DeviceInfo)
+	return nil
+}
+
+// readDeviceInfo asks the light for its name, firmware version and serial
+// number. Whatever the light doesn't answer is left empty, except the serial,
+// which falls back to the USB one. Must be called with mu held.
+func (dm *DeviceManager) readDeviceInfo(l *Light) logitech.DeviceInfo {
+	info := logitech.DeviceInfo{Serial: l.Info.SerialNbr}
+
+	if index, ok := l.Features[logitech.FeatureDeviceInformation]; ok {
+		firmware, serial, err := dm.readFirmware(l, index)
+		if err != nil {
+			log.Printf("Unable to read device information from %s: %v", l.ID, err)
+		}
+		info.Firmware = firmware
+		if serial != "" {
+			info.Serial = serial
+		}
+	}
+
+	if index, ok := l.Features[logitech.FeatureDeviceName]; ok {
+		name, err := dm.readName(l, index)
+		if err != nil {
+			log.Printf("Unable to read the name of %s: %v", l.ID, err)
+		}
+		info.Name = name
+	}
+	if info.Name == "" {
+		info.Name = l.Model.Name
+	}
+
+	return info
+}
+
+// readFirmware reads the main firmware version and, when the light supports
+// it, the serial number. Must be called with mu held.
+func (dm *DeviceManager) readFirmware(l *Light, index byte) (firmware, serial string, err error) {
+	r, err := dm.query(l, logitech.GetDeviceInfo(index))
+	if err != nil {
+		return "", "", err
+	}
+	entities, hasSerial, err := logitech.DecodeDeviceInfo(r)
+	if err != nil {
+		return "", "", err
+	}
+
+	for entity := 0; entity < entities && firmware == ""; entity++ {
+		if r, err = dm.query(l, logitech.GetFirmwareInfo(index, byte(entity))); err != nil {
+			return "", "", err
+		}
+		version, application, err := logitech.DecodeFirmwareInfo(r)
+		if err != nil {
+			return "", "", err
+		}
+		if application {
+			firmware = version
+		}
+	}
+
+	if hasSerial {
+		if r, err = dm.query(l, logitech.GetSerialNumber(index)); err != nil {
+			return firmware, "", err
+		}
+		if serial, err = logitech.DecodeSerialNumber(r); err != nil {
+			return firmware, "", err
+		}
+	}
+	return firmware, serial, nil
+}
+
+// readName reads the light's name, which may take several reports. Must be called with mu held.
+func (dm *DeviceManager) readName(l *Light, index byte) (string, error) {
+	r, err := dm.query(l, logitech.GetNameLength(index))
+	if err != nil {
+		return "", err
+	}
+	length, err := logitech.DecodeNameLength(r)
+	if err != nil {
+		return "", err
+	}
+
+	var name strings.Builder
+	for name.Len() < length {
+		r, err := dm.query(l, logitech.GetNamePart(index, byte(name.Len())))
+		if err != nil {
+			return "", err
+		}
+		part := logitech.DecodeNamePart(r)
+		if part == "" {
+			break
+		}
+		name.WriteString(part)
+	}
+	return strings.TrimSpace(name.String()[:min(name.Len(), length)]), nil
+}
+
+// readFeatures lists every feature the light has. Must be called with mu held.
+func (dm *DeviceManager) readFeatures(l *Light) (logitech.Features, error) {
+	r, err := dm.query(l, logitech.GetFeatureIndex(logitech.FeatureSet))
+	if err != nil {
+		return nil, err
+	}
+	setIndex, err := logitech.DecodeFeatureIndex(r)
+	if err != nil {
+		return nil, fmt.Errorf("IFeatureSet: %w", err)
+	}
+
+	if r, err = dm.query(l, logitech.GetFeatureCount(setIndex)); err != nil {
+		return nil, err
+	}
+	count, err := logitech.DecodeFeatureCount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	features := logitech.Features{logitech.FeatureRoot: 0}
+	for i := 1; i <= count; i++ {
+		if r, err = dm.query(l, logitech.GetFeatureID(setIndex, byte(i))); err != nil {
+			return nil, err
+		}
+		id, err := logitech.DecodeFeatureID(r)
+		if err != nil {
+			return nil, err
+		}
+		features[id] = byte(i)
+	}
+	return features, nil
+}
+
+// closeLight closes the handle to a light, keeping it known. Must be called with mu held.
+func (dm *DeviceManager) closeLight(l *Light) {
+	if l.reader != nil {
+		l.reader.close()
+		l.reader = nil
+	}
+	if l.device != nil {
+		l.device.Close()
+		l.device = nil
+	}
+}
+
+// resolve returns the attached lights selected by target, scanning for
+// devices when needed. A light whose connection failed is still returned, for
+// write or query to reopen on its own. Must be called with mu held.
+func (dm *DeviceManager) resolve(target string) ([]*Light, error) {
+	pick := func() []*Light {
+		var ids []string
+		switch {
+		case target == AllLights:
+			for id := range dm.lights {
+				ids = append(ids, id)
+			}
+		case strings.HasPrefix(target, groupPrefix):
+			ids = append(ids, dm.groups[strings.TrimPrefix(target, groupPrefix)]...)
+		default:
+			ids = []string{target}
+		}
+		sort.Strings(ids)
+
+		var selected []*Light
+		for _, id := range ids {
+			if l, ok := dm.lights[id]; ok {
+				selected = append(selected, l)
+			}
+		}
+		return selected
+	}
+
+	lights := pick()
+	if len(lights) == 0 || time.Since(dm.lastScan) > scanInterval {
+		err := dm.scan()
+		lights = pick()
+		if len(lights) == 0 {
+			if err != nil {
+				return nil, err
+			}
+			if target == AllLights {
+				return nil, fmt.Errorf("no Litra device found (VID=0x%04x UsagePage=0x%04x)", VID, UsagePage)
+			}
+			return nil, fmt.Errorf("light %q is not connected", target)
+		}
+	}
+	return lights, nil
+}
+
+// pause waits without holding mu, so the watcher, the lights' events and
+// writes to other lights aren't held up. It gives up once ctx is done. Must
+// be called with mu held.
+func (dm *DeviceManager) pause(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dm.mu.Unlock()
+	defer dm.mu.Lock()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Close shuts down every device connection.
+func (dm *DeviceManager) Close() {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	for _, l := range dm.lights {
+		dm.closeLight(l)
+	}
+	dm.transport.Exit()
+}
+
+// Lights rescans and lists the attached lights, ordered by ID.
+func (dm *DeviceManager) Lights() []LightInfo {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	if err := dm.scan(); err != nil {
+		log.Printf("Scan failed: %v", err)
+	}
+
+	infos := make([]LightInfo, 0, len(dm.lights))
+	for _, l := range dm.lights {
+		infos = append(infos, LightInfo{
+			ID:       l.ID,
+			Product:  l.Info.ProductStr,
+			Model:    l.Model.Name,
+			Name:     l.DeviceInfo.Name,
+			Firmware: l.DeviceInfo.Firmware,
+			Serial:   cmp.Or(l.DeviceInfo.Serial, l.Info.SerialNbr),
+			Path:     l.Info.Path,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// SetGroups replaces the named light groups. Each group lists light IDs.
+func (dm *DeviceManager) SetGroups(groups map[string][]string) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	dm.groups = make(map[string][]string, len(groups))
+	for name, ids := range groups {
+		dm.groups[name] = append([]string(nil), ids...)
+	}
+}
+
+// WriteCommands sends one or more byte sequences to every attached light.
+func (dm *DeviceManager) WriteCommands(commands ...[]byte) error {
+	return dm.WriteTo(AllLights, commands...)
+}
+
+// WriteTo sends one or more byte sequences to the lights selected by target:
+// a light ID, "group:<name>", or AllLights.
+func (dm *DeviceManager) WriteTo(target string, commands ...[]byte) error {
+	return dm.Apply(target, func(*Light) ([][]byte, error) {
+		return commands, nil
+	})
+}
+
+// Apply builds the commands for each light selected by target and writes them,
+// with retry on failure. A failure on one light doesn't stop the others.
+//
+// When several lights are selected, the ones whose model doesn't support the
+// commands (build returns logitech.ErrUnsupported) are skipped, so "front light
+// on" for a group still works when it mixes a Glow and a Beam LX. The
+// unsupported error is only returned when no light could take the commands.
+func (dm *DeviceManager) Apply(target string, build func(l *Light) ([][]byte, error)) error {
+	return dm.ApplyContext(context.Background(), target, build)
+}
+
+// ApplyContext is Apply, giving up retrying once ctx is done.
+func (dm *DeviceManager) ApplyContext(ctx context.Context, target string, build func(l *Light) ([][]byte, error)) error {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	// An unknown group is a mistake in the key's settings, not a light to look for.
+	if name, ok := strings.CutPrefix(target, groupPrefix); ok {
+		if _, known := dm.groups[name]; !known {
+			return fmt.Errorf("unknown light group %q", name)
+		}
+	}
+
+	// A light that isn't there is reported right away: the lights that are
+	// keep their connections, and write reopens only a light that fails.
+	lights, err := dm.resolve(target)
+	if err != nil {
+		return err
+	}
+
+	var errs, unsupported []error
+	for _, l := range lights {
+		l.newColors = nil
+		commands, err := build(l)
+		if errors.Is(err, logitech.ErrUnsupported) {
+			log.Printf("Skipping %s: %v", l.ID, err)
+			unsupported = append(unsupported, fmt.Errorf("%s: %w", l.ID, err))
+			continue
+		}
+		if err == nil {
+			err = dm.write(ctx, l, commands)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", l.ID, err))
+		}
+	}
+	if len(unsupported) == len(lights) {
+		return errors.Join(unsupported...)
+	}
+	return errors.Join(errs...)
+}
+
+// write sends commands to one light, reopening it between attempts until ctx
+// is done. Between attempts it lets go of mu. Must be called with mu held.
+func (dm *DeviceManager) write(ctx context.Context, l *Light, commands [][]byte) error {
+	colors := l.newColors
+	l.newColors = nil
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if l.device == nil {
+			if err := dm.open(l); err != nil {
+				log.Printf("Reopen failed (attempt %d/%d): %v", attempt+1, maxRetries+1, err)
+				if attempt == maxRetries {
+					return err
+				}
+				if ctxErr := dm.pause(ctx, dm.retryDelay); ctxErr != nil {
+					return errors.Join(err, ctxErr)
+				}
+				continue
+			}
+		}
+
+		var writeErr error
+		var failed []byte
+		for _, cmd := range commands {
+			if _, err := dm.send(l, cmd); err != nil {
+				writeErr, failed = err, cmd
+				break
+			}
+			if e, ok := l.Model.DecodeSetting(cmd); ok {
+				l.remember(e)
+			}
+		}
+
+		if writeErr == nil {
+			if colors != nil {
+				l.recordBackColors(colors)
+			}
+			dm.followFront(ctx, l, commands)
+			return nil // success
+		}
+
+		// The light got the command and refused it; sending it again won't help.
+		var deviceErr *logitech.DeviceError
+		if errors.As(writeErr, &deviceErr) {
+			log.Printf("%s refused %s: %v", l.ID, l.Model.Describe(failed), writeErr)
+			return writeErr
+		}
+
+		log.Printf("Write to %s failed (attempt %d/%d): %v", l.ID, attempt+1, maxRetries+1, writeErr)
+		if attempt == maxRetries {
+			return writeErr
+		}
+		dm.closeLight(l)
+		if err := dm.pause(ctx, dm.retryDelay); err != nil {
+			return errors.Join(writeErr, err)
+		}
+	}
+
+	return fmt.Errorf("all %d write attempts failed", maxRetries+1)
+}
+
+// LightState is what a light reports about its front or back light.
+type LightState struct {
+	On          bool
+	Brightness  uint8  // 1-100%
+	Temperature uint16 // Kelvin, front light only
+
+	// Colors holds the back light zones as last written by the plugin, since
+	// the device can't report them. Nil for the front light or before any
+	// colour was set.
+	Colors []color.RGBA
+}
+
+// ReadState asks a light selected by target for the state of its front or
+// back light. For a group or AllLights, the first light in ID order that has
+// the requested light answers.
+func (dm *DeviceManager) ReadState(target string, which logitech.LightTarget) (LightState, error) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+
+	lights, err := dm.resolve(target)
+	if err != nil {
+		return LightState{}, err
+	}
+	l := lights[0]
+	for _, candidate := range lights {
+		if candidate.Model.Supports(which) {
+			l = candidate
+			break
+		}
+	}
+
+	get := func(build func(logitech.LightTarget) ([]byte, error)) (logitech.Response, error) {
+		request, err := build(which)
+		if err != nil {
+			return logitech.Response{}, err
+		}
+		return dm.query(l, request)
+	}
+
+	state := LightState{}
+	r, err := get(l.Model.GetPower)
+	if err != nil {
+		return state, err
+	}
+	if state.On, err = l.Model.DecodePower(r); err != nil {
+		return state, err
+	}
+
+	if r, err = get(l.Model.GetBrightness); err != nil {
+		return state, err
+	}
+	if state.Brightness, err = l.Model.DecodeBrightness(which, r); err != nil {
+		return state, err
+	}
+
+	if which == logitech.FrontLight {
+		if r, err = get(l.Model.GetTemperature); err != nil {
+			return state, err
+		}
+		if state.Temperature, err = l.Model.DecodeTemperature(r); err != nil {
+			return state, err
+		}
+	} else if colors := l.backColors(); len(colors) > 0 {
+		state.Colors = l.Model.ZoneColors(colors)
+	}
+
+	l.remember(logitech.Event{Target: which, Kind: logitech.PowerChanged, On: state.On})
+	l.remember(logitech.Event{Target: which, Kind: logitech.BrightnessChanged, Brightness: state.Brightness})
+	if which == logitech.FrontLight {
+		l.remember(logitech.Event{Target: which, Kind: logitech.TemperatureChanged, Temperature: state.Temperature})
+	}
+	return state, nil
+}
+
+// KnownState returns what the plugin knows of the front or back light of a
+// light selected by target, without asking the light. For a group or
+// AllLights, the first attached light in ID order that has the requested
+// light answers, like ReadState. It reports false when nothing is known.
+func (dm *DeviceManager) KnownState(target string, which logitech.LightTarget) (LightState, bool) {
+	dm.mu.Lock()
+	var ids []string
+	for id, l := range dm.lights {
+		if dm.selects(target, id) && l.Model.Supports(which) {
+			ids = append(ids, id)
+		}
+	}
+	dm.mu.Unlock()
+	if len(ids) == 0 {
+		return LightState{}, false
+	}
+	return dm.State.State(slices.Min(ids), which)
+}
+
+// query sends a get request to one light and waits for its answer. Must be
+// called with mu held.
+func (dm *DeviceManager) query(l *Light, request []byte) (logitech.Response, error) {
+	if l.device == nil {
+		if err := dm.open(l); err != nil {
+			return logitech.Response{}, err
+		}
+	}
+	return dm.send(l, request)
+}
+
+// send writes one report to an open light and waits for the light to answer
+// it, which for a set command acknowledges it. A refusal is returned as a
+// *logitech.DeviceError. Reports that aren't HID++ requests aren't answered,
+// so send only writes them. Must be called with mu held.
+func (dm *DeviceManager) send(l *Light, report []byte) (logitech.Response, error) {
+	if !logitech.ExpectsAnswer(report) {
+		_, err := l.device.Write(report)
+		return logitech.Response{}, err
+	}
+
+	answer := l.reader.expect(report)
+	if _, err := l.device.Write(report); err != nil {
+		l.reader.cancel()
+		dm.closeLight(l)
+		return logitech.Response{}, fmt.Errorf("%s: %w", l.ID, err)
+	}
+
+	select {
+	case res := <-answer:
+		if res.broken {
+			dm.closeLight(l)
+			return logitech.Response{}, fmt.Errorf("%s: %w", l.ID, res.err)
+		}
+		return res.r, res.err
+	case <-time.After(dm.queryTimeout):
+		l.reader.cancel()
+		return logitech.Response{}, fmt.Errorf("%s: no answer: %w", l.ID, transport.ErrTimeout)
+	}
+}
+
+// Subscribe calls fn for every change a light reports on its own, such as a
+// press of its buttons. Calls happen one at a time, in the order the changes
+// arrived, on a goroutine of their own.
+func (dm *DeviceManager) Subscribe(fn func(id string, e logitech.Event)) {
+	dm.subMu.Lock()
+	dm.subscribers = append(dm.subscribers, fn)
+	dm.subMu.Unlock()
+	dm.startDispatch()
+}
+
+// SubscribeConnections calls fn when a light is unplugged, and when it is
+// plugged back in and its state was replayed. Calls are made like Subscribe's.
+func (dm *DeviceManager) SubscribeConnections(fn func(id string, connected bool)) {
+	dm.subMu.Lock()
+	dm.connSubscribers = append(dm.connSubscribers, fn)
+	dm.subMu.Unlock()
+	dm.startDispatch()
+}
+
+// publish queues e for the subscribers without blocking.
+func (dm *DeviceManager) publish(e lightEvent) {
+	select {
+	case dm.events <- e:
+	default:
+		log.Printf("Dropped an event from %s: too many pending", e.id)
+	}
+}
+
+func (dm *DeviceManager) startDispatch() {
+	dm.dispatch.Do(func() {
+		go func() {
+			for e := range dm.events {
+				if e.connection == noConnectionChange {
+					dm.rememberEvent(e)
+					dm.followReportedFront(e.id, e.event)
+				}
+
+				dm.subMu.Lock()
+				subscribers := slices.Clone(dm.subscribers)
+				connSubscribers := slices.Clone(dm.connSubscribers)
+				dm.subMu.Unlock()
+
+				switch e.connection {
+				case noConnectionChange:
+					for _, fn := range subscribers {
+						fn(e.id, e.event)
+					}
+				case lightAttached, lightDetached:
+					for _, fn := range connSubscribers {
+						fn(e.id, e.connection == lightAttached)
+					}
+				}
+			}
+		}()
+	})
+}
+
+// rememberEvent records a change a light reported, so it is replayed too.
+func (dm *DeviceManager) rememberEvent(e lightEvent) {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	if l, ok := dm.lights[e.id]; ok {
+		l.remember(e.event)
+	}
+}
+
+// Selects reports whether target selects the light with the given ID.
+func (dm *DeviceManager) Selects(target, id string) bool {
+	dm.mu.Lock()
+	defer dm.mu.Unlock()
+	return dm.selects(target, id)
+}
+
+// selects is Selects for callers holding mu.
+func (dm *DeviceManager) selects(target, id string) bool {
+	if target == AllLights || target == id {
+		return true
+	}
+	name, ok := strings.CutPrefix(target, groupPrefix)
+	if !ok {
+		return false
+	}
+	return slices.Contains(dm.groups[name], id)
+}
+
+// overlaps reports whether targets a and b select any light in common.
+func (dm *DeviceManager) overlaps(a, b string) bool {
+	if a == b || a == AllLights || b == AllLights {
+		return true
+	}
+
+	dm.mu.Lock()
+	ids := make([]string, 0, len(dm.lights)+len(dm.detached))
+	for id := range dm.lights {
+		ids = append(ids, id)
+	}
+	for id := range dm.detached {
+		ids = append(ids, id)
+	}
+	dm.mu.Unlock()
+
+	for _, id := range ids {
+		if dm.Selects(a, id) && dm.Selects(b, id) {
+			return true
+		}
+	}
+	return false
+}
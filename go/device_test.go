@@ -0,0 +1,812 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/michaelabon/streamdeck-logitech-litra/internal/transport"
+)
+
+const testPath = "/dev/hidraw-litra"
+
+var testLight = transport.DeviceInfo{
+	Path:      testPath,
+	VendorID:  VID,
+	ProductID: logitech.LitraBeamLX.ProductID,
+	SerialNbr: "2403FE0001",
+	UsagePage: UsagePage,
+}
+
+// newTestManager returns a DeviceManager backed by a fake with no retry delays.
+// The fake lights answer like real ones (see emulateLitras), and the feature
+// discovery traffic is left out of the recorded reports.
+func newTestManager(devices ...transport.DeviceInfo) (*DeviceManager, *transport.Fake) {
+	fake := transport.NewFake(devices...)
+	fake.Respond(emulateLitras)
+	fake.Ignore(isDiscovery)
+	dm := NewDeviceManager(fake)
+	dm.retryDelay = 0
+	dm.queryTimeout = 50 * time.Millisecond
+	dm.readPoll = 5 * time.Millisecond
+	return dm, fake
+}
+
+func TestWriteCommandsRecordsReports(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+
+	a := []byte{0x11, 0xff, 0x06, 0x1c, 0x01}
+	b := []byte{0x11, 0xff, 0x06, 0x1c, 0x00}
+	if err := dm.WriteCommands(a, b); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.WriteCommands(a); err != nil {
+		t.Fatal(err)
+	}
+
+	written := fake.Written(testPath)
+	if len(written) != 3 {
+		t.Fatalf("Expected 3 reports, got %d", len(written))
+	}
+	if !bytes.Equal(written[1], b) {
+		t.Errorf("Expected second report % x, got % x", b, written[1])
+	}
+	if fake.Opens() != 1 {
+		t.Errorf("Expected the connection to be reused, opened %d times", fake.Opens())
+	}
+}
+
+func TestWriteCommandsIgnoresOtherUsagePages(t *testing.T) {
+	other := testLight
+	other.Path = "/dev/hidraw-keyboard"
+	other.UsagePage = 0x0001
+	dm, fake := newTestManager(testLight, other)
+
+	if err := dm.WriteCommands([]byte{0x11}); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Written(other.Path)) != 0 {
+		t.Errorf("Wrote to an interface with the wrong usage page")
+	}
+	if len(fake.Written(testPath)) != 1 {
+		t.Errorf("Expected one report on the Litra interface")
+	}
+}
+
+func TestWriteCommandsReconnectsAfterWriteFailure(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	fake.FailWrites(1, errors.New("overlapped I/O"))
+
+	cmd := []byte{0x11, 0xff, 0x06, 0x1c, 0x01}
+	if err := dm.WriteCommands(cmd); err != nil {
+		t.Fatalf("Expected retry to succeed, got %v", err)
+	}
+	if fake.Opens() != 2 {
+		t.Errorf("Expected a reconnect after the failed write, opened %d times", fake.Opens())
+	}
+	if written := fake.Written(testPath); len(written) != 1 || !bytes.Equal(written[0], cmd) {
+		t.Errorf("Expected the command to be written once, got %v", written)
+	}
+}
+
+func TestWriteCommandsRewritesWholeBatchOnRetry(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+
+	// First attempt: the first report goes through, the second fails.
+	if err := dm.WriteCommands([]byte{0x01}); err != nil {
+		t.Fatal(err)
+	}
+	fake.Reset()
+	fake.FailWrites(1, errors.New("pipe"))
+
+	if err := dm.WriteCommands([]byte{0x02}, []byte{0x03}); err != nil {
+		t.Fatal(err)
+	}
+	written := fake.Written(testPath)
+	if len(written) != 2 || written[0][0] != 0x02 || written[1][0] != 0x03 {
+		t.Errorf("Expected the whole batch after reconnect, got %v", written)
+	}
+}
+
+func TestWriteCommandsGivesUpAfterMaxRetries(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	if err := dm.WriteTo(AllLights); err != nil {
+		t.Fatal(err)
+	}
+	writeErr := errors.New("device unplugged")
+	fake.FailWrites(maxRetries+1, writeErr)
+
+	err := dm.WriteCommands([]byte{0x11})
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("Expected %v, got %v", writeErr, err)
+	}
+	if fake.Opens() != maxRetries+1 {
+		t.Errorf("Expected %d connections, got %d", maxRetries+1, fake.Opens())
+	}
+}
+
+func TestWriteWaitsBetweenAttemptsWithoutTheLock(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.retryDelay = time.Hour
+	if err := dm.WriteTo(AllLights); err != nil {
+		t.Fatal(err)
+	}
+	fake.FailWrites(1, errors.New("stalled"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	built := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- dm.ApplyContext(ctx, AllLights, func(*Light) ([][]byte, error) {
+			close(built)
+			return [][]byte{{0x11}}, nil
+		})
+	}()
+	<-built
+
+	// The lights can still be listed while the write waits to try again.
+	listed := make(chan struct{})
+	go func() {
+		dm.Lights()
+		close(listed)
+	}()
+	select {
+	case <-listed:
+	case <-time.After(testTimeout):
+		t.Fatal("Expected the lock released while the write waits")
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the wait to be cancelled, got %v", err)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("Expected the write to stop waiting once cancelled")
+	}
+}
+
+func TestWriteCommandsWithoutDevice(t *testing.T) {
+	dm, fake := newTestManager()
+
+	if err := dm.WriteCommands([]byte{0x11}); err == nil {
+		t.Fatal("Expected an error with no device attached")
+	}
+
+	// Plugging the light in makes the next call succeed.
+	fake.Attach(testLight)
+	if err := dm.WriteCommands([]byte{0x11}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteCommandsOpenFailure(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	fake.FailOpen(errors.New("access denied"))
+
+	if err := dm.WriteCommands([]byte{0x11}); err == nil {
+		t.Fatal("Expected an error when the device cannot be opened")
+	}
+	if len(fake.Reports()) != 0 {
+		t.Errorf("Expected no reports, got %d", len(fake.Reports()))
+	}
+}
+
+func TestCloseReopensOnNextWrite(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+
+	if err := dm.WriteCommands([]byte{0x11}); err != nil {
+		t.Fatal(err)
+	}
+	dm.Close()
+	if err := dm.WriteCommands([]byte{0x11}); err != nil {
+		t.Fatal(err)
+	}
+	if fake.Opens() != 2 {
+		t.Errorf("Expected a fresh connection after Close, opened %d times", fake.Opens())
+	}
+}
+
+func TestWriteToTargetsLights(t *testing.T) {
+	second := testLight
+	second.Path = "/dev/hidraw-litra-2"
+	second.SerialNbr = "2403FE0002"
+	noSerial := testLight
+	noSerial.Path = "/dev/hidraw-litra-3"
+	noSerial.SerialNbr = ""
+	dm, fake := newTestManager(testLight, second, noSerial)
+	dm.SetGroups(map[string][]string{"desk": {testLight.SerialNbr, noSerial.Path}})
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{AllLights, []string{testLight.Path, second.Path, noSerial.Path}},
+		{second.SerialNbr, []string{second.Path}},
+		{noSerial.Path, []string{noSerial.Path}},
+		{"group:desk", []string{testLight.Path, noSerial.Path}},
+	}
+
+	for _, test := range tests {
+		fake.Reset()
+		if err := dm.WriteTo(test.target, []byte{0x11}); err != nil {
+			t.Fatalf("For target %q: %v", test.target, err)
+		}
+		reports := fake.Reports()
+		if len(reports) != len(test.want) {
+			t.Fatalf("For target %q, expected %d reports, got %d", test.target, len(test.want), len(reports))
+		}
+		for _, path := range test.want {
+			if len(fake.Written(path)) != 1 {
+				t.Errorf("For target %q, expected one report on %s", test.target, path)
+			}
+		}
+	}
+}
+
+func TestWriteToUnknownTarget(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	if err := dm.WriteTo(AllLights); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dm.WriteTo("group:nope", []byte{0x11}); err == nil {
+		t.Error("Expected an error for an unknown group")
+	}
+	if err := dm.WriteTo("NOT-A-SERIAL", []byte{0x11}); err == nil {
+		t.Error("Expected an error for a light that isn't attached")
+	}
+	if len(fake.Reports()) != 0 {
+		t.Errorf("Expected no reports, got %d", len(fake.Reports()))
+	}
+	// A missing light leaves the connected ones open.
+	if fake.Opens() != 1 {
+		t.Errorf("Expected the attached light kept open, opened %d times", fake.Opens())
+	}
+}
+
+func TestWriteToKeepsGoingWhenOneLightFails(t *testing.T) {
+	second := testLight
+	second.Path = "/dev/hidraw-litra-2"
+	second.SerialNbr = "2403FE0002"
+	dm, fake := newTestManager(testLight, second)
+	if err := dm.WriteTo(AllLights); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first light in ID order fails every attempt; the second still gets the command.
+	fake.FailWrites(maxRetries+1, errors.New("stalled"))
+	err := dm.WriteTo(AllLights, []byte{0x11})
+	if err == nil {
+		t.Fatal("Expected the failure to be reported")
+	}
+	if len(fake.Written(testLight.Path)) != 0 || len(fake.Written(second.Path)) != 1 {
+		t.Errorf("Expected only the second light to receive the command")
+	}
+}
+
+func TestLightsFindsNewlyAttachedDevices(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	if got := dm.Lights(); len(got) != 1 || got[0].ID != testLight.SerialNbr {
+		t.Fatalf("Expected the first light, got %v", got)
+	}
+
+	second := testLight
+	second.Path = "/dev/hidraw-litra-2"
+	second.SerialNbr = "2403FE0002"
+	fake.Attach(second)
+	fake.Detach(testLight.Path)
+
+	got := dm.Lights()
+	if len(got) != 1 || got[0].ID != second.SerialNbr {
+		t.Errorf("Expected only the second light, got %v", got)
+	}
+}
+
+func TestScanIdentifiesModels(t *testing.T) {
+	glow := testLight
+	glow.Path = "/dev/hidraw-glow"
+	glow.SerialNbr = "2201FE0001"
+	glow.ProductID = logitech.LitraGlow.ProductID
+	mouse := testLight
+	mouse.Path = "/dev/hidraw-mouse"
+	mouse.SerialNbr = "MOUSE"
+	mouse.ProductID = 0xc52b
+	dm, _ := newTestManager(testLight, glow, mouse)
+
+	got := dm.Lights()
+	if len(got) != 2 {
+		t.Fatalf("Expected the two Litras, got %v", got)
+	}
+	if got[0].Model != "Litra Glow" || got[1].Model != "Litra Beam LX" {
+		t.Errorf("Unexpected models %q and %q", got[0].Model, got[1].Model)
+	}
+}
+
+func TestApplyReportsUnsupportedOnlyWhenNoLightCouldTakeIt(t *testing.T) {
+	glow := testLight
+	glow.ProductID = logitech.LitraGlow.ProductID
+	dm, _ := newTestManager(glow)
+
+	err := dm.Apply(AllLights, func(l *Light) ([][]byte, error) {
+		cmd, err := l.Model.LightsOn(logitech.BackLight)
+		return [][]byte{cmd}, err
+	})
+	if !errors.Is(err, logitech.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+// litraFeatures lists the feature ID at each index, as each model's firmware
+// reports them. The IDs not named in logitech_hid are features we don't use.
+var litraFeatures = map[uint16][]uint16{
+	0xc900: {0x0000, 0x0001, 0x0003, 0x0005, 0x1990},
+	0xc901: {0x0000, 0x0001, 0x0003, 0x0005, 0x1990},
+	0xc903: {
+		0x0000, 0x0001, 0x0003, 0x0005, 0x00c2, 0x1802,
+		0x1990, 0x1803, 0x1807, 0x18a1, 0x8040, 0x1c04, 0x8081,
+	},
+}
+
+// litraState is what the emulated lights answer to get requests: the front
+// light on at 4000K and 50%, and the back light off at 40%.
+var litraState = map[byte][]byte{
+	0x0c: {0x01},       // front power
+	0x3c: {0x00, 0x85}, // front brightness, 133 lm on a Beam LX
+	0x8c: {0x0f, 0xa0}, // temperature
+	0x3b: {0x00},       // back power
+	0x1b: {0x00, 0x28}, // back brightness
+}
+
+// testFirmware is the application firmware the emulated lights run.
+const testFirmware = "RQM 64.02_B0006"
+
+// emulateLitras answers IRoot, IFeatureSet, device information and get
+// requests like a Litra of the device's product ID would, acknowledges set
+// commands by echoing them, and refuses requests for features it doesn't have.
+func emulateLitras(info transport.DeviceInfo, report []byte) [][]byte {
+	if !logitech.ExpectsAnswer(report) {
+		return nil
+	}
+	features := litraFeatures[info.ProductID]
+	model, _ := logitech.ModelByProductID(info.ProductID)
+	if int(report[2]) >= len(features) {
+		return [][]byte{hidError(report, logitech.ErrInvalidFeatureIndex)}
+	}
+	report = append(report[:len(report):len(report)], make([]byte, 20)...)[:20]
+	answer := make([]byte, 20)
+	copy(answer, report[:4])
+
+	switch {
+	case report[2] == 0x00 && report[3] == 0x0d: // IRoot getFeature
+		id := uint16(report[4])<<8 | uint16(report[5])
+		for i, f := range features {
+			if f == id {
+				answer[4] = byte(i)
+			}
+		}
+	case report[2] == 0x01 && report[3] == 0x0d: // IFeatureSet getCount
+		answer[4] = byte(len(features) - 1)
+	case report[2] == 0x01 && report[3] == 0x1d: // IFeatureSet getFeatureID
+		if i := int(report[4]); i < len(features) {
+			answer[4], answer[5] = byte(features[i]>>8), byte(features[i])
+		}
+	case report[2] == 0x02 && report[3] == 0x0d: // DeviceInformation getDeviceInfo
+		answer[4] = 2     // bootloader and application
+		answer[18] = 0x01 // serial number supported
+	case report[2] == 0x02 && report[3] == 0x1d: // DeviceInformation getFwInfo
+		if report[4] == 0 {
+			copy(answer[4:], []byte{0x01, 'B', 'O', 'T', 0x01, 0x00, 0x00, 0x01})
+		} else {
+			copy(answer[4:], []byte{0x00, 'R', 'Q', 'M', 0x64, 0x02, 0x00, 0x06})
+		}
+	case report[2] == 0x02 && report[3] == 0x2d: // DeviceInformation getSerialNumber
+		copy(answer[4:], info.SerialNbr)
+	case report[2] == 0x03 && report[3] == 0x0d: // DeviceName getDeviceNameCount
+		answer[4] = byte(len(model.Name))
+	case report[2] == 0x03 && report[3] == 0x1d: // DeviceName getDeviceName
+		if offset := int(report[4]); offset < len(model.Name) {
+			copy(answer[4:], model.Name[offset:])
+		}
+	default:
+		params, ok := litraState[report[3]]
+		if !ok {
+			params = report[4:] // acknowledge a set command
+		}
+		copy(answer[4:], params)
+	}
+	return [][]byte{answer}
+}
+
+// hidError is the HID++ error report refusing request with code.
+func hidError(request []byte, code logitech.ErrorCode) []byte {
+	answer := make([]byte, 20)
+	copy(answer, []byte{0x11, 0xff, 0xff, request[2], request[3], byte(code)})
+	return answer
+}
+
+// isDiscovery matches the requests sent when a light connects: IRoot,
+// IFeatureSet, DeviceInformation and DeviceName, at indices 0 to 3 on every model.
+func isDiscovery(report []byte) bool {
+	return len(report) > 2 && report[2] <= 0x03
+}
+
+func TestReadState(t *testing.T) {
+	dm, _ := newTestManager(testLight)
+
+	front, err := dm.ReadState(AllLights, logitech.FrontLight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (LightState{On: true, Brightness: 50, Temperature: 4000}); front.On != want.On ||
+		front.Brightness != want.Brightness || front.Temperature != want.Temperature {
+		t.Errorf("Expected %+v, got %+v", want, front)
+	}
+
+	back, err := dm.ReadState(AllLights, logitech.BackLight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.On || back.Brightness != 40 || back.Colors != nil {
+		t.Errorf("Unexpected back light state %+v", back)
+	}
+}
+
+func TestReadStateRemembersBackColors(t *testing.T) {
+	dm, _ := newTestManager(testLight)
+
+	err := dm.Apply(AllLights, func(l *Light) ([][]byte, error) {
+		commands, err := l.Model.Color(logitech.BackLight, 0, 128, 255)
+		l.rememberBackColors(commands)
+		return commands, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	back, err := dm.ReadState(testLight.SerialNbr, logitech.BackLight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := color.RGBA{R: 1, G: 128, B: 255, A: 0xff}
+	if len(back.Colors) != 7 || back.Colors[3] != want {
+		t.Errorf("Expected 7 zones of %v, got %v", want, back.Colors)
+	}
+}
+
+func TestReadStateSkipsUnrelatedReports(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.Lights() // open the light so reports can be queued
+	if err := fake.QueueRead(testPath, []byte{0x11, 0xff, 0x06, 0x10, 0x00, 0x50}); err != nil {
+		t.Fatal(err)
+	}
+
+	front, err := dm.ReadState(AllLights, logitech.FrontLight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !front.On || front.Brightness != 50 {
+		t.Errorf("Unexpected front light state %+v", front)
+	}
+}
+
+func TestReadStateWithoutAnswer(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	fake.Respond(nil)
+
+	if _, err := dm.ReadState(AllLights, logitech.FrontLight); !errors.Is(err, transport.ErrTimeout) {
+		t.Errorf("Expected a timeout, got %v", err)
+	}
+}
+
+func TestReadStateFindsLightWithBackLight(t *testing.T) {
+	glow := testLight
+	glow.Path = "/dev/hidraw-glow"
+	glow.SerialNbr = "0001" // sorts before the Beam LX
+	glow.ProductID = logitech.LitraGlow.ProductID
+	dm, fake := newTestManager(testLight, glow)
+
+	if _, err := dm.ReadState(AllLights, logitech.BackLight); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.Written(glow.Path)) != 0 {
+		t.Errorf("Expected the Glow not to be asked about a back light")
+	}
+	if _, err := dm.ReadState(glow.SerialNbr, logitech.BackLight); !errors.Is(err, logitech.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestConnectDiscoversFeatures(t *testing.T) {
+	// A firmware update moved the front light to index 0x07.
+	moved := append([]uint16(nil), litraFeatures[0xc903]...)
+	moved[6], moved[7] = moved[7], moved[6]
+	defer func(prev []uint16) { litraFeatures[0xc903] = prev }(litraFeatures[0xc903])
+	litraFeatures[0xc903] = moved
+
+	dm, fake := newTestManager(testLight)
+	if err := dm.Apply(AllLights, func(l *Light) ([][]byte, error) {
+		on, err := l.Model.LightsOn(logitech.FrontLight)
+		return [][]byte{on}, err
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	written := fake.Written(testPath)
+	if len(written) != 1 || written[0][2] != 0x07 {
+		t.Errorf("Expected front ON at index 0x07, got %v", written)
+	}
+	if got := dm.lights[testLight.SerialNbr].Features[logitech.FeaturePerKeyLighting]; got != 0x0c {
+		t.Errorf("Expected the colour feature at 0x0c, got 0x%02x", got)
+	}
+}
+
+func TestConnectDiscoversOnlyOnce(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	fake.Ignore(nil)
+
+	dm.Lights()
+	discovery := len(fake.Written(testPath))
+	if discovery == 0 {
+		t.Fatal("Expected the light to be asked for its features")
+	}
+
+	// Reconnecting after a failed write keeps the feature table.
+	fake.FailWrites(1, errors.New("overlapped I/O"))
+	if err := dm.WriteCommands([]byte{0x11}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(fake.Written(testPath)); got != discovery+1 {
+		t.Errorf("Expected only the command after reconnecting, got %d new reports", got-discovery)
+	}
+}
+
+func TestConnectKeepsDefaultsWithoutAnswer(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	fake.Respond(nil)
+
+	if err := dm.WriteCommands([]byte{0x11}); err != nil {
+		t.Fatal(err)
+	}
+	l := dm.lights[testLight.SerialNbr]
+	if l.Features != nil || l.Model != logitech.LitraBeamLX {
+		t.Errorf("Expected the Beam LX defaults, got %+v", l.Model)
+	}
+}
+
+func TestConnectReadsDeviceInfo(t *testing.T) {
+	noSerial := testLight
+	noSerial.Path = "/dev/hidraw-glow"
+	noSerial.SerialNbr = ""
+	noSerial.ProductID = logitech.LitraGlow.ProductID
+	dm, _ := newTestManager(testLight, noSerial)
+
+	got := dm.Lights()
+	if len(got) != 2 {
+		t.Fatalf("Expected two lights, got %v", got)
+	}
+	byPath := map[string]LightInfo{got[0].Path: got[0], got[1].Path: got[1]}
+
+	lx := byPath[testPath]
+	if lx.Name != "Litra Beam LX" || lx.Firmware != testFirmware || lx.Serial != testLight.SerialNbr {
+		t.Errorf("Unexpected Beam LX info %+v", lx)
+	}
+	glow := byPath[noSerial.Path]
+	if glow.Name != "Litra Glow" || glow.Firmware != testFirmware {
+		t.Errorf("Unexpected Glow info %+v", glow)
+	}
+}
+
+func TestConnectWithoutDeviceInfo(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	fake.Respond(nil)
+
+	got := dm.Lights()
+	if len(got) != 1 || got[0].Firmware != "" || got[0].Serial != testLight.SerialNbr {
+		t.Errorf("Expected only the USB details, got %+v", got)
+	}
+}
+
+func TestSubscribeReceivesLightEvents(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	events := make(chan logitech.Event, 1)
+	dm.Subscribe(func(id string, e logitech.Event) {
+		if id != testLight.SerialNbr {
+			t.Errorf("Expected an event from %s, got one from %s", testLight.SerialNbr, id)
+		}
+		events <- e
+	})
+
+	dm.Lights() // open the light so reports can be queued
+	if err := fake.QueueRead(testPath, []byte{0x11, 0xff, 0x0a, 0x10, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case e := <-events:
+		want := logitech.Event{Target: logitech.BackLight, Kind: logitech.PowerChanged, On: true}
+		if e != want {
+			t.Errorf("Expected %+v, got %+v", want, e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No event arrived")
+	}
+}
+
+func TestSelects(t *testing.T) {
+	dm, _ := newTestManager(testLight)
+	dm.SetGroups(map[string][]string{"desk": {testLight.SerialNbr}})
+
+	for target, want := range map[string]bool{
+		AllLights:               true,
+		testLight.SerialNbr:     true,
+		groupPrefix + "desk":    true,
+		groupPrefix + "shelf":   false,
+		"someone-else's-serial": false,
+	} {
+		if got := dm.Selects(target, testLight.SerialNbr); got != want {
+			t.Errorf("Selects(%q): expected %v, got %v", target, want, got)
+		}
+	}
+}
+
+func TestWatchReplaysStateWhenLightComesBack(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.watchInterval = 5 * time.Millisecond
+	connections := make(chan bool, 4)
+	dm.SubscribeConnections(func(id string, connected bool) {
+		connections <- connected
+	})
+	expect := func(want bool) {
+		t.Helper()
+		select {
+		case connected := <-connections:
+			if connected != want {
+				t.Fatalf("Expected connected=%v, got %v", want, connected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("No connection change to connected=%v", want)
+		}
+	}
+
+	m := logitech.LitraBeamLX
+	colors := mustAll(m.Color(logitech.BackLight, 0, 128, 255))
+	err := dm.Apply(AllLights, func(l *Light) ([][]byte, error) {
+		l.rememberBackColors(colors)
+		return append([][]byte{
+			mustBytes(m.LightsOn(logitech.FrontLight)),
+			mustBytes(m.Brightness(logitech.FrontLight, 70)),
+			mustBytes(m.Temperature(logitech.FrontLight, 3200)),
+			mustBytes(m.LightsOn(logitech.BackLight)),
+		}, colors...), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The light's own buttons change the temperature afterwards.
+	if err := fake.QueueRead(testPath, []byte{0x11, 0xff, 0x06, 0x20, 0x10, 0x68}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		e, _ := dm.State.Recall(testLight.SerialNbr, logitech.FrontLight, logitech.TemperatureChanged)
+		return e.Temperature == 4200
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dm.Watch(ctx)
+
+	fake.Detach(testPath)
+	expect(false)
+	if got := dm.Lights(); len(got) != 0 {
+		t.Errorf("Expected the unplugged light not to be listed, got %v", got)
+	}
+
+	fake.Reset()
+	fake.Attach(testLight)
+	expect(true)
+
+	assertReports(t, fake.Written(testPath), append([][]byte{
+		mustBytes(m.Brightness(logitech.FrontLight, 70)),
+		mustBytes(m.Temperature(logitech.FrontLight, 4200)),
+		mustBytes(m.LightsOn(logitech.FrontLight)),
+		mustBytes(m.LightsOn(logitech.BackLight)),
+	}, colors...)...)
+}
+
+func TestReplayLeavesColorsOffWhenBackLightWasOff(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.watchInterval = 5 * time.Millisecond
+	connections := make(chan bool, 4)
+	dm.SubscribeConnections(func(id string, connected bool) {
+		connections <- connected
+	})
+
+	m := logitech.LitraBeamLX
+	err := dm.Apply(AllLights, func(l *Light) ([][]byte, error) {
+		l.rememberBackColors(mustAll(m.Color(logitech.BackLight, 255, 0, 0)))
+		return [][]byte{mustBytes(m.LightsOff(logitech.BackLight))}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dm.Watch(ctx)
+	fake.Detach(testPath)
+	<-connections
+	fake.Reset()
+	fake.Attach(testLight)
+	<-connections
+
+	assertReports(t, fake.Written(testPath), mustBytes(m.LightsOff(logitech.BackLight)))
+}
+
+// waitFor polls until cond holds, for state that changes on another goroutine.
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteWaitsForAcknowledgement(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	fake.Respond(func(info transport.DeviceInfo, report []byte) [][]byte {
+		if len(report) > 3 && report[3] == 0x9c {
+			return [][]byte{hidError(report, logitech.ErrOutOfRange)}
+		}
+		return emulateLitras(info, report)
+	})
+
+	on := mustBytes(logitech.LitraBeamLX.LightsOn(logitech.FrontLight))
+	temp := mustBytes(logitech.LitraBeamLX.Temperature(logitech.FrontLight, 4000))
+	bright := mustBytes(logitech.LitraBeamLX.Brightness(logitech.FrontLight, 50))
+
+	err := dm.WriteCommands(on, temp, bright)
+	var deviceErr *logitech.DeviceError
+	if !errors.As(err, &deviceErr) || deviceErr.Code != logitech.ErrOutOfRange || deviceErr.Function != 0x9c {
+		t.Fatalf("Expected the temperature to be refused as out of range, got %v", err)
+	}
+
+	// A refused command isn't retried, and the rest of the batch isn't sent.
+	assertReports(t, fake.Written(testPath), on, temp)
+	if fake.Opens() != 1 {
+		t.Errorf("Expected no reconnect after a refusal, opened %d times", fake.Opens())
+	}
+}
+
+func TestWriteToMissingFeatureIsRefused(t *testing.T) {
+	glow := testLight
+	glow.ProductID = logitech.LitraGlow.ProductID
+	dm, _ := newTestManager(glow)
+
+	// A Beam LX back light command sent to a Glow, bypassing the model table.
+	err := dm.WriteCommands(mustBytes(logitech.LitraBeamLX.LightsOn(logitech.BackLight)))
+	if !errors.Is(err, logitech.ErrInvalidFeatureIndex) {
+		t.Errorf("Expected ErrInvalidFeatureIndex, got %v", err)
+	}
+}
+
+func TestWriteRetriesWithoutAcknowledgement(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.Lights()
+	fake.Respond(nil)
+
+	err := dm.WriteCommands(mustBytes(logitech.LitraBeamLX.LightsOn(logitech.FrontLight)))
+	if !errors.Is(err, transport.ErrTimeout) {
+		t.Fatalf("Expected a timeout, got %v", err)
+	}
+	if len(fake.Written(testPath)) != maxRetries+1 {
+		t.Errorf("Expected %d attempts, got %d", maxRetries+1, len(fake.Written(testPath)))
+	}
+}
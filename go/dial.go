@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+	sdcontext "github.com/samwho/streamdeck/context"
+)
+
+const (
+	dialFrontBrightnessUUID  = "ca.michaelabon.logitech-litra-lights.dial.front.brightness"
+	dialFrontTemperatureUUID = "ca.michaelabon.logitech-litra-lights.dial.front.temperature"
+	dialBackBrightnessUUID   = "ca.michaelabon.logitech-litra-lights.dial.back.brightness"
+	dialBackHueUUID          = "ca.michaelabon.logitech-litra-lights.dial.back.hue"
+)
+
+// dialControl is what a Stream Deck+ dial adjusts. Turning it steps the
+// value, pressing it turns the light on or off, and tapping the touch strip
+// jumps to the next preset.
+type dialControl struct {
+	uuid      string
+	title     string // shown on the touch strip
+	which     logitech.LightTarget
+	kind      logitech.EventKind // the setting it adjusts; zero for the back light's colours
+	attribute string
+	min, max  int
+	step      int  // per tick of the dial
+	wrap      bool // turning past one end comes back at the other, like a hue
+	unit      string
+	presets   []int // in increasing order
+	turnsOn   bool  // setting the value turns the light on
+
+	// read picks the value out of a light's state, if it's known.
+	read  func(LightState) (int, bool)
+	fader func(value int) fader
+}
+
+var dialControls = []dialControl{
+	{
+		uuid: dialFrontBrightnessUUID, title: "Front Brightness",
+		which: logitech.FrontLight, kind: logitech.BrightnessChanged, attribute: "front.brightness",
+		min: 1, max: 100, step: 1, unit: "%",
+		presets: []int{20, 40, 60, 80, 100},
+		read:    func(s LightState) (int, bool) { return int(s.Brightness), s.Brightness > 0 },
+		fader:   func(v int) fader { return fadeBrightness(logitech.FrontLight, uint8(v)) },
+	},
+	{
+		uuid: dialFrontTemperatureUUID, title: "Front Temperature",
+		which: logitech.FrontLight, kind: logitech.TemperatureChanged, attribute: "front.temperature",
+		min: 2700, max: 6500, step: 100, unit: "K", turnsOn: true,
+		presets: []int{2700, 3200, 4000, 5000, 6500},
+		read:    func(s LightState) (int, bool) { return int(s.Temperature), s.Temperature > 0 },
+		fader:   func(v int) fader { return fadeAll(turnOn(logitech.FrontLight), fadeTemperature(uint16(v))) },
+	},
+	{
+		uuid: dialBackBrightnessUUID, title: "Back Brightness",
+		which: logitech.BackLight, kind: logitech.BrightnessChanged, attribute: "back.brightness",
+		min: 1, max: 100, step: 1, unit: "%",
+		presets: []int{20, 40, 60, 80, 100},
+		read:    func(s LightState) (int, bool) { return int(s.Brightness), s.Brightness > 0 },
+		fader:   func(v int) fader { return fadeBrightness(logitech.BackLight, uint8(v)) },
+	},
+	{
+		uuid: dialBackHueUUID, title: "Back Hue",
+		which: logitech.BackLight, attribute: "back.color",
+		min: 0, max: 359, step: 5, wrap: true, unit: "°", turnsOn: true,
+		presets: []int{0, 30, 60, 120, 180, 240, 300},
+		read: func(s LightState) (int, bool) {
+			if len(s.Colors) == 0 {
+				return 0, false
+			}
+			h, ok := logitech.HueOf(s.Colors[0])
+			return int(math.Round(h)) % 360, ok
+		},
+		fader: func(v int) fader {
+			return fadeAll(turnOn(logitech.BackLight), fadeFrame(logitech.Fill(logitech.Hue(float64(v)))))
+		},
+	},
+}
+
+// turn returns value moved by ticks of the dial, kept within the control's
+// range.
+func (c dialControl) turn(value, ticks int) int {
+	return c.turnBy(value, ticks*c.step)
+}
+
+// turnBy returns value moved by delta, kept within the control's range.
+func (c dialControl) turnBy(value, delta int) int {
+	value += delta
+	if c.wrap {
+		span := c.max - c.min + 1
+		return c.min + ((value-c.min)%span+span)%span
+	}
+	return min(max(value, c.min), c.max)
+}
+
+// feedback is the touch strip layout ($B1) showing value.
+func (c dialControl) feedback(value int, on bool) map[string]any {
+	label := strconv.Itoa(value) + c.unit
+	if !on {
+		label = onOffTitle(false)
+	}
+	indicator := map[string]any{"value": 100 * (value - c.min) / (c.max - c.min)}
+	if c.wrap {
+		indicator["bar_fill_c"] = rgbaToHex(logitech.Hue(float64(value)))
+	}
+	return map[string]any{"title": c.title, "value": label, "indicator": indicator}
+}
+
+// dial is a visible dial: the lights it targets and what it shows of them.
+type dial struct {
+	control dialControl
+	target  string
+	value   int
+	on      bool
+	pending int // changes the dial submitted that haven't finished
+}
+
+// dials are the visible dials by context. They're read from the goroutine
+// delivering state changes, so they have their own lock.
+type dials struct {
+	mu    sync.Mutex
+	byCtx map[string]*dial
+}
+
+func newDials() *dials {
+	return &dials{byCtx: make(map[string]*dial)}
+}
+
+// get returns the dial behind an event, adding it if it wasn't seen appear.
+// Must be called with mu held.
+func (ds *dials) get(c dialControl, event streamdeck.Event) *dial {
+	d, ok := ds.byCtx[event.Context]
+	if !ok {
+		d = &dial{control: c, target: targetFromEvent(event), value: c.presets[0]}
+		ds.byCtx[event.Context] = d
+	}
+	return d
+}
+
+// settled passes on how a change the dial behind ctx submitted went, once
+// the dial no longer waits for it. Until then, the dial ignores state changes
+// that would undo what it shows while it's being turned.
+func (ds *dials) settled(ctx string, done <-chan error) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		err := <-done
+		ds.mu.Lock()
+		if d, ok := ds.byCtx[ctx]; ok && d.pending > 0 {
+			d.pending--
+		}
+		ds.mu.Unlock()
+		result <- err
+	}()
+	return result
+}
+
+// showDial sends a dial's value to its touch strip.
+func showDial(ctx context.Context, client *streamdeck.Client, d dial) {
+	if err := setFeedback(ctx, client, d.control.feedback(d.value, d.on)); err != nil {
+		log.Printf("Unable to update the touch strip of %s: %v\n", d.control.title, err)
+	}
+}
+
+// --- Stream Deck+ dials ---
+// dialControlFor returns the dial control of the action uuid.
+func dialControlFor(uuid string) dialControl {
+	i := slices.IndexFunc(dialControls, func(c dialControl) bool { return c.uuid == uuid })
+	return dialControls[i]
+}
+
+func setupDialActions(client *streamdeck.Client, dials *dials) {
+	for _, c := range dialControls {
+		setupDialAction(client, dials, c)
+	}
+}
+
+func setupDialAction(client *streamdeck.Client, dials *dials, c dialControl) {
+	action := client.Action(c.uuid)
+	what := "setting " + c.title
+
+	// change sets the dial's value with f and the lights to it.
+	change := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event, f func(int) int) error {
+		dials.mu.Lock()
+		d := dials.get(c, event)
+		d.value = f(d.value)
+		d.on = d.on || c.turnsOn
+		d.pending++
+		shown := *d
+		dials.mu.Unlock()
+
+		log.Printf("%s: %d%s\n", c.title, shown.value, c.unit)
+		showDial(ctx, client, shown)
+		takeOver(shown.target, c.attribute)
+		fade := Fade{Target: shown.target, Attribute: c.attribute, Fader: c.fader(shown.value)}
+		await(ctx, client, what, dials.settled(event.Context, deviceMgr.SubmitFade(ctx, fade, Transition{})), "")
+		return nil
+	}
+
+	action.RegisterHandler(
+		streamdeck.WillAppear,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			d := &dial{control: c, target: targetFromEvent(event), value: c.presets[0]}
+			dials.mu.Lock()
+			dials.byCtx[event.Context] = d
+			dials.mu.Unlock()
+			return showState(event, c.which, func(state LightState, ok bool) error {
+				dials.mu.Lock()
+				if dials.byCtx[event.Context] != d {
+					dials.mu.Unlock()
+					return nil // gone, or appeared again since
+				}
+				// A dial turned in the meantime shows its own value.
+				if ok && d.pending == 0 {
+					d.on = state.On
+					if v, ok := c.read(state); ok {
+						d.value = min(max(v, c.min), c.max)
+					}
+				}
+				shown := *d
+				dials.mu.Unlock()
+				showDial(ctx, client, shown)
+				return nil
+			})
+		},
+	)
+
+	action.RegisterHandler(
+		streamdeck.DidReceiveSettings,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			dials.mu.Lock()
+			defer dials.mu.Unlock()
+			if d, ok := dials.byCtx[event.Context]; ok {
+				d.target = targetFromEvent(event)
+			}
+			return nil
+		},
+	)
+
+	action.RegisterHandler(
+		streamdeck.WillDisappear,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			dials.mu.Lock()
+			defer dials.mu.Unlock()
+			delete(dials.byCtx, event.Context)
+			return nil
+		},
+	)
+
+	action.RegisterHandler(
+		DialRotate,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			p := DialRotatePayload{}
+			if err := json.Unmarshal(event.Payload, &p); err != nil {
+				return err
+			}
+			return change(ctx, client, event, func(v int) int { return c.turn(v, p.Ticks) })
+		},
+	)
+
+	action.RegisterHandler(
+		TouchTap,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			return change(ctx, client, event, func(v int) int {
+				return c.presets[nextPresetIndex(c.presets, v)]
+			})
+		},
+	)
+
+	action.RegisterHandler(
+		DialDown,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			target := targetFromEvent(event)
+			on := !poweredOn(c.which, target)
+			log.Printf("%s: %s\n", c.which, onOffTitle(on))
+
+			dials.mu.Lock()
+			d := dials.get(c, event)
+			d.on = on
+			d.pending++
+			shown := *d
+			dials.mu.Unlock()
+
+			showDial(ctx, client, shown)
+			cmd := powerCommand(c.which, target, on)
+			takeOver(target, cmd.Attribute)
+			await(ctx, client, fmt.Sprintf("toggling %s power", c.which), dials.settled(event.Context, deviceMgr.Submit(ctx, cmd)), "")
+			return nil
+		},
+	)
+}
+
+// followDials updates the touch strips of visible dials whenever a light's
+// state changes, like followLights does for keys.
+func followDials(client *streamdeck.Client, dials *dials) {
+	dm := deviceMgr
+	dm.State.Subscribe(func(change StateChange) {
+		var shown []dial
+		var contexts []string
+		dials.mu.Lock()
+		for ctxStr, d := range dials.byCtx {
+			if d.control.which != change.Which || d.pending > 0 || !dm.Selects(d.target, change.ID) {
+				continue
+			}
+			value, on := d.value, d.on
+			switch change.Kind {
+			case logitech.PowerChanged:
+				on = change.State.On
+			case d.control.kind:
+				if v, ok := d.control.read(change.State); ok {
+					value = min(max(v, d.control.min), d.control.max)
+				}
+			}
+			if value == d.value && on == d.on {
+				continue
+			}
+			d.value, d.on = value, on
+			shown = append(shown, *d)
+			contexts = append(contexts, ctxStr)
+		}
+		dials.mu.Unlock()
+
+		for i, d := range shown {
+			showDial(sdcontext.WithContext(context.Background(), contexts[i]), client, d)
+		}
+	})
+}
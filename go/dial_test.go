@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+// expectFeedback waits for the touch strip of ctx to be updated and returns
+// the value it shows.
+func (d *testDeck) expectFeedback(ctx string) string {
+	d.t.Helper()
+	var p struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(d.expect(SetFeedback, ctx).Payload, &p); err != nil {
+		d.t.Fatal(err)
+	}
+	return p.Value
+}
+
+func TestDialTurn(t *testing.T) {
+	brightness, hue := dialControls[0], dialControls[3]
+	tests := []struct {
+		name        string
+		c           dialControl
+		value, tick int
+		want        int
+	}{
+		{"brightness up", brightness, 50, 3, 53},
+		{"brightness stops at the top", brightness, 99, 5, 100},
+		{"brightness stops at the bottom", brightness, 2, -5, 1},
+		{"hue wraps past red", hue, 355, 2, 5},
+		{"hue wraps back past red", hue, 5, -2, 355},
+	}
+	for _, tt := range tests {
+		if got := tt.c.turn(tt.value, tt.tick); got != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestTemperatureDial(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	m := logitech.LitraBeamLX
+	ctx := t.Name()
+
+	d.send(dialFrontTemperatureUUID, streamdeck.WillAppear, ctx, nil)
+	if v := d.expectFeedback(ctx); v != "4000K" {
+		t.Errorf("Expected the light's 4000K, got %q", v)
+	}
+	read := len(d.fake.Written(testPath))
+
+	d.sendPayload(dialFrontTemperatureUUID, DialRotate, ctx, DialRotatePayload{Ticks: -3})
+	if v := d.expectFeedback(ctx); v != "3700K" {
+		t.Errorf("Expected 3700K after three ticks down, got %q", v)
+	}
+	written := d.waitReports(read + 2)
+	assertReports(t, written[read:],
+		mustBytes(m.LightsOn(logitech.FrontLight)),
+		mustBytes(m.Temperature(logitech.FrontLight, 3700)),
+	)
+
+	// A tap jumps to the next preset.
+	d.sendPayload(dialFrontTemperatureUUID, TouchTap, ctx, TouchTapPayload{})
+	if v := d.expectFeedback(ctx); v != "4000K" {
+		t.Errorf("Expected the 4000K preset, got %q", v)
+	}
+
+	// Pressing the dial turns the light off.
+	before := len(d.waitReports(len(written) + 1))
+	d.sendPayload(dialFrontTemperatureUUID, DialDown, ctx, DialPayload{})
+	if v := d.expectFeedback(ctx); v != "OFF" {
+		t.Errorf("Expected OFF, got %q", v)
+	}
+	written = d.waitReports(before + 1)
+	assertReports(t, written[len(written)-1:], mustBytes(m.LightsOff(logitech.FrontLight)))
+}
+
+func TestBackBrightnessDialFollowsTheLight(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	ctx := t.Name()
+
+	d.send(dialBackBrightnessUUID, streamdeck.WillAppear, ctx, nil)
+	if v := d.expectFeedback(ctx); v != "OFF" {
+		t.Errorf("Expected the back light off, got %q", v)
+	}
+
+	// The light turning its back light on updates the strip.
+	if err := d.fake.QueueRead(testPath, []byte{0x11, 0xff, 0x0a, 0x10, 0x01}); err != nil {
+		t.Fatal(err)
+	}
+	if v := d.expectFeedback(ctx); v != "40%" {
+		t.Errorf("Expected the light's 40%%, got %q", v)
+	}
+}
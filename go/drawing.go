@@ -1,11 +1,13 @@
 package main
 
 import (
+	"cmp"
 	"image"
 	"image/color"
 	"log"
 
 	temperatureconverter "github.com/maruel/temperature"
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
 )
 
 const (
@@ -84,6 +86,50 @@ func generateBackground(settings Settings) image.Image {
 	return img
 }
 
+// sceneImage draws a scene for its key: the front light's white across the
+// top and each back light zone along the bottom, dimmed by brightness and
+// dark where the scene turns a light off.
+func sceneImage(s Scene) image.Image {
+	const dim = 72
+	const frontHeight = 48
+	img := image.NewRGBA(image.Rect(0, 0, dim, dim))
+
+	off := color.RGBA{A: opaque}
+	front := off
+	if s.Front.Power != "off" {
+		front = dimmed(logitech.Kelvin(cmp.Or(s.Front.Temperature, 4000)), cmp.Or(s.Front.Brightness, maxBrightness))
+	}
+	zones := logitech.Fill(off)
+	if s.Back.Power != "off" && s.Back.hasColors() {
+		for i, c := range s.Back.frame() {
+			zones[i] = dimmed(c, cmp.Or(s.Back.Brightness, maxBrightness))
+		}
+	}
+
+	for y := 0; y < dim; y++ {
+		for x := 0; x < dim; x++ {
+			c := front
+			if y >= frontHeight {
+				c = zones[x*len(zones)/dim]
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// dimmed darkens c for a light at brightness percent, keeping dim lights
+// visible on the key.
+func dimmed(c color.RGBA, brightness uint8) color.RGBA {
+	scale := 0.3 + 0.7*float64(brightness)/maxBrightness
+	return color.RGBA{
+		R: uint8(float64(c.R) * scale),
+		G: uint8(float64(c.G) * scale),
+		B: uint8(float64(c.B) * scale),
+		A: opaque,
+	}
+}
+
 // Returns the interpolated value that is calculated from topC to botC
 //
 // topC - the starting colour component value (original colour
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+// Effect renders the back light's zones at a moment of an animation.
+type Effect interface {
+	Frame(elapsed time.Duration) logitech.Frame
+}
+
+// EffectParams tune an effect. Speed scales how fast it runs, 1 being the
+// effect's normal pace. Palette lists the colours it uses; each effect has its
+// own default when it's empty.
+type EffectParams struct {
+	Speed   float64
+	Palette []color.RGBA
+}
+
+// effectInfo describes one effect for the Effects action.
+type effectInfo struct {
+	Title   string // shown on the key
+	Palette []string
+	New     func(EffectParams) Effect
+}
+
+var effects = map[string]effectInfo{
+	"breathing": {"Breathe", []string{"#ff0000", "#0000ff"}, newBreathing},
+	"rainbow":   {"Rainbow", []string{"#ff0000", "#ffff00", "#00ff00", "#00ffff", "#0000ff", "#ff00ff"}, newRainbow},
+	"scanner":   {"Scanner", []string{"#ff0000", "#000000"}, newScanner},
+	"cycle":     {"Cycle", []string{"#ff0000", "#00ff00", "#0000ff"}, newColorCycle},
+	"sparkle":   {"Sparkle", []string{"#ffffff", "#ffd000", "#00c0ff"}, newSparkle},
+	"rotate":    {"Rotate", []string{"#ff00ff", "#00ffff", "#ffff00"}, newGradientRotation},
+}
+
+// NewEffect builds the named effect. A zero speed runs at the normal pace.
+func NewEffect(name string, params EffectParams) (Effect, error) {
+	info, ok := effects[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown effect %q", name)
+	}
+	if params.Speed <= 0 {
+		params.Speed = 1
+	}
+	if len(params.Palette) == 0 {
+		for _, hex := range info.Palette {
+			params.Palette = append(params.Palette, hexToRGBA(hex))
+		}
+	}
+	return info.New(params), nil
+}
+
+// phase returns how many periods have passed at elapsed, at the given speed.
+func phase(elapsed, period time.Duration, speed float64) float64 {
+	return elapsed.Seconds() * speed / period.Seconds()
+}
+
+func scale(c color.RGBA, f float64) color.RGBA {
+	channel := func(v uint8) uint8 {
+		return uint8(math.Round(float64(v) * min(max(f, 0), 1)))
+	}
+	return color.RGBA{R: channel(c.R), G: channel(c.G), B: channel(c.B), A: 0xff}
+}
+
+// looped blends through the colours and back to the first, so it can wrap.
+func looped(colors []color.RGBA, space logitech.Interpolation) logitech.Gradient {
+	g := logitech.Gradient{Space: space}
+	for i, c := range colors {
+		g.Stops = append(g.Stops, logitech.Stop{Position: float64(i) / float64(len(colors)), Color: c})
+	}
+	g.Stops = append(g.Stops, logitech.Stop{Position: 1, Color: colors[0]})
+	return g
+}
+
+// --- Breathing: the whole bar fades in and out, a palette colour per breath ---
+
+type breathing struct {
+	EffectParams
+}
+
+const breathPeriod = 4 * time.Second
+
+func newBreathing(p EffectParams) Effect { return breathing{p} }
+
+func (e breathing) Frame(elapsed time.Duration) logitech.Frame {
+	breaths, at := math.Modf(phase(elapsed, breathPeriod, e.Speed))
+	c := e.Palette[int(breaths)%len(e.Palette)]
+	return logitech.Fill(scale(c, (1-math.Cos(2*math.Pi*at))/2))
+}
+
+// --- Rainbow wave: the hue wheel scrolls along the bar ---
+
+type rainbow struct {
+	EffectParams
+	wheel logitech.Gradient
+}
+
+const rainbowPeriod = 5 * time.Second
+
+// newRainbow blends the palette in sRGB: between neighbouring primaries and
+// secondaries that is the same as turning the hue.
+func newRainbow(p EffectParams) Effect {
+	return rainbow{p, looped(p.Palette, logitech.SRGB)}
+}
+
+func (e rainbow) Frame(elapsed time.Duration) logitech.Frame {
+	_, shift := math.Modf(phase(elapsed, rainbowPeriod, e.Speed))
+	var f logitech.Frame
+	for i := range f {
+		_, at := math.Modf(float64(i)/float64(len(f)) + shift)
+		f[i] = e.wheel.At(at)
+	}
+	return f
+}
+
+// --- Scanner: a light sweeps back and forth leaving a fading trail ---
+
+type scanner struct {
+	EffectParams
+}
+
+const (
+	scanPeriod = 2 * time.Second // there and back
+	scanTrail  = 2.0             // zones the trail fades over
+)
+
+func newScanner(p EffectParams) Effect { return scanner{p} }
+
+func (e scanner) Frame(elapsed time.Duration) logitech.Frame {
+	_, at := math.Modf(phase(elapsed, scanPeriod, e.Speed))
+	last := float64(len(logitech.Frame{}) - 1)
+	head := last * (1 - math.Abs(2*at-1)) // 0 to the last zone and back
+
+	background := color.RGBA{A: 0xff}
+	if len(e.Palette) > 1 {
+		background = e.Palette[1]
+	}
+	gradient := logitech.Gradient{Stops: []logitech.Stop{{Position: 0, Color: background}, {Position: 1, Color: e.Palette[0]}}}
+
+	var f logitech.Frame
+	for i := range f {
+		f[i] = gradient.At(1 - math.Abs(float64(i)-head)/scanTrail)
+	}
+	return f
+}
+
+// --- Colour cycle: the whole bar blends from one palette colour to the next ---
+
+type colorCycle struct {
+	EffectParams
+	loop logitech.Gradient
+}
+
+const cycleStep = 3 * time.Second // per palette colour
+
+func newColorCycle(p EffectParams) Effect {
+	return colorCycle{p, looped(p.Palette, logitech.OKLab)}
+}
+
+func (e colorCycle) Frame(elapsed time.Duration) logitech.Frame {
+	_, at := math.Modf(phase(elapsed, cycleStep*time.Duration(len(e.Palette)), e.Speed))
+	return logitech.Fill(e.loop.At(at))
+}
+
+// --- Sparkle: zones flash palette colours at random and fade out ---
+
+type sparkle struct {
+	EffectParams
+	seed uint64
+}
+
+const (
+	sparkleSlot   = 400 * time.Millisecond // each zone may sparkle once per slot
+	sparkleChance = 0.35
+)
+
+func newSparkle(p EffectParams) Effect { return sparkle{p, rand.Uint64()} }
+
+// Frame picks each zone's sparkles from a generator seeded by the zone and
+// the time slot, so the effect needs no state between frames.
+func (e sparkle) Frame(elapsed time.Duration) logitech.Frame {
+	slot, at := math.Modf(phase(elapsed, sparkleSlot, e.Speed))
+	var f logitech.Frame
+	for i := range f {
+		r := rand.New(rand.NewPCG(e.seed^uint64(i), uint64(slot)))
+		start := r.Float64()
+		c := e.Palette[r.IntN(len(e.Palette))]
+		if r.Float64() >= sparkleChance || at < start {
+			f[i] = color.RGBA{A: 0xff}
+			continue
+		}
+		f[i] = scale(c, 1-(at-start)/(1-start))
+	}
+	return f
+}
+
+// --- Gradient rotation: the palette's gradient turns around the bar ---
+
+type gradientRotation struct {
+	EffectParams
+	loop logitech.Gradient
+}
+
+const rotationPeriod = 6 * time.Second
+
+func newGradientRotation(p EffectParams) Effect {
+	return gradientRotation{p, looped(p.Palette, logitech.OKLab)}
+}
+
+func (e gradientRotation) Frame(elapsed time.Duration) logitech.Frame {
+	_, shift := math.Modf(phase(elapsed, rotationPeriod, e.Speed))
+	var f logitech.Frame
+	for i := range f {
+		_, at := math.Modf(float64(i)/float64(len(f)) + 1 - shift)
+		f[i] = e.loop.At(at)
+	}
+	return f
+}
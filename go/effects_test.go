@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+var (
+	black = hexToRGBA("#000000")
+	red   = hexToRGBA("#ff0000")
+	green = hexToRGBA("#00ff00")
+	blue  = hexToRGBA("#0000ff")
+)
+
+func mustEffect(t *testing.T, name string, params EffectParams) Effect {
+	t.Helper()
+	e, err := NewEffect(name, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestBreathing(t *testing.T) {
+	e := mustEffect(t, "breathing", EffectParams{Palette: []color.RGBA{red, blue}})
+
+	if got := e.Frame(0); got != logitech.Fill(black) {
+		t.Errorf("Expected a breath to start dark, got %v", got)
+	}
+	if got := e.Frame(breathPeriod / 2); got != logitech.Fill(red) {
+		t.Errorf("Expected the first breath red at its peak, got %v", got)
+	}
+	if got := e.Frame(breathPeriod * 3 / 2); got != logitech.Fill(blue) {
+		t.Errorf("Expected the second breath blue, got %v", got)
+	}
+
+	// Twice the speed reaches the peak in half the time.
+	fast := mustEffect(t, "breathing", EffectParams{Speed: 2, Palette: []color.RGBA{red}})
+	if got := fast.Frame(breathPeriod / 4); got != logitech.Fill(red) {
+		t.Errorf("Expected the peak at a quarter period, got %v", got)
+	}
+}
+
+func TestRainbowScrolls(t *testing.T) {
+	e := mustEffect(t, "rainbow", EffectParams{})
+
+	first := e.Frame(0)
+	if first[0] != red {
+		t.Errorf("Expected zone 1 to start red, got %v", first[0])
+	}
+	later := e.Frame(rainbowPeriod / 7)
+	for i := 1; i < len(first); i++ {
+		if later[i-1] != first[i] {
+			t.Errorf("Expected zone %d to take zone %d's colour after a seventh of a period, got %v and %v", i, i+1, later[i-1], first[i])
+		}
+	}
+	if e.Frame(rainbowPeriod) != first {
+		t.Error("Expected the wave to repeat every period")
+	}
+}
+
+func TestScannerSweeps(t *testing.T) {
+	e := mustEffect(t, "scanner", EffectParams{Palette: []color.RGBA{red, black}})
+
+	start := e.Frame(0)
+	if start[0] != red || start[6] != black {
+		t.Errorf("Expected the light at zone 1, got %v", start)
+	}
+	if start[1] == red || start[1] == black {
+		t.Errorf("Expected a trail behind the light, got %v", start[1])
+	}
+	if middle := e.Frame(scanPeriod / 2); middle[6] != red || middle[0] != black {
+		t.Errorf("Expected the light at zone 7 half way, got %v", middle)
+	}
+}
+
+func TestColorCycle(t *testing.T) {
+	e := mustEffect(t, "cycle", EffectParams{Palette: []color.RGBA{red, green, blue}})
+
+	for i, want := range []color.RGBA{red, green, blue, red} {
+		if got := e.Frame(cycleStep * time.Duration(i)); got != logitech.Fill(want) {
+			t.Errorf("Step %d: expected %v, got %v", i, want, got[0])
+		}
+	}
+}
+
+func TestSparkleUsesThePalette(t *testing.T) {
+	e := mustEffect(t, "sparkle", EffectParams{Palette: []color.RGBA{red}})
+
+	lit := false
+	for at := time.Duration(0); at < 20*sparkleSlot; at += sparkleSlot / 8 {
+		frame := e.Frame(at)
+		if frame != e.Frame(at) {
+			t.Fatal("Expected the same frame for the same moment")
+		}
+		for _, c := range frame {
+			if c.G != 0 || c.B != 0 {
+				t.Fatalf("Expected only shades of red, got %v", c)
+			}
+			lit = lit || c.R > 0
+		}
+	}
+	if !lit {
+		t.Error("Expected some sparkles")
+	}
+}
+
+func TestGradientRotation(t *testing.T) {
+	e := mustEffect(t, "rotate", EffectParams{Palette: []color.RGBA{red, blue}})
+
+	first := e.Frame(0)
+	if first[0] != red {
+		t.Errorf("Expected zone 1 red, got %v", first[0])
+	}
+	later := e.Frame(rotationPeriod / 7)
+	for i := 1; i < len(first); i++ {
+		if later[i] != first[i-1] {
+			t.Errorf("Expected zone %d to take zone %d's colour, got %v and %v", i+1, i, later[i], first[i-1])
+		}
+	}
+}
+
+func TestNewEffectRejectsUnknownNames(t *testing.T) {
+	if _, err := NewEffect("disco", EffectParams{}); err == nil {
+		t.Error("Expected an error for an unknown effect")
+	}
+}
+
+// countingEffect shows a different colour for every frame it renders.
+type countingEffect struct {
+	frames atomic.Int32
+}
+
+func (e *countingEffect) Frame(time.Duration) logitech.Frame {
+	n := e.frames.Add(1)
+	return logitech.Fill(color.RGBA{R: uint8(n), A: 0xff})
+}
+
+func TestEffectStreamsFramesUntilStopped(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	ended := make(chan error, 1)
+	var colorChanges atomic.Int32
+	dm.State.Subscribe(func(c StateChange) {
+		if c.Which == logitech.BackLight {
+			colorChanges.Add(1)
+		}
+	})
+
+	dm.StartEffect(AllLights, "test", &countingEffect{}, maxFrameRate, func(err error) { ended <- err })
+	waitFor(t, func() bool { return len(fake.Written(testPath)) >= 7 })
+
+	written := fake.Written(testPath)
+	if !bytes.Equal(written[0], logitech.ConvertLightsOnTarget(logitech.BackLight, logitech.BackLightFID)) {
+		t.Errorf("Expected the first frame to turn the back light on, got % x", written[0])
+	}
+	for _, report := range written[1:] {
+		if bytes.Equal(report, written[0]) {
+			t.Error("Expected the back light turned on only once")
+		}
+	}
+	if name, ok := dm.RunningEffect(AllLights); !ok || name != "test" {
+		t.Errorf("Expected the test effect running, got %q", name)
+	}
+
+	if !dm.StopEffects(AllLights) {
+		t.Error("Expected StopEffects to report the running effect")
+	}
+	if err := <-ended; err != nil {
+		t.Errorf("Expected no error when stopped, got %v", err)
+	}
+	stoppedAt := len(fake.Written(testPath))
+	time.Sleep(5 * time.Second / maxFrameRate)
+	if got := len(fake.Written(testPath)); got != stoppedAt {
+		t.Errorf("Expected no frames after stopping, got %d more reports", got-stoppedAt)
+	}
+
+	// Only the frame the effect stopped on is recorded as the back light's colours.
+	if n := colorChanges.Load(); n != 0 {
+		t.Errorf("Expected the frames kept out of the light state, got %d changes", n)
+	}
+	commands, _ := dm.State.BackColors(testLight.SerialNbr)
+	if len(commands) == 0 {
+		t.Fatal("Expected the last frame recorded")
+	}
+	written = fake.Written(testPath)
+	assertReports(t, written[len(written)-len(commands):], commands...)
+	if _, ok := dm.RunningEffect(AllLights); ok {
+		t.Error("Expected no effect running")
+	}
+}
+
+func TestEffectStopsOnLightsWithoutColour(t *testing.T) {
+	glow := testLight
+	glow.ProductID = logitech.LitraGlow.ProductID
+	dm, _ := newTestManager(glow)
+	ended := make(chan error, 1)
+
+	dm.StartEffect(AllLights, "test", &countingEffect{}, maxFrameRate, func(err error) { ended <- err })
+	select {
+	case err := <-ended:
+		if !errors.Is(err, logitech.ErrUnsupported) {
+			t.Errorf("Expected ErrUnsupported, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the effect to stop")
+	}
+	if _, ok := dm.RunningEffect(AllLights); ok {
+		t.Error("Expected the failed effect removed")
+	}
+}
+
+func TestStaticColourTakesOverFromAnEffect(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	const action = "ca.michaelabon.logitech-litra-lights.back.effect"
+	settings := map[string]any{"effect": "rainbow", "fps": "30"}
+
+	d.send(action, streamdeck.WillAppear, t.Name(), settings)
+	if title := d.expectTitle(t.Name()); title != "Rainbow\nOff" {
+		t.Errorf("Expected Rainbow Off, got %q", title)
+	}
+	d.send(action, streamdeck.KeyDown, t.Name(), settings)
+	if title := d.expectTitle(t.Name()); title != "Rainbow\nOn" {
+		t.Errorf("Expected Rainbow On, got %q", title)
+	}
+	d.waitReports(4)
+
+	// Setting a colour stops the effect, and its key says so.
+	d.send("ca.michaelabon.logitech-litra-lights.back.color", streamdeck.KeyDown, "color", nil)
+	if title := d.expectTitle(t.Name()); title != "Rainbow\nOff" {
+		t.Errorf("Expected Rainbow Off, got %q", title)
+	}
+	d.expectTitle("color")
+	if _, ok := deviceMgr.RunningEffect(AllLights); ok {
+		t.Error("Expected the effect stopped")
+	}
+
+	written := d.fake.Written(testPath)
+	want := logitech.ConvertBackColorAllZones(255, 0, 0)
+	assertReports(t, written[len(written)-len(want):], want...)
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+// fadeInterval is how often a fade sends the lights its next step. A step
+// waits for the last one to be acknowledged, so slow lights get fewer steps.
+const fadeInterval = 40 * time.Millisecond
+
+// Easing maps the time through a fade, 0 to 1, to how far the lights have
+// gone from their old value to the new one.
+type Easing func(t float64) float64
+
+var easings = map[string]Easing{
+	"linear": func(t float64) float64 { return t },
+	"ease-in-out": func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		return 1 - math.Pow(-2*t+2, 3)/2
+	},
+	// exponential starts slowly and speeds up, which looks even to the eye
+	// when fading brightness.
+	"exponential": func(t float64) float64 {
+		if t <= 0 {
+			return 0
+		}
+		return math.Pow(2, 10*(t-1))
+	},
+}
+
+// Transition is how a change fades in. A zero Duration changes at once.
+type Transition struct {
+	Duration time.Duration
+	Easing   Easing // linear when nil
+}
+
+// TransitionSettings are the fade settings of the actions that can fade.
+type TransitionSettings struct {
+	Transition int    `json:"transition,string"` // milliseconds
+	Easing     string `json:"easing"`            // a key of easings
+}
+
+func (s TransitionSettings) transition() Transition {
+	return Transition{Duration: time.Duration(s.Transition) * time.Millisecond, Easing: easings[s.Easing]}
+}
+
+// fader starts a fade on one light: it returns the function building the
+// commands that take the light progress of the way, 0 to 1, from the state it
+// was in when the fade started.
+type fader func(l *Light) (step func(progress float64) ([][]byte, error), err error)
+
+// Fade is a change made gradually to the lights selected by Target. Like a
+// Command, a newer command with the same Target and Attribute replaces it.
+type Fade struct {
+	Target    string
+	Attribute string
+	Fader     fader
+}
+
+// fadeRun is a fade in progress.
+type fadeRun struct {
+	cancel context.CancelFunc
+}
+
+// fades tracks the fades in progress by Target and Attribute.
+type fades struct {
+	mu      sync.Mutex
+	running map[[2]string]*fadeRun
+}
+
+// SubmitFade makes fade's change over tr's duration, submitting a step every
+// fadeInterval. Like Submit it returns at once; the channel receives nil once
+// the lights reached the new value, ErrSuperseded if a newer command for the
+// same Target and Attribute replaced the fade, or the error that stopped it.
+func (dm *DeviceManager) SubmitFade(ctx context.Context, fade Fade, tr Transition) <-chan error {
+	steps := make(map[string]func(float64) ([][]byte, error)) // by light ID
+	sent := make(map[string][][]byte)
+	// build is only called by the writer goroutine, one step at a time.
+	build := func(progress float64) func(l *Light) ([][]byte, error) {
+		return func(l *Light) ([][]byte, error) {
+			step, ok := steps[l.ID]
+			if !ok {
+				var err error
+				if step, err = fade.Fader(l); err != nil {
+					return nil, err
+				}
+				steps[l.ID] = step
+			}
+			commands, err := step(progress)
+			if err != nil {
+				return nil, err
+			}
+			// Skip a step that doesn't change the light.
+			if last, ok := sent[l.ID]; ok && bytes.Equal(bytes.Join(last, nil), bytes.Join(commands, nil)) {
+				return nil, nil
+			}
+			sent[l.ID] = commands
+			return commands, nil
+		}
+	}
+
+	if tr.Duration <= 0 {
+		return dm.Submit(ctx, Command{Target: fade.Target, Attribute: fade.Attribute, Build: build(1)})
+	}
+	easing := tr.Easing
+	if easing == nil {
+		easing = easings["linear"]
+	}
+
+	key := [2]string{fade.Target, fade.Attribute}
+	fadeCtx, cancel := context.WithCancel(ctx)
+	run := &fadeRun{cancel: cancel}
+	dm.fades.mu.Lock()
+	if old, ok := dm.fades.running[key]; ok {
+		old.cancel()
+	}
+	if dm.fades.running == nil {
+		dm.fades.running = make(map[[2]string]*fadeRun)
+	}
+	dm.fades.running[key] = run
+	dm.fades.mu.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			dm.fades.mu.Lock()
+			if dm.fades.running[key] == run {
+				delete(dm.fades.running, key)
+			}
+			dm.fades.mu.Unlock()
+			cancel()
+		}()
+
+		ticker := time.NewTicker(fadeInterval)
+		defer ticker.Stop()
+		start := time.Now()
+		for {
+			t := min(time.Since(start).Seconds()/tr.Duration.Seconds(), 1)
+			cmd := Command{Target: fade.Target, Attribute: fade.Attribute, Build: build(easing(t)), fade: run}
+			err := <-dm.Submit(fadeCtx, cmd)
+			switch {
+			case fadeCtx.Err() != nil && ctx.Err() == nil:
+				done <- ErrSuperseded // a newer command cancelled the fade
+				return
+			case err != nil:
+				done <- err
+				return
+			case t == 1:
+				done <- nil
+				return
+			}
+
+			select {
+			case <-fadeCtx.Done():
+				if ctx.Err() != nil {
+					done <- ctx.Err()
+				} else {
+					done <- ErrSuperseded
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// cancelFade stops the fades changing any of what cmd changes on any of its
+// lights, unless cmd is one of their own steps, so a newer command isn't
+// undone by a fade's next step.
+func (dm *DeviceManager) cancelFade(cmd Command) {
+	dm.fades.mu.Lock()
+	defer dm.fades.mu.Unlock()
+	for key, run := range dm.fades.running {
+		if run == cmd.fade || !attributesOverlap(key[1], cmd.Attribute) || !dm.overlaps(key[0], cmd.Target) {
+			continue
+		}
+		run.cancel()
+		delete(dm.fades.running, key)
+	}
+}
+
+// attributesOverlap reports whether commands with attributes a and b change
+// some of the same things: "front" covers "front.brightness", and "power" and
+// "scene" cover everything.
+func attributesOverlap(a, b string) bool {
+	switch {
+	case a == b, a == "power", b == "power", a == "scene", b == "scene":
+		return true
+	}
+	return strings.HasPrefix(a, b+".") || strings.HasPrefix(b, a+".")
+}
+
+// --- Faders ---
+
+// between goes progress of the way from a to b.
+func between(a, b, progress float64) float64 {
+	return a + (b-a)*progress
+}
+
+// fadeAll fades several things at once, in order, e.g. turning a light on,
+// then its brightness and temperature.
+func fadeAll(faders ...fader) fader {
+	return func(l *Light) (func(float64) ([][]byte, error), error) {
+		steps := make([]func(float64) ([][]byte, error), len(faders))
+		for i, f := range faders {
+			var err error
+			if steps[i], err = f(l); err != nil {
+				return nil, err
+			}
+		}
+		return func(progress float64) ([][]byte, error) {
+			var commands [][]byte
+			for _, step := range steps {
+				c, err := step(progress)
+				if err != nil {
+					return nil, err
+				}
+				commands = append(commands, c...)
+			}
+			return commands, nil
+		}, nil
+	}
+}
+
+// turnOn turns the light on with the first step of a fade.
+func turnOn(which logitech.LightTarget) fader {
+	return func(l *Light) (func(float64) ([][]byte, error), error) {
+		onBytes, err := l.Model.LightsOn(which)
+		if err != nil {
+			return nil, err
+		}
+		first := true
+		return func(float64) ([][]byte, error) {
+			if !first {
+				return nil, nil
+			}
+			first = false
+			return [][]byte{onBytes}, nil
+		}, nil
+	}
+}
+
+// fadeBrightness fades a light's brightness from its known value to
+// brightness. A light whose brightness isn't known changes at once.
+func fadeBrightness(which logitech.LightTarget, brightness uint8) fader {
+	return func(l *Light) (func(float64) ([][]byte, error), error) {
+		from := brightness
+		if e, ok := l.recall(which, logitech.BrightnessChanged); ok {
+			from = e.Brightness
+		}
+		return func(progress float64) ([][]byte, error) {
+			b, err := l.Model.Brightness(which, uint8(math.Round(between(float64(from), float64(brightness), progress))))
+			return [][]byte{b}, err
+		}, nil
+	}
+}
+
+// fadeTemperature fades the front light's temperature from its known value,
+// like fadeBrightness.
+func fadeTemperature(temperature uint16) fader {
+	return func(l *Light) (func(float64) ([][]byte, error), error) {
+		from := temperature
+		if e, ok := l.recall(logitech.FrontLight, logitech.TemperatureChanged); ok {
+			from = e.Temperature
+		}
+		return func(progress float64) ([][]byte, error) {
+			t, err := l.Model.Temperature(logitech.FrontLight, uint16(math.Round(between(float64(from), float64(temperature), progress))))
+			return [][]byte{t}, err
+		}, nil
+	}
+}
+
+// fadeFrame fades the back light's zones from the last colours set to frame,
+// blending in OKLab so the colours in between don't go muddy.
+func fadeFrame(frame logitech.Frame) fader {
+	return func(l *Light) (func(float64) ([][]byte, error), error) {
+		if _, err := l.Model.Frame(logitech.BackLight, frame); err != nil {
+			return nil, err
+		}
+		var from logitech.Frame
+		last := l.backColors()
+		known := len(last) > 0
+		if known {
+			copy(from[:], l.Model.ZoneColors(last))
+		}
+		return func(progress float64) ([][]byte, error) {
+			step := frame
+			if known && progress < 1 {
+				for i := range step {
+					g := logitech.Gradient{Stops: []logitech.Stop{{Position: 0, Color: from[i]}, {Position: 1, Color: frame[i]}}, Space: logitech.OKLab}
+					step[i] = g.At(progress)
+				}
+			}
+			colorCmds, err := l.Model.Frame(logitech.BackLight, step)
+			if err != nil {
+				return nil, err
+			}
+			// Remembered for back power restore once the step is written
+			l.rememberBackColors(colorCmds)
+			return colorCmds, nil
+		}, nil
+	}
+}
+
+// fadeWhite fades the back light to white matching the front light at a
+// temperature, corrected for each light's model.
+func fadeWhite(kelvin uint16) fader {
+	return func(l *Light) (func(float64) ([][]byte, error), error) {
+		return fadeFrame(logitech.Fill(l.Model.White(kelvin)))(l)
+	}
+}
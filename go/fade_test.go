@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+func TestEasingsRunFromStartToEnd(t *testing.T) {
+	for name, easing := range easings {
+		if got := easing(0); got != 0 {
+			t.Errorf("%s: expected 0 at the start, got %v", name, got)
+		}
+		if got := easing(1); got != 1 {
+			t.Errorf("%s: expected 1 at the end, got %v", name, got)
+		}
+		last := 0.0
+		for i := 1; i <= 10; i++ {
+			got := easing(float64(i) / 10)
+			if got < last {
+				t.Errorf("%s: expected it never to go back, got %v after %v", name, got, last)
+			}
+			last = got
+		}
+	}
+	if got := easings["ease-in-out"](0.5); got != 0.5 {
+		t.Errorf("Expected ease-in-out half way at the middle, got %v", got)
+	}
+}
+
+// brightnessSteps decodes the brightness values written to the back light.
+func brightnessSteps(t *testing.T, reports [][]byte) []uint16 {
+	t.Helper()
+	var steps []uint16
+	for _, report := range reports {
+		cmd, err := logitech.Decode(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, ok := cmd.(logitech.Brightness); ok && b.Target == logitech.BackLight {
+			steps = append(steps, b.Value)
+		}
+	}
+	return steps
+}
+
+func TestFadeStepsToTheNewValue(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	m := logitech.LitraBeamLX
+	if err := <-dm.Submit(context.Background(), writeCmd("back.brightness", mustBytes(m.Brightness(logitech.BackLight, 20)))); err != nil {
+		t.Fatal(err)
+	}
+	before := len(fake.Written(testPath))
+
+	fade := Fade{Target: AllLights, Attribute: "back.brightness", Fader: fadeBrightness(logitech.BackLight, 80)}
+	if err := <-dm.SubmitFade(context.Background(), fade, Transition{Duration: 300 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := brightnessSteps(t, fake.Written(testPath)[before:])
+	if len(steps) < 4 {
+		t.Fatalf("Expected the fade to take several steps, got %v", steps)
+	}
+	for i := 1; i < len(steps); i++ {
+		if steps[i] <= steps[i-1] {
+			t.Errorf("Expected the brightness to rise at every step, got %v", steps)
+			break
+		}
+	}
+	if last := steps[len(steps)-1]; last != 80 {
+		t.Errorf("Expected the fade to end at 80%%, got %d%%", last)
+	}
+}
+
+func TestCommandCancelsFade(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	m := logitech.LitraBeamLX
+	<-dm.Submit(context.Background(), writeCmd("back.brightness", mustBytes(m.Brightness(logitech.BackLight, 1))))
+
+	fade := Fade{Target: AllLights, Attribute: "back.brightness", Fader: fadeBrightness(logitech.BackLight, 100)}
+	done := dm.SubmitFade(context.Background(), fade, Transition{Duration: time.Minute})
+	waitFor(t, func() bool { return len(fake.Written(testPath)) >= 3 })
+
+	final := mustBytes(m.Brightness(logitech.BackLight, 30))
+	if err := result(t, dm.Submit(context.Background(), writeCmd("back.brightness", final))); err != nil {
+		t.Fatal(err)
+	}
+	if err := result(t, done); !errors.Is(err, ErrSuperseded) {
+		t.Errorf("Expected the fade superseded, got %v", err)
+	}
+
+	time.Sleep(3 * fadeInterval)
+	written := fake.Written(testPath)
+	if !bytes.Equal(written[len(written)-1], final) {
+		t.Errorf("Expected the command to be the last report, got %s", m.Describe(written[len(written)-1]))
+	}
+}
+
+func TestCommandCancelsFadeOfWhatItCovers(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	m := logitech.LitraBeamLX
+	<-dm.Submit(context.Background(), writeCmd("front.brightness", mustBytes(m.Brightness(logitech.FrontLight, 1))))
+
+	fade := Fade{Target: AllLights, Attribute: "front.brightness", Fader: fadeBrightness(logitech.FrontLight, 100)}
+	done := dm.SubmitFade(context.Background(), fade, Transition{Duration: time.Minute})
+	waitFor(t, func() bool { return len(fake.Written(testPath)) >= 3 })
+
+	// "front" covers the front light's brightness, on a light the fade is on.
+	final := mustBytes(m.Brightness(logitech.FrontLight, 30))
+	cmd := writeCmd("front", final)
+	cmd.Target = testLight.SerialNbr
+	if err := result(t, dm.Submit(context.Background(), cmd)); err != nil {
+		t.Fatal(err)
+	}
+	if err := result(t, done); !errors.Is(err, ErrSuperseded) {
+		t.Errorf("Expected the fade superseded, got %v", err)
+	}
+
+	time.Sleep(3 * fadeInterval)
+	written := fake.Written(testPath)
+	if !bytes.Equal(written[len(written)-1], final) {
+		t.Errorf("Expected the command to be the last report, got %s", m.Describe(written[len(written)-1]))
+	}
+}
+
+func TestAttributesOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"front.brightness", "front.brightness", true},
+		{"front", "front.brightness", true},
+		{"front.temperature", "front", true},
+		{"power", "back.color", true},
+		{"front.brightness", "scene", true},
+		{"front.brightness", "front.temperature", false},
+		{"front", "back.brightness", false},
+		{"back", "back.color", true},
+		{"back", "backlight", false},
+	}
+	for _, test := range tests {
+		if got := attributesOverlap(test.a, test.b); got != test.want {
+			t.Errorf("attributesOverlap(%q, %q): expected %v, got %v", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+func TestFadeWithoutDurationChangesAtOnce(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	m := logitech.LitraBeamLX
+
+	fade := Fade{Target: AllLights, Attribute: "front.temperature", Fader: fadeAll(turnOn(logitech.FrontLight), fadeTemperature(5000))}
+	if err := result(t, dm.SubmitFade(context.Background(), fade, Transition{})); err != nil {
+		t.Fatal(err)
+	}
+
+	assertReports(t, fake.Written(testPath),
+		mustBytes(m.LightsOn(logitech.FrontLight)),
+		mustBytes(m.Temperature(logitech.FrontLight, 5000)),
+	)
+}
+
+func TestFadeFrameBlendsFromTheLastColours(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	m := logitech.LitraBeamLX
+	if err := <-dm.SubmitFade(context.Background(), Fade{Target: AllLights, Attribute: "back.color", Fader: fadeFrame(logitech.Fill(red))}, Transition{}); err != nil {
+		t.Fatal(err)
+	}
+	redReports := len(fake.Written(testPath))
+
+	fade := Fade{Target: AllLights, Attribute: "back.color", Fader: fadeFrame(logitech.Fill(blue))}
+	if err := <-dm.SubmitFade(context.Background(), fade, Transition{Duration: 200 * time.Millisecond, Easing: easings["ease-in-out"]}); err != nil {
+		t.Fatal(err)
+	}
+
+	var blended bool
+	for _, report := range fake.Written(testPath)[redReports:] {
+		cmd, err := m.Decode(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if zones, ok := cmd.(logitech.ZoneColor); ok {
+			c := zones.Zones[0].Color
+			blended = blended || (c != red && c != blue && c.R > 0 && c.B > 0)
+		}
+	}
+	if !blended {
+		t.Error("Expected colours between red and blue")
+	}
+
+	want := mustAll(m.Frame(logitech.BackLight, logitech.Fill(blue)))
+	written := fake.Written(testPath)
+	assertReports(t, written[len(written)-len(want):], want...)
+}
+
+func TestFadeFrameRemembersColoursOnceWritten(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	if err := <-dm.SubmitFade(context.Background(), Fade{Target: AllLights, Attribute: "back.color", Fader: fadeFrame(logitech.Fill(red))}, Transition{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, written := dm.State.BackColors(testLight.SerialNbr)
+
+	fake.FailWrites(maxRetries+1, errors.New("unplugged"))
+	if err := <-dm.SubmitFade(context.Background(), Fade{Target: AllLights, Attribute: "back.color", Fader: fadeFrame(logitech.Fill(blue))}, Transition{}); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if _, colors := dm.State.BackColors(testLight.SerialNbr); len(colors) == 0 || !slices.Equal(colors, written) {
+		t.Errorf("Expected the colours last written, %v, got %v", written, colors)
+	}
+}
+
+func TestBrightnessKeyFadesWithItsTransition(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	const action = "ca.michaelabon.logitech-litra-lights.front.brightness"
+	settings := map[string]any{"transition": "200", "easing": "exponential"}
+
+	d.send(action, streamdeck.WillAppear, t.Name(), settings)
+	if title := d.expectTitle(t.Name()); title != "50%" {
+		t.Errorf("Expected 50%%, got %q", title)
+	}
+	// The key follows the fade's steps up to 60%.
+	d.send(action, streamdeck.KeyDown, t.Name(), settings)
+	d.waitTitle(t.Name(), "60%")
+
+	written := d.fake.Written(testPath)
+	if len(written) < 2 {
+		t.Errorf("Expected the brightness to fade in steps, got %d reports", len(written))
+	}
+	assertReports(t, written[len(written)-1:], mustBytes(logitech.LitraBeamLX.Brightness(logitech.FrontLight, 60)))
+}
@@ -3,12 +3,13 @@ module github.com/michaelabon/streamdeck-logitech-litra
 go 1.24.6
 
 require (
+	github.com/gorilla/websocket v1.5.3
 	github.com/maruel/temperature v1.0.0
 	github.com/samwho/streamdeck v0.0.0-20190725183037-2b866fdcb4a6
 	github.com/sstallion/go-hid v0.15.0
 )
 
-require (
-	github.com/gorilla/websocket v1.5.3 // indirect
-	golang.org/x/sys v0.35.0 // indirect
-)
+require golang.org/x/sys v0.35.0 // indirect
+
+// A copy with Client.Send exported, for the Stream Deck+ events the package predates.
+replace github.com/samwho/streamdeck => ./third_party/streamdeck
@@ -2,12 +2,14 @@ package logitech_hid
 
 import (
 	"fmt"
+	"image/color"
 )
 
 // Logitech expects to receive 20 bytes when given a command.
 const byteLength = 20
 
-// Feature IDs for the Litra Beam LX
+// Default feature indices for the Litra Beam LX, used until a light's own
+// indices are discovered (see features.go). Other models are described in models.go.
 const (
 	FrontLightFID     = 0x06 // Front (white) light feature ID
 	BackLightFID      = 0x0a // Back (RGB) light power/brightness feature ID
@@ -28,32 +30,22 @@ const (
 // --- Power On/Off ---
 
 func ConvertLightsOn() (b []byte) {
-	return ConvertLightsOnTarget(FrontLight)
+	return ConvertLightsOnTarget(FrontLight, FrontLightFID)
 }
 
 func ConvertLightsOff() (b []byte) {
-	return ConvertLightsOffTarget(FrontLight)
+	return ConvertLightsOffTarget(FrontLight, FrontLightFID)
 }
 
-func ConvertLightsOnTarget(target LightTarget) (b []byte) {
-	b = make([]byte, byteLength)
-	if target == BackLight {
-		// Back light uses function 0x4b for power control
-		copy(b, []byte{0x11, 0xff, byte(target), 0x4b, 0x01})
-	} else {
-		copy(b, []byte{0x11, 0xff, byte(target), 0x1c, 0x01})
-	}
+// ConvertLightsOnTarget turns on the target light, whose feature is at index.
+func ConvertLightsOnTarget(target LightTarget, index byte) (b []byte) {
+	b, _ = Power{Target: target, Index: index, On: true}.MarshalBinary()
 	return
 }
 
-func ConvertLightsOffTarget(target LightTarget) (b []byte) {
-	b = make([]byte, byteLength)
-	if target == BackLight {
-		// Back light uses function 0x4b for power control
-		copy(b, []byte{0x11, 0xff, byte(target), 0x4b, 0x00})
-	} else {
-		copy(b, []byte{0x11, 0xff, byte(target), 0x1c, 0x00})
-	}
+// ConvertLightsOffTarget turns off the target light, whose feature is at index.
+func ConvertLightsOffTarget(target LightTarget, index byte) (b []byte) {
+	b, _ = Power{Target: target, Index: index}.MarshalBinary()
 	return
 }
 
@@ -64,31 +56,35 @@ const (
 	maxPercentage = 100
 )
 
-// ConvertBrightness sets front light brightness (1-100%)
-func ConvertBrightness(percentage uint8) ([]byte, error) {
-	return ConvertBrightnessTarget(FrontLight, percentage)
-}
-
-// ConvertBrightnessTarget sets brightness for a specific light target (1-100%)
-func ConvertBrightnessTarget(target LightTarget, percentage uint8) ([]byte, error) {
+// CheckBrightness reports whether percentage is a brightness the lights take (1-100%).
+func CheckBrightness(percentage uint8) error {
 	if percentage < minPercentage {
-		return nil, fmt.Errorf("percentage must be greater than 1, was %d", percentage)
+		return fmt.Errorf("percentage must be greater than 1, was %d", percentage)
 	}
 	if percentage > maxPercentage {
-		return nil, fmt.Errorf("percentage must be less than 100, was %d", percentage)
+		return fmt.Errorf("percentage must be less than 100, was %d", percentage)
 	}
+	return nil
+}
+
+// ConvertBrightness sets front light brightness (1-100%)
+func ConvertBrightness(percentage uint8) ([]byte, error) {
+	return ConvertBrightnessTarget(FrontLight, FrontLightFID, percentage)
+}
 
-	b := make([]byte, byteLength)
+// ConvertBrightnessTarget sets brightness for a specific light target (1-100%),
+// whose feature is at index.
+func ConvertBrightnessTarget(target LightTarget, index byte, percentage uint8) ([]byte, error) {
+	if err := CheckBrightness(percentage); err != nil {
+		return nil, err
+	}
 
 	if target == BackLight {
-		// Back light brightness uses function 0x2b with direct percentage value
-		copy(b, []byte{0x11, 0xff, byte(target), 0x2b, 0x00, percentage})
-	} else {
-		// Front light brightness uses function 0x4c with mapped value
-		copy(b, []byte{0x11, 0xff, byte(target), 0x4c, 0x00, calcBrightness(percentage)})
+		// Back light brightness takes the percentage directly
+		return Brightness{Target: target, Index: index, Value: uint16(percentage)}.MarshalBinary()
 	}
-
-	return b, nil
+	// Front light brightness takes a mapped value
+	return Brightness{Target: target, Index: index, Value: uint16(calcBrightness(percentage))}.MarshalBinary()
 }
 
 // --- Temperature ---
@@ -98,30 +94,31 @@ const (
 	maxTemperature = 6500
 )
 
-// ConvertTemperature sets front light temperature (2700-6500K)
-func ConvertTemperature(temperature uint16) ([]byte, error) {
-	return ConvertTemperatureTarget(FrontLight, temperature)
-}
-
-// ConvertTemperatureTarget sets temperature for a specific light target (2700-6500K)
-func ConvertTemperatureTarget(target LightTarget, temperature uint16) ([]byte, error) {
+// CheckTemperature reports whether temperature is a colour temperature the
+// lights take (2700-6500K).
+func CheckTemperature(temperature uint16) error {
 	if temperature < minTemperature {
-		return nil, fmt.Errorf("temperature must be greater than 2700, was %d", temperature)
+		return fmt.Errorf("temperature must be greater than 2700, was %d", temperature)
 	}
 	if temperature > maxTemperature {
-		return nil, fmt.Errorf("temperature must be less than 6500, was %d", temperature)
+		return fmt.Errorf("temperature must be less than 6500, was %d", temperature)
 	}
+	return nil
+}
 
-	b := make([]byte, byteLength)
-	b[0] = 0x11
-	b[1] = 0xff
-	b[2] = byte(target)
-	b[3] = 0x9c
+// ConvertTemperature sets front light temperature (2700-6500K)
+func ConvertTemperature(temperature uint16) ([]byte, error) {
+	return ConvertTemperatureTarget(FrontLight, FrontLightFID, temperature)
+}
 
-	b[4] = byte(temperature >> 8) //nolint:mnd // Split temperature into two bytes
-	b[5] = byte(temperature)
+// ConvertTemperatureTarget sets temperature for a specific light target (2700-6500K),
+// whose feature is at index.
+func ConvertTemperatureTarget(target LightTarget, index byte, temperature uint16) ([]byte, error) {
+	if err := CheckTemperature(temperature); err != nil {
+		return nil, err
+	}
 
-	return b, nil
+	return Temperature{Index: index, Kelvin: temperature}.MarshalBinary()
 }
 
 // --- Back Light Color ---
@@ -130,70 +127,74 @@ func ConvertTemperatureTarget(target LightTarget, temperature uint16) ([]byte, e
 // Zone IDs range from 1 to 7. RGB values are clamped to minimum 1.
 // After setting all desired zones, you must call ConvertBackColorCommit() and write it.
 func ConvertBackColorZone(zone uint8, r, g, b uint8) []byte {
-	// The device freaks out if RGB values are 0, clamp to 1
-	if r == 0 {
-		r = 1
-	}
-	if g == 0 {
-		g = 1
-	}
-	if b == 0 {
-		b = 1
+	return backColorZone(BackLightColorFID, zone, r, g, b)
+}
+
+// backColorZone builds a zone colour command for the colour feature at index.
+func backColorZone(index byte, zone uint8, r, g, b uint8) []byte {
+	return backColorZones(index, []Zone{{zone, rgb(r, g, b)}})[0]
+}
+
+// zonesPerReport is how many zone slots one colour report has. An unused
+// slot starts with unusedZone.
+const (
+	zonesPerReport = 4
+	unusedZone     = 0xFF
+)
+
+// ConvertBackColorZones packs zone colours four to a report, so the seven
+// zones take two reports instead of seven. RGB values are clamped to minimum 1.
+// After writing them, write ConvertBackColorCommit().
+func ConvertBackColorZones(zones []Zone) [][]byte {
+	return backColorZones(BackLightColorFID, zones)
+}
+
+// backColorZones packs zone colours for the colour feature at index.
+func backColorZones(index byte, zones []Zone) [][]byte {
+	commands := make([][]byte, 0, (len(zones)+zonesPerReport-1)/zonesPerReport)
+	for start := 0; start < len(zones); start += zonesPerReport {
+		buf, _ := ZoneColor{Index: index, Zones: zones[start:min(start+zonesPerReport, len(zones))]}.MarshalBinary()
+		commands = append(commands, buf)
 	}
+	return commands
+}
 
-	buf := make([]byte, byteLength)
-	copy(buf, []byte{
-		0x11, 0xff, BackLightColorFID, 0x1B,
-		zone, r, g, b,
-		0xFF, 0x00, 0x00, 0x00,
-		0xFF, 0x00, 0x00, 0x00,
-		0xFF, 0x00, 0x00, 0x00,
-	})
-	return buf
+func rgb(r, g, b uint8) color.RGBA {
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
 }
 
 // ConvertBackColorCommit generates the commit command that must be sent
 // after setting zone colors to apply the changes.
 func ConvertBackColorCommit() []byte {
-	buf := make([]byte, byteLength)
-	copy(buf, []byte{0x11, 0xff, BackLightColorFID, 0x7B, 0x00, 0x00, 0x01, 0x00, 0x00})
+	return backColorCommit(BackLightColorFID)
+}
+
+func backColorCommit(index byte) []byte {
+	buf, _ := Commit{Index: index}.MarshalBinary()
 	return buf
 }
 
 // ConvertBackColorAllZones generates a sequence of commands to set ALL 7 zones
-// to the same RGB color, including the final commit command.
+// to the same RGB color, packed into two reports, including the final commit command.
 // Returns a slice of byte slices that should be written in order.
 func ConvertBackColorAllZones(r, g, b uint8) [][]byte {
-	commands := make([][]byte, BackLightZoneCount+1)
-	for i := uint8(1); i <= BackLightZoneCount; i++ {
-		commands[i-1] = ConvertBackColorZone(i, r, g, b)
-	}
-	commands[BackLightZoneCount] = ConvertBackColorCommit()
-	return commands
+	return ConvertBackColorFrame(Fill(rgb(r, g, b)))
 }
 
-// ConvertColorTarget is a convenience wrapper that sets back light color on all zones.
-// For the front light, this is a no-op (front light doesn't support RGB).
-func ConvertColorTarget(target LightTarget, r, g, b uint8) ([][]byte, error) {
+// ConvertColorTarget sets back light color on all zones, with the colour
+// feature at index. For the front light, this is a no-op (front light doesn't support RGB).
+func ConvertColorTarget(target LightTarget, index byte, r, g, b uint8) ([][]byte, error) {
 	if target != BackLight {
 		return nil, fmt.Errorf("RGB color is only supported on BackLight target")
 	}
-	return ConvertBackColorAllZones(r, g, b), nil
+	commands := backColorZones(index, Fill(rgb(r, g, b)).zones(BackLightZoneCount))
+	return append(commands, backColorCommit(index)), nil
 }
 
 // ConvertBackColorGradient generates commands to set a gradient across all 7 zones,
 // interpolating from color1 (r1,g1,b1) to color2 (r2,g2,b2).
 func ConvertBackColorGradient(r1, g1, b1, r2, g2, b2 uint8) [][]byte {
-	commands := make([][]byte, BackLightZoneCount+1)
-	for i := uint8(0); i < BackLightZoneCount; i++ {
-		t := float64(i) / float64(BackLightZoneCount-1) // 0.0 to 1.0
-		r := uint8(float64(r1)*(1-t) + float64(r2)*t)
-		g := uint8(float64(g1)*(1-t) + float64(g2)*t)
-		b := uint8(float64(b1)*(1-t) + float64(b2)*t)
-		commands[i] = ConvertBackColorZone(i+1, r, g, b)
-	}
-	commands[BackLightZoneCount] = ConvertBackColorCommit()
-	return commands
+	return ConvertBackColorFrame(twoColors(rgb(r1, g1, b1), rgb(r2, g2, b2)).Frame())
 }
 
 // --- Internal helpers ---
@@ -1,6 +1,7 @@
 package logitech_hid
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -106,7 +107,7 @@ func TestConvertTemperature(t *testing.T) {
 
 func TestConvertBrightnessTarget(t *testing.T) {
 	// Front light should use FID 0x06
-	result, err := ConvertBrightnessTarget(FrontLight, 50)
+	result, err := ConvertBrightnessTarget(FrontLight, FrontLightFID, 50)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -115,7 +116,7 @@ func TestConvertBrightnessTarget(t *testing.T) {
 	}
 
 	// Back light should use FID 0x0a and function 0x2b
-	result, err = ConvertBrightnessTarget(BackLight, 50)
+	result, err = ConvertBrightnessTarget(BackLight, BackLightFID, 50)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -131,12 +132,12 @@ func TestConvertBrightnessTarget(t *testing.T) {
 }
 
 func TestConvertLightsOnTarget(t *testing.T) {
-	front := ConvertLightsOnTarget(FrontLight)
+	front := ConvertLightsOnTarget(FrontLight, FrontLightFID)
 	if front[2] != 0x06 || front[4] != 0x01 {
 		t.Errorf("Front ON incorrect: % x", front[:6])
 	}
 
-	back := ConvertLightsOnTarget(BackLight)
+	back := ConvertLightsOnTarget(BackLight, BackLightFID)
 	if back[2] != 0x0a || back[3] != 0x4b || back[4] != 0x01 {
 		t.Errorf("Back ON incorrect: % x (expected FID=0x0a, func=0x4b, val=0x01)", back[:6])
 	}
@@ -165,3 +166,55 @@ func extendByteSlice(a []byte) []byte {
 
 	return b
 }
+
+func TestConvertBackColorZonesPacksFourPerReport(t *testing.T) {
+	commands := ConvertBackColorAllZones(0, 128, 255)
+	expected := [][]byte{
+		{
+			0x11, 0xff, 0x0c, 0x1b,
+			0x01, 0x01, 0x80, 0xff,
+			0x02, 0x01, 0x80, 0xff,
+			0x03, 0x01, 0x80, 0xff,
+			0x04, 0x01, 0x80, 0xff,
+		},
+		{
+			0x11, 0xff, 0x0c, 0x1b,
+			0x05, 0x01, 0x80, 0xff,
+			0x06, 0x01, 0x80, 0xff,
+			0x07, 0x01, 0x80, 0xff,
+			0xff, 0x00, 0x00, 0x00,
+		},
+		ConvertBackColorCommit(),
+	}
+	if len(commands) != len(expected) {
+		t.Fatalf("Expected %d commands, got %d", len(expected), len(commands))
+	}
+	for i := range expected {
+		if !bytes.Equal(commands[i], expected[i]) {
+			t.Errorf("Command %d: expected % x, got % x", i, expected[i], commands[i])
+		}
+	}
+
+	// A single zone leaves the other three slots unused.
+	single := ConvertBackColorZone(3, 10, 20, 30)
+	if !bytes.Equal(single[4:], []byte{3, 10, 20, 30, 0xff, 0, 0, 0, 0xff, 0, 0, 0, 0xff, 0, 0, 0}) {
+		t.Errorf("Unexpected single zone report % x", single)
+	}
+
+	if got := ConvertBackColorZones(nil); len(got) != 0 {
+		t.Errorf("Expected no reports for no zones, got %d", len(got))
+	}
+}
+
+func TestConvertBackColorGradientEnds(t *testing.T) {
+	commands := ConvertBackColorGradient(255, 0, 0, 0, 0, 255)
+	if len(commands) != 3 {
+		t.Fatalf("Expected 3 commands, got %d", len(commands))
+	}
+	if !bytes.Equal(commands[0][4:8], []byte{0x01, 0xff, 0x01, 0x01}) {
+		t.Errorf("Expected the first zone red, got % x", commands[0][4:8])
+	}
+	if !bytes.Equal(commands[1][12:16], []byte{0x07, 0x01, 0x01, 0xff}) {
+		t.Errorf("Expected the last zone blue, got % x", commands[1][12:16])
+	}
+}
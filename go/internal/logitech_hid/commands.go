@@ -0,0 +1,344 @@
+package logitech_hid
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// Command is one HID++ report sent to a light, in typed form. MarshalBinary
+// builds the 20-byte report; Decode turns a report back into a Command.
+type Command interface {
+	encoding.BinaryMarshaler
+	fmt.Stringer
+}
+
+// ErrUnknownCommand is returned for a report that isn't one of the commands below.
+var ErrUnknownCommand = errors.New("unknown HID++ command")
+
+// Set functions of the colour feature, in function<<4 | softwareID form.
+const (
+	setZones     = 0x1B // PerKeyLighting setIndividualRgbZones
+	commitFrames = 0x7B // PerKeyLighting frameEnd
+)
+
+// Power turns a light on or off.
+type Power struct {
+	Target LightTarget
+	Index  byte // feature index of the target light
+	On     bool
+}
+
+// Brightness sets a light's brightness. Value is the output in lumen for the
+// front light and 1-100% for the back light, as the light expects them.
+type Brightness struct {
+	Target LightTarget
+	Index  byte
+	Value  uint16
+}
+
+// Temperature sets the front light's colour temperature.
+type Temperature struct {
+	Index  byte
+	Kelvin uint16
+}
+
+// Zone is the colour of one back light zone, numbered from 1.
+type Zone struct {
+	Number uint8
+	Color  color.RGBA
+}
+
+// ZoneColor sets up to four back light zones. They show once a Commit follows.
+type ZoneColor struct {
+	Index byte
+	Zones []Zone
+}
+
+// Commit shows the zone colours set since the last commit.
+type Commit struct {
+	Index byte
+}
+
+// Query asks a light for a value, such as its brightness. Function is in
+// function<<4 | softwareID form.
+type Query struct {
+	Target   LightTarget
+	Index    byte
+	Function byte
+}
+
+// report starts a 20-byte HID++ long report.
+func report(index, function byte, params ...byte) []byte {
+	b := make([]byte, byteLength)
+	copy(b, []byte{0x11, 0xff, index, function})
+	copy(b[4:], params)
+	return b
+}
+
+// checkReport returns an error unless b is an HID++ long report for one of functions.
+func checkReport(b []byte, functions ...byte) error {
+	if len(b) < 6 || b[0] != 0x11 {
+		return ErrShortReport
+	}
+	for _, f := range functions {
+		if b[3] == f {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: function 0x%02x", ErrUnknownCommand, b[3])
+}
+
+func targetName(t LightTarget) string {
+	switch t {
+	case FrontLight:
+		return "FrontLight"
+	case BackLight:
+		return "BackLight"
+	}
+	return fmt.Sprintf("Light(0x%02x)", byte(t))
+}
+
+func (c Power) MarshalBinary() ([]byte, error) {
+	var on byte
+	if c.On {
+		on = 0x01
+	}
+	if c.Target == BackLight {
+		return report(c.Index, setBackPower, on), nil
+	}
+	return report(c.Index, setFrontPower, on), nil
+}
+
+func (c *Power) UnmarshalBinary(b []byte) error {
+	if err := checkReport(b, setFrontPower, setBackPower); err != nil {
+		return err
+	}
+	c.Target = FrontLight
+	if b[3] == setBackPower {
+		c.Target = BackLight
+	}
+	c.Index, c.On = b[2], b[4] != 0x00
+	return nil
+}
+
+func (c Power) String() string {
+	if c.On {
+		return targetName(c.Target) + " Power on"
+	}
+	return targetName(c.Target) + " Power off"
+}
+
+func (c Brightness) MarshalBinary() ([]byte, error) {
+	if c.Target == BackLight {
+		return report(c.Index, setBackBrightness, byte(c.Value>>8), byte(c.Value)), nil
+	}
+	return report(c.Index, setFrontBrightness, byte(c.Value>>8), byte(c.Value)), nil
+}
+
+func (c *Brightness) UnmarshalBinary(b []byte) error {
+	if err := checkReport(b, setFrontBrightness, setBackBrightness); err != nil {
+		return err
+	}
+	c.Target = FrontLight
+	if b[3] == setBackBrightness {
+		c.Target = BackLight
+	}
+	c.Index, c.Value = b[2], uint16(b[4])<<8|uint16(b[5])
+	return nil
+}
+
+func (c Brightness) String() string {
+	if c.Target == BackLight {
+		return fmt.Sprintf("%s Brightness %d%%", targetName(c.Target), c.Value)
+	}
+	return fmt.Sprintf("%s Brightness %dlm", targetName(c.Target), c.Value)
+}
+
+func (c Temperature) MarshalBinary() ([]byte, error) {
+	return report(c.Index, setFrontTemperature, byte(c.Kelvin>>8), byte(c.Kelvin)), nil
+}
+
+func (c *Temperature) UnmarshalBinary(b []byte) error {
+	if err := checkReport(b, setFrontTemperature); err != nil {
+		return err
+	}
+	c.Index, c.Kelvin = b[2], uint16(b[4])<<8|uint16(b[5])
+	return nil
+}
+
+func (c Temperature) String() string {
+	return fmt.Sprintf("FrontLight Temperature %dK", c.Kelvin)
+}
+
+func (c ZoneColor) MarshalBinary() ([]byte, error) {
+	if len(c.Zones) > zonesPerReport {
+		return nil, fmt.Errorf("%d zones don't fit in one report of %d", len(c.Zones), zonesPerReport)
+	}
+	b := report(c.Index, setZones)
+	for slot := 0; slot < zonesPerReport; slot++ {
+		at := 4 + slot*4
+		if slot >= len(c.Zones) {
+			b[at] = unusedZone
+			continue
+		}
+		z := c.Zones[slot]
+		// The device freaks out if RGB values are 0, clamp to 1
+		copy(b[at:], []byte{z.Number, max(z.Color.R, 1), max(z.Color.G, 1), max(z.Color.B, 1)})
+	}
+	return b, nil
+}
+
+func (c *ZoneColor) UnmarshalBinary(b []byte) error {
+	if err := checkReport(b, setZones); err != nil {
+		return err
+	}
+	c.Index, c.Zones = b[2], nil
+	for at := 4; at+3 < len(b) && at < 4+zonesPerReport*4; at += 4 {
+		if b[at] == unusedZone {
+			continue
+		}
+		c.Zones = append(c.Zones, Zone{Number: b[at], Color: color.RGBA{R: b[at+1], G: b[at+2], B: b[at+3], A: 0xff}})
+	}
+	return nil
+}
+
+func (c ZoneColor) String() string {
+	var s strings.Builder
+	s.WriteString("BackLight ZoneColor")
+	for _, z := range c.Zones {
+		fmt.Fprintf(&s, " zone=%d #%02x%02x%02x", z.Number, z.Color.R, z.Color.G, z.Color.B)
+	}
+	return s.String()
+}
+
+func (c Commit) MarshalBinary() ([]byte, error) {
+	return report(c.Index, commitFrames, 0x00, 0x00, 0x01), nil
+}
+
+func (c *Commit) UnmarshalBinary(b []byte) error {
+	if err := checkReport(b, commitFrames); err != nil {
+		return err
+	}
+	c.Index = b[2]
+	return nil
+}
+
+func (c Commit) String() string {
+	return "BackLight Commit"
+}
+
+func (c Query) MarshalBinary() ([]byte, error) {
+	return report(c.Index, c.Function), nil
+}
+
+// UnmarshalBinary reads any request as a Query. The report doesn't say which
+// light it is for; Decode fills in Target from the feature index.
+func (c *Query) UnmarshalBinary(b []byte) error {
+	if len(b) < 4 || b[0] != 0x11 {
+		return ErrShortReport
+	}
+	c.Index, c.Function = b[2], b[3]
+	return nil
+}
+
+func (c Query) String() string {
+	names := map[LightTarget]map[byte]string{
+		FrontLight: {getFrontPower: "GetPower", getFrontBrightness: "GetBrightness", getFrontTemperature: "GetTemperature"},
+		BackLight:  {getBackPower: "GetPower", getBackBrightness: "GetBrightness"},
+	}
+	if name, ok := names[c.Target][c.Function]; ok {
+		return targetName(c.Target) + " " + name
+	}
+	return fmt.Sprintf("Query feature 0x%02x function 0x%02x", c.Index, c.Function)
+}
+
+// Decode reads a report sent to a Litra Beam LX at its default feature
+// indices. Use Model.Decode for other lights.
+func Decode(b []byte) (Command, error) {
+	return LitraBeamLX.Decode(b)
+}
+
+// Decode reads a report sent to one of the model's lights back into a Command.
+func (m Model) Decode(b []byte) (Command, error) {
+	if len(b) < 4 || b[0] != 0x11 {
+		return nil, ErrShortReport
+	}
+
+	var functions map[byte]func() (Command, error)
+	unmarshal := func(c interface {
+		Command
+		UnmarshalBinary([]byte) error
+	}) func() (Command, error) {
+		return func() (Command, error) {
+			return c, c.UnmarshalBinary(b)
+		}
+	}
+	query := func(target LightTarget) func() (Command, error) {
+		return func() (Command, error) {
+			return Query{Target: target, Index: b[2], Function: b[3]}, nil
+		}
+	}
+
+	switch {
+	case m.FrontIndex != 0 && b[2] == m.FrontIndex:
+		functions = map[byte]func() (Command, error){
+			setFrontPower:       unmarshal(&Power{}),
+			setFrontBrightness:  unmarshal(&Brightness{}),
+			setFrontTemperature: unmarshal(&Temperature{}),
+			getFrontPower:       query(FrontLight),
+			getFrontBrightness:  query(FrontLight),
+			getFrontTemperature: query(FrontLight),
+		}
+	case m.BackIndex != 0 && b[2] == m.BackIndex:
+		functions = map[byte]func() (Command, error){
+			setBackPower:      unmarshal(&Power{}),
+			setBackBrightness: unmarshal(&Brightness{}),
+			getBackPower:      query(BackLight),
+			getBackBrightness: query(BackLight),
+		}
+	case m.BackColorIndex != 0 && b[2] == m.BackColorIndex:
+		functions = map[byte]func() (Command, error){
+			setZones:     unmarshal(&ZoneColor{}),
+			commitFrames: unmarshal(&Commit{}),
+		}
+	}
+
+	decode, ok := functions[b[3]]
+	if !ok {
+		return nil, fmt.Errorf("%w: feature 0x%02x function 0x%02x", ErrUnknownCommand, b[2], b[3])
+	}
+	c, err := decode()
+	if err != nil {
+		return nil, err
+	}
+	return deref(c), nil
+}
+
+// deref returns the command a pointer points to, so Decode's results compare
+// equal to commands built by value.
+func deref(c Command) Command {
+	switch c := c.(type) {
+	case *Power:
+		return *c
+	case *Brightness:
+		return *c
+	case *Temperature:
+		return *c
+	case *ZoneColor:
+		return *c
+	case *Commit:
+		return *c
+	}
+	return c
+}
+
+// Describe names a report for logs: the command it decodes to, or its bytes.
+func (m Model) Describe(b []byte) string {
+	if c, err := m.Decode(b); err == nil {
+		return c.String()
+	}
+	return fmt.Sprintf("% x", b)
+}
@@ -0,0 +1,124 @@
+package logitech_hid
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestCommandsRoundTrip(t *testing.T) {
+	red := color.RGBA{R: 0xff, G: 0x01, B: 0x01, A: 0xff}
+	tests := []struct {
+		command  Command
+		expected string
+	}{
+		{Power{Target: FrontLight, Index: 0x06, On: true}, "FrontLight Power on"},
+		{Power{Target: BackLight, Index: 0x0a}, "BackLight Power off"},
+		{Brightness{Target: FrontLight, Index: 0x06, Value: 200}, "FrontLight Brightness 200lm"},
+		{Brightness{Target: BackLight, Index: 0x0a, Value: 75}, "BackLight Brightness 75%"},
+		{Temperature{Index: 0x06, Kelvin: 4000}, "FrontLight Temperature 4000K"},
+		{ZoneColor{Index: 0x0c, Zones: []Zone{{3, red}}}, "BackLight ZoneColor zone=3 #ff0101"},
+		{Commit{Index: 0x0c}, "BackLight Commit"},
+		{Query{Target: FrontLight, Index: 0x06, Function: getFrontBrightness}, "FrontLight GetBrightness"},
+		{Query{Target: BackLight, Index: 0x0a, Function: getBackPower}, "BackLight GetPower"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := tt.command.String(); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+			b, err := tt.command.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary: %v", err)
+			}
+			if len(b) != byteLength {
+				t.Errorf("Expected a %d byte report, got %d", byteLength, len(b))
+			}
+			decoded, err := Decode(b)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, tt.command) {
+				t.Errorf("Expected %#v, decoded %#v", tt.command, decoded)
+			}
+		})
+	}
+}
+
+func TestCommandsMatchBuilders(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  Command
+		expected []byte
+	}{
+		{"power on", Power{Target: BackLight, Index: BackLightFID, On: true}, ConvertLightsOnTarget(BackLight, BackLightFID)},
+		{"brightness", Brightness{Target: FrontLight, Index: FrontLightFID, Value: 0xfa}, mustBytes(ConvertBrightness(100))},
+		{"temperature", Temperature{Index: FrontLightFID, Kelvin: 2700}, mustBytes(ConvertTemperature(2700))},
+		{"commit", Commit{Index: BackLightColorFID}, ConvertBackColorCommit()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.command.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(b, tt.expected) {
+				t.Errorf("Expected % x, got % x", tt.expected, b)
+			}
+		})
+	}
+}
+
+func TestZoneColorMarksUnusedSlots(t *testing.T) {
+	b, err := ZoneColor{Index: 0x0c, Zones: []Zone{{5, color.RGBA{A: 0xff}}}}.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{0x11, 0xff, 0x0c, 0x1b, 0x05, 0x01, 0x01, 0x01, 0xff}
+	if !bytes.Equal(b[:len(expected)], expected) {
+		t.Errorf("Expected % x, got % x", expected, b[:len(expected)])
+	}
+
+	tooMany := make([]Zone, zonesPerReport+1)
+	if _, err := (ZoneColor{Zones: tooMany}).MarshalBinary(); err == nil {
+		t.Error("Expected an error for more zones than one report holds")
+	}
+}
+
+func TestModelDecodeUsesItsIndices(t *testing.T) {
+	b, _ := Power{Target: FrontLight, Index: LitraGlow.FrontIndex, On: true}.MarshalBinary()
+
+	c, err := LitraGlow.Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.String() != "FrontLight Power on" {
+		t.Errorf("Expected the Glow's front light, got %q", c)
+	}
+
+	// The Beam LX has nothing at the Glow's front light index.
+	if _, err := Decode(b); !errors.Is(err, ErrUnknownCommand) {
+		t.Errorf("Expected ErrUnknownCommand, got %v", err)
+	}
+}
+
+func TestDescribeFallsBackToHex(t *testing.T) {
+	b := []byte{0x11, 0xff, 0x0c, 0x5e, 0x01}
+	if got := LitraBeamLX.Describe(b); got != "11 ff 0c 5e 01" {
+		t.Errorf("Expected a hex dump, got %q", got)
+	}
+	if _, err := Decode([]byte{0x11}); !errors.Is(err, ErrShortReport) {
+		t.Errorf("Expected ErrShortReport, got %v", err)
+	}
+}
+
+func mustBytes(b []byte, err error) []byte {
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
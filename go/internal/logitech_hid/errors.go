@@ -0,0 +1,77 @@
+package logitech_hid
+
+import "fmt"
+
+// ErrorCode is an HID++ 2.0 error code, sent by a light that refused a request.
+type ErrorCode byte
+
+const (
+	ErrUnknown             ErrorCode = 0x01
+	ErrInvalidArgument     ErrorCode = 0x02
+	ErrOutOfRange          ErrorCode = 0x03
+	ErrHardware            ErrorCode = 0x04
+	ErrLogitechInternal    ErrorCode = 0x05
+	ErrInvalidFeatureIndex ErrorCode = 0x06
+	ErrInvalidFunction     ErrorCode = 0x07
+	ErrBusy                ErrorCode = 0x08
+	ErrNotSupported        ErrorCode = 0x09
+)
+
+// String names the code briefly enough for a key title.
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrUnknown:
+		return "unknown"
+	case ErrInvalidArgument:
+		return "bad argument"
+	case ErrOutOfRange:
+		return "out of range"
+	case ErrHardware:
+		return "hardware"
+	case ErrLogitechInternal:
+		return "internal"
+	case ErrInvalidFeatureIndex:
+		return "bad feature"
+	case ErrInvalidFunction:
+		return "bad function"
+	case ErrBusy:
+		return "busy"
+	case ErrNotSupported:
+		return "unsupported"
+	}
+	return fmt.Sprintf("0x%02x", byte(c))
+}
+
+func (c ErrorCode) Error() string {
+	return "HID++ error: " + c.String()
+}
+
+// DeviceError is an HID++ error report: the light received a request and
+// refused it. It unwraps to its ErrorCode, so callers can test for one with
+// errors.Is.
+type DeviceError struct {
+	FeatureIndex byte
+	Function     byte // function<<4 | softwareID of the refused request
+	Code         ErrorCode
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("HID++ error %s for feature 0x%02x function 0x%02x", e.Code.String(), e.FeatureIndex, e.Function)
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Code
+}
+
+// Is makes a light refusing a function it doesn't support count as
+// ErrUnsupported, like a model that lacks the feature.
+func (e *DeviceError) Is(target error) bool {
+	return target == ErrUnsupported && e.Code == ErrNotSupported
+}
+
+// ExpectsAnswer reports whether the light answers report, which is true of
+// every HID++ request. The light echoes the feature index and function of a
+// set command to acknowledge it.
+func ExpectsAnswer(report []byte) bool {
+	return len(report) > 3 && report[0] == 0x11
+}
@@ -0,0 +1,44 @@
+package logitech_hid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeviceErrorCodes(t *testing.T) {
+	r, err := ParseResponse(extendByteSlice([]byte{0x11, 0xff, 0xff, 0x0a, 0x4b, 0x06}))
+	if !errors.Is(err, ErrInvalidFeatureIndex) {
+		t.Fatalf("Expected ErrInvalidFeatureIndex, got %v", err)
+	}
+	if r.FeatureIndex != 0x0a || r.Function != 0x4b {
+		t.Errorf("Expected the error to refer to 0x0a 0x4b, got %+v", r)
+	}
+	if got := err.Error(); got != "HID++ error bad feature for feature 0x0a function 0x4b" {
+		t.Errorf("Unexpected message %q", got)
+	}
+	if errors.Is(err, ErrUnsupported) {
+		t.Error("Expected a bad feature index not to count as unsupported")
+	}
+
+	// A light refusing a function it doesn't have is like a model lacking the feature.
+	_, err = ParseResponse(extendByteSlice([]byte{0x11, 0xff, 0xff, 0x06, 0x9c, 0x09}))
+	if !errors.Is(err, ErrNotSupported) || !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrNotSupported and ErrUnsupported, got %v", err)
+	}
+
+	// Codes this package doesn't know still come through.
+	_, err = ParseResponse(extendByteSlice([]byte{0x11, 0xff, 0xff, 0x06, 0x9c, 0x42}))
+	if !errors.Is(err, ErrorCode(0x42)) || ErrorCode(0x42).String() != "0x42" {
+		t.Errorf("Expected error code 0x42, got %v", err)
+	}
+}
+
+func TestExpectsAnswer(t *testing.T) {
+	on, _ := LitraBeamLX.LightsOn(FrontLight)
+	if !ExpectsAnswer(on) {
+		t.Error("Expected a set command to be answered")
+	}
+	if ExpectsAnswer([]byte{0x11}) || ExpectsAnswer([]byte{0x10, 0xff, 0x06, 0x1c}) {
+		t.Error("Expected no answer to a report that isn't an HID++ long report")
+	}
+}
@@ -0,0 +1,158 @@
+package logitech_hid
+
+import "fmt"
+
+// Notifications a light sends on its own, e.g. when its buttons are pressed.
+// They carry software ID 0, which requests never use.
+const (
+	frontPowerEvent       = 0x00 // Illumination illuminationChangeEvent
+	frontBrightnessEvent  = 0x10 // Illumination brightnessChangeEvent
+	frontTemperatureEvent = 0x20 // Illumination colorTemperatureChangeEvent
+	backBrightnessEvent   = 0x00 // BrightnessControl brightnessChangeEvent
+	backPowerEvent        = 0x10 // BrightnessControl illuminationChangeEvent
+)
+
+// EventKind says what changed on a light.
+type EventKind int
+
+const (
+	PowerChanged EventKind = iota + 1
+	BrightnessChanged
+	TemperatureChanged
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case PowerChanged:
+		return "power"
+	case BrightnessChanged:
+		return "brightness"
+	case TemperatureChanged:
+		return "temperature"
+	}
+	return "unknown"
+}
+
+// Event is a change a light reported on its own.
+type Event struct {
+	Target LightTarget
+	Kind   EventKind
+
+	// Only the field matching Kind is set.
+	On          bool
+	Brightness  uint8  // 1-100%
+	Temperature uint16 // Kelvin
+}
+
+// IsNotification reports whether the light sent r on its own rather than in
+// answer to a request.
+func (r Response) IsNotification() bool {
+	return r.Function&0x0f == 0
+}
+
+// DecodeEvent reads a notification from one of the model's lights. It
+// reports false for anything else, including notifications from features we
+// don't use.
+func (m Model) DecodeEvent(r Response) (Event, bool) {
+	if !r.IsNotification() || len(r.Params) < 1 {
+		return Event{}, false
+	}
+	var u16 uint16
+	if len(r.Params) >= 2 {
+		u16 = uint16(r.Params[0])<<8 | uint16(r.Params[1])
+	}
+
+	switch {
+	case m.FrontIndex != 0 && r.FeatureIndex == m.FrontIndex:
+		e := Event{Target: FrontLight}
+		switch r.Function {
+		case frontPowerEvent:
+			e.Kind, e.On = PowerChanged, r.Params[0] != 0x00
+		case frontBrightnessEvent:
+			e.Kind, e.Brightness = BrightnessChanged, m.Percentage(u16)
+		case frontTemperatureEvent:
+			e.Kind, e.Temperature = TemperatureChanged, u16
+		default:
+			return Event{}, false
+		}
+		return e, true
+
+	case m.BackIndex != 0 && r.FeatureIndex == m.BackIndex:
+		e := Event{Target: BackLight}
+		switch r.Function {
+		case backPowerEvent:
+			e.Kind, e.On = PowerChanged, r.Params[0] != 0x00
+		case backBrightnessEvent:
+			e.Kind, e.Brightness = BrightnessChanged, uint8(min(max(u16, minPercentage), maxPercentage))
+		default:
+			return Event{}, false
+		}
+		return e, true
+	}
+	return Event{}, false
+}
+
+// Set functions, in function<<4 | softwareID form, as built by bytes.go.
+const (
+	setFrontPower       = 0x1c
+	setFrontBrightness  = 0x4c
+	setFrontTemperature = 0x9c
+	setBackPower        = 0x4b
+	setBackBrightness   = 0x2b
+)
+
+// DecodeSetting reads a set command built for one of the model's lights as
+// the change it makes, so the plugin can remember the state it left the light
+// in. It reports false for anything else, including colour commands.
+func (m Model) DecodeSetting(cmd []byte) (Event, bool) {
+	if len(cmd) < 6 || cmd[0] != 0x11 {
+		return Event{}, false
+	}
+	u16 := uint16(cmd[4])<<8 | uint16(cmd[5])
+
+	switch {
+	case m.FrontIndex != 0 && cmd[2] == m.FrontIndex:
+		e := Event{Target: FrontLight}
+		switch cmd[3] {
+		case setFrontPower:
+			e.Kind, e.On = PowerChanged, cmd[4] != 0x00
+		case setFrontBrightness:
+			e.Kind, e.Brightness = BrightnessChanged, m.Percentage(u16)
+		case setFrontTemperature:
+			e.Kind, e.Temperature = TemperatureChanged, u16
+		default:
+			return Event{}, false
+		}
+		return e, true
+
+	case m.BackIndex != 0 && cmd[2] == m.BackIndex:
+		e := Event{Target: BackLight}
+		switch cmd[3] {
+		case setBackPower:
+			e.Kind, e.On = PowerChanged, cmd[4] != 0x00
+		case setBackBrightness:
+			e.Kind, e.Brightness = BrightnessChanged, uint8(min(max(u16, minPercentage), maxPercentage))
+		default:
+			return Event{}, false
+		}
+		return e, true
+	}
+	return Event{}, false
+}
+
+// Restore builds the command that puts the light back into the state e
+// describes, the inverse of DecodeSetting.
+func (m Model) Restore(e Event) ([]byte, error) {
+	switch e.Kind {
+	case PowerChanged:
+		if e.On {
+			return m.LightsOn(e.Target)
+		}
+		return m.LightsOff(e.Target)
+	case BrightnessChanged:
+		return m.Brightness(e.Target, e.Brightness)
+	case TemperatureChanged:
+		return m.Temperature(e.Target, e.Temperature)
+	}
+	return nil, fmt.Errorf("nothing to restore for a %s change", e.Kind)
+}
@@ -0,0 +1,115 @@
+package logitech_hid
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    Model
+		report   []byte
+		expected Event
+	}{
+		{
+			"front power",
+			LitraBeamLX,
+			[]byte{0x11, 0xff, 0x06, 0x00, 0x01},
+			Event{Target: FrontLight, Kind: PowerChanged, On: true},
+		},
+		{
+			"front brightness",
+			LitraBeamLX,
+			[]byte{0x11, 0xff, 0x06, 0x10, 0x00, 0x85},
+			Event{Target: FrontLight, Kind: BrightnessChanged, Brightness: 50},
+		},
+		{
+			"front temperature",
+			LitraGlow,
+			[]byte{0x11, 0xff, 0x04, 0x20, 0x0f, 0xa0},
+			Event{Target: FrontLight, Kind: TemperatureChanged, Temperature: 4000},
+		},
+		{
+			"back power",
+			LitraBeamLX,
+			[]byte{0x11, 0xff, 0x0a, 0x10, 0x00},
+			Event{Target: BackLight, Kind: PowerChanged},
+		},
+		{
+			"back brightness",
+			LitraBeamLX,
+			[]byte{0x11, 0xff, 0x0a, 0x00, 0x00, 0x46},
+			Event{Target: BackLight, Kind: BrightnessChanged, Brightness: 70},
+		},
+	}
+
+	for _, test := range tests {
+		r, err := ParseResponse(extendByteSlice(test.report))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, ok := test.model.DecodeEvent(r)
+		if !ok || e != test.expected {
+			t.Errorf("%s: expected %+v, got %+v (ok=%v)", test.name, test.expected, e, ok)
+		}
+	}
+}
+
+func TestDecodeEventIgnoresAnswersAndOtherFeatures(t *testing.T) {
+	reports := [][]byte{
+		{0x11, 0xff, 0x06, 0x3c, 0x00, 0x85}, // answer to get brightness
+		{0x11, 0xff, 0x0c, 0x00, 0x01, 0x02}, // colour feature
+		{0x11, 0xff, 0x06, 0x30, 0x00, 0x00}, // unknown event
+	}
+	for _, report := range reports {
+		r, _ := ParseResponse(extendByteSlice(report))
+		if e, ok := LitraBeamLX.DecodeEvent(r); ok {
+			t.Errorf("Expected % x not to be an event, got %+v", report[:4], e)
+		}
+	}
+
+	// The Glow has no back light, so index 0x0a means nothing to it.
+	r, _ := ParseResponse(extendByteSlice([]byte{0x11, 0xff, 0x0a, 0x10, 0x01}))
+	if _, ok := LitraGlow.DecodeEvent(r); ok {
+		t.Error("Expected no back light event from a Glow")
+	}
+}
+
+func TestDecodeSettingAndRestore(t *testing.T) {
+	events := []Event{
+		{Target: FrontLight, Kind: PowerChanged, On: true},
+		{Target: FrontLight, Kind: PowerChanged},
+		{Target: FrontLight, Kind: BrightnessChanged, Brightness: 60},
+		{Target: FrontLight, Kind: TemperatureChanged, Temperature: 3200},
+		{Target: BackLight, Kind: PowerChanged, On: true},
+		{Target: BackLight, Kind: BrightnessChanged, Brightness: 35},
+	}
+	for _, want := range events {
+		cmd, err := LitraBeamLX.Restore(want)
+		if err != nil {
+			t.Fatalf("%+v: %v", want, err)
+		}
+		if got, ok := LitraBeamLX.DecodeSetting(cmd); !ok || got != want {
+			t.Errorf("Expected % x to decode as %+v, got %+v (ok=%v)", cmd[:6], want, got, ok)
+		}
+	}
+
+	// Commands built by the legacy helpers decode too.
+	if e, ok := LitraBeamLX.DecodeSetting(ConvertLightsOn()); !ok || e.Kind != PowerChanged || !e.On {
+		t.Errorf("Expected ConvertLightsOn to decode as front power on, got %+v", e)
+	}
+
+	// Colours and reads aren't settings.
+	colors, _ := LitraBeamLX.Color(BackLight, 255, 0, 0)
+	get, _ := LitraBeamLX.GetPower(FrontLight)
+	for _, cmd := range append(colors, get) {
+		if e, ok := LitraBeamLX.DecodeSetting(cmd); ok {
+			t.Errorf("Expected % x not to be a setting, got %+v", cmd[:4], e)
+		}
+	}
+
+	if _, err := LitraGlow.Restore(Event{Target: BackLight, Kind: PowerChanged}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported restoring a Glow's back light, got %v", err)
+	}
+}
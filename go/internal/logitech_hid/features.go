@@ -0,0 +1,86 @@
+package logitech_hid
+
+import "fmt"
+
+// HID++ 2.0 feature IDs. A light lists the features it has at indices that
+// can change between firmware versions, so they are looked up through IRoot
+// and IFeatureSet when a light connects.
+const (
+	FeatureRoot              uint16 = 0x0000 // IRoot, always at index 0
+	FeatureSet               uint16 = 0x0001 // IFeatureSet
+	FeatureIllumination      uint16 = 0x1990 // front light
+	FeatureBrightnessControl uint16 = 0x8040 // back light power and brightness
+	FeaturePerKeyLighting    uint16 = 0x8081 // back light zone colours
+)
+
+const rootIndex = 0x00
+
+// Discovery functions, in function<<4 | softwareID form.
+const (
+	getFeature   = 0x0d // IRoot getFeature
+	getCount     = 0x0d // IFeatureSet getCount
+	getFeatureID = 0x1d // IFeatureSet getFeatureID
+)
+
+// Features maps feature IDs to the indices one light reported for them.
+type Features map[uint16]byte
+
+// GetFeatureIndex asks IRoot for the index of a feature.
+func GetFeatureIndex(featureID uint16) []byte {
+	return report(rootIndex, getFeature, byte(featureID>>8), byte(featureID))
+}
+
+// DecodeFeatureIndex reads the answer to GetFeatureIndex. The light answers
+// index 0 for a feature it doesn't have.
+func DecodeFeatureIndex(r Response) (byte, error) {
+	if len(r.Params) < 1 {
+		return 0, ErrShortReport
+	}
+	if r.Params[0] == rootIndex {
+		return 0, fmt.Errorf("feature not present: %w", ErrUnsupported)
+	}
+	return r.Params[0], nil
+}
+
+// GetFeatureCount asks IFeatureSet, at index, how many features the light has
+// besides IRoot.
+func GetFeatureCount(index byte) []byte {
+	return report(index, getCount)
+}
+
+// DecodeFeatureCount reads the answer to GetFeatureCount.
+func DecodeFeatureCount(r Response) (int, error) {
+	if len(r.Params) < 1 {
+		return 0, ErrShortReport
+	}
+	return int(r.Params[0]), nil
+}
+
+// GetFeatureID asks IFeatureSet, at index, which feature is at featureIndex.
+func GetFeatureID(index, featureIndex byte) []byte {
+	return report(index, getFeatureID, featureIndex)
+}
+
+// DecodeFeatureID reads the answer to GetFeatureID.
+func DecodeFeatureID(r Response) (uint16, error) {
+	if len(r.Params) < 2 {
+		return 0, ErrShortReport
+	}
+	return uint16(r.Params[0])<<8 | uint16(r.Params[1]), nil
+}
+
+// WithFeatures returns the model with the feature indices one light reported.
+// A light the model table lists but whose feature the light didn't report is
+// treated as missing, so commands for it fail instead of going to the wrong feature.
+func (m Model) WithFeatures(features Features) Model {
+	resolve := func(current byte, featureID uint16) byte {
+		if current == 0 {
+			return 0
+		}
+		return features[featureID]
+	}
+	m.FrontIndex = resolve(m.FrontIndex, FeatureIllumination)
+	m.BackIndex = resolve(m.BackIndex, FeatureBrightnessControl)
+	m.BackColorIndex = resolve(m.BackColorIndex, FeaturePerKeyLighting)
+	return m
+}
@@ -0,0 +1,79 @@
+package logitech_hid
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDiscoveryCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   []byte
+		expected []byte
+	}{
+		{"getFeature", GetFeatureIndex(FeatureIllumination), []byte{0x11, 0xff, 0x00, 0x0d, 0x19, 0x90}},
+		{"getCount", GetFeatureCount(0x01), []byte{0x11, 0xff, 0x01, 0x0d}},
+		{"getFeatureID", GetFeatureID(0x01, 0x0a), []byte{0x11, 0xff, 0x01, 0x1d, 0x0a}},
+	}
+
+	for _, test := range tests {
+		if !bytes.Equal(test.result, extendByteSlice(test.expected)) {
+			t.Errorf("%s: expected % x, got % x", test.name, test.expected, test.result[:6])
+		}
+	}
+}
+
+func TestDecodeDiscovery(t *testing.T) {
+	index, err := DecodeFeatureIndex(Response{Params: []byte{0x01, 0x00, 0x02}})
+	if err != nil || index != 0x01 {
+		t.Errorf("Expected index 0x01, got 0x%02x (%v)", index, err)
+	}
+	if _, err := DecodeFeatureIndex(Response{Params: []byte{0x00}}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for a missing feature, got %v", err)
+	}
+
+	count, err := DecodeFeatureCount(Response{Params: []byte{0x0c}})
+	if err != nil || count != 12 {
+		t.Errorf("Expected 12 features, got %d (%v)", count, err)
+	}
+
+	id, err := DecodeFeatureID(Response{Params: []byte{0x80, 0x81}})
+	if err != nil || id != FeaturePerKeyLighting {
+		t.Errorf("Expected 0x8081, got 0x%04x (%v)", id, err)
+	}
+}
+
+func TestWithFeatures(t *testing.T) {
+	// A firmware update moved every feature along by one.
+	m := LitraBeamLX.WithFeatures(Features{
+		FeatureIllumination:      0x07,
+		FeatureBrightnessControl: 0x0b,
+		FeaturePerKeyLighting:    0x0d,
+	})
+
+	on, _ := m.LightsOn(FrontLight)
+	if on[2] != 0x07 {
+		t.Errorf("Expected the front light at 0x07, got 0x%02x", on[2])
+	}
+	back, _ := m.Brightness(BackLight, 50)
+	if back[2] != 0x0b {
+		t.Errorf("Expected the back light at 0x0b, got 0x%02x", back[2])
+	}
+	color, _ := m.Color(BackLight, 1, 2, 3)
+	if color[0][2] != 0x0d || color[len(color)-1][2] != 0x0d {
+		t.Errorf("Expected the colour feature at 0x0d, got 0x%02x", color[0][2])
+	}
+
+	// A Glow reporting a BrightnessControl feature still has no back light.
+	glow := LitraGlow.WithFeatures(Features{FeatureIllumination: 0x05, FeatureBrightnessControl: 0x06})
+	if glow.FrontIndex != 0x05 || glow.Supports(BackLight) {
+		t.Errorf("Unexpected Glow indices %+v", glow)
+	}
+
+	// A light that doesn't report a feature doesn't get commands for it.
+	noColor := LitraBeamLX.WithFeatures(Features{FeatureIllumination: 0x06, FeatureBrightnessControl: 0x0a})
+	if _, err := noColor.Color(BackLight, 1, 2, 3); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
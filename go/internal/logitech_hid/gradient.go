@@ -0,0 +1,120 @@
+package logitech_hid
+
+import (
+	"image/color"
+	"math"
+	"slices"
+)
+
+// Frame is a colour for each back light zone, zone 1 first.
+type Frame [BackLightZoneCount]color.RGBA
+
+// Fill returns a frame with every zone set to c.
+func Fill(c color.RGBA) Frame {
+	var f Frame
+	for i := range f {
+		f[i] = c
+	}
+	return f
+}
+
+// zones lists the first count zones of the frame.
+func (f Frame) zones(count int) []Zone {
+	zones := make([]Zone, min(count, len(f)))
+	for i := range zones {
+		zones[i] = Zone{uint8(i + 1), f[i]}
+	}
+	return zones
+}
+
+// Stop is one colour of a gradient, at a position from 0 (zone 1) to 1 (the
+// last zone).
+type Stop struct {
+	Position float64
+	Color    color.RGBA
+}
+
+// Gradient blends between any number of colour stops. Zones before the first
+// stop or after the last take that stop's colour.
+type Gradient struct {
+	Stops []Stop
+	Space Interpolation // SRGB unless set
+}
+
+// twoColors blends from one colour at zone 1 to another at the last zone.
+func twoColors(from, to color.RGBA) Gradient {
+	return Gradient{Stops: []Stop{{0, from}, {1, to}}}
+}
+
+// At returns the gradient's colour at position t, from 0 to 1.
+func (g Gradient) At(t float64) color.RGBA {
+	stops := g.sorted()
+	switch {
+	case len(stops) == 0:
+		return color.RGBA{}
+	case t <= stops[0].Position:
+		return stops[0].Color
+	case t >= stops[len(stops)-1].Position:
+		return stops[len(stops)-1].Color
+	}
+
+	i := 1
+	for stops[i].Position < t {
+		i++
+	}
+	from, to := stops[i-1], stops[i]
+	span := to.Position - from.Position
+	if span <= 0 {
+		return to.Color
+	}
+	return g.Space.mix(from.Color, to.Color, (t-from.Position)/span)
+}
+
+// Frame samples the gradient once per zone, zone 1 at 0 and the last zone at 1.
+func (g Gradient) Frame() Frame {
+	var f Frame
+	for i := range f {
+		f[i] = g.At(float64(i) / float64(len(f)-1))
+	}
+	return f
+}
+
+func (g Gradient) sorted() []Stop {
+	stops := slices.Clone(g.Stops)
+	slices.SortStableFunc(stops, func(a, b Stop) int {
+		switch {
+		case a.Position < b.Position:
+			return -1
+		case a.Position > b.Position:
+			return 1
+		}
+		return 0
+	})
+	return stops
+}
+
+// mix blends linearly from a to b, t from 0 to 1. It rounds, so a gradient
+// and its mirror image give the same colours.
+func mix(a, b color.RGBA, t float64) color.RGBA {
+	channel := func(x, y uint8) uint8 {
+		return uint8(math.Round(float64(x)*(1-t) + float64(y)*t))
+	}
+	return color.RGBA{R: channel(a.R, b.R), G: channel(a.G, b.G), B: channel(a.B, b.B), A: 0xff}
+}
+
+// ConvertBackColorFrame sets each of the 7 zones to its colour in frame,
+// including the final commit command.
+func ConvertBackColorFrame(frame Frame) [][]byte {
+	commands := ConvertBackColorZones(frame.zones(BackLightZoneCount))
+	return append(commands, ConvertBackColorCommit())
+}
+
+// Frame sets each back light zone to its colour in frame, including the commit command.
+func (m Model) Frame(target LightTarget, frame Frame) ([][]byte, error) {
+	if target != BackLight || !m.HasColor() {
+		return nil, m.unsupported("RGB " + target.String())
+	}
+
+	commands := backColorZones(m.BackColorIndex, frame.zones(m.ZoneCount))
+	return append(commands, backColorCommit(m.BackColorIndex)), nil
+}
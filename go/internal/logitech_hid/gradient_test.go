@@ -0,0 +1,93 @@
+package logitech_hid
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+)
+
+var (
+	red   = color.RGBA{R: 255, A: 0xff}
+	white = color.RGBA{R: 255, G: 255, B: 255, A: 0xff}
+	blue  = color.RGBA{B: 255, A: 0xff}
+)
+
+func TestGradientAt(t *testing.T) {
+	// Stops out of order, as a user might enter them.
+	g := Gradient{Stops: []Stop{{1, blue}, {0, red}, {0.5, white}}}
+
+	tests := []struct {
+		t        float64
+		expected color.RGBA
+	}{
+		{-1, red},
+		{0, red},
+		{0.25, color.RGBA{R: 255, G: 128, B: 128, A: 0xff}},
+		{0.5, white},
+		{0.75, color.RGBA{R: 128, G: 128, B: 255, A: 0xff}},
+		{1, blue},
+		{2, blue},
+	}
+	for _, tt := range tests {
+		if got := g.At(tt.t); got != tt.expected {
+			t.Errorf("At(%v): expected %v, got %v", tt.t, tt.expected, got)
+		}
+	}
+
+	if got := (Gradient{}).At(0.5); got != (color.RGBA{}) {
+		t.Errorf("Expected no colour from an empty gradient, got %v", got)
+	}
+}
+
+func TestGradientFrameCentreOut(t *testing.T) {
+	frame := Gradient{Stops: []Stop{{0, blue}, {0.5, white}, {1, blue}}}.Frame()
+
+	if frame[3] != white {
+		t.Errorf("Expected the middle zone white, got %v", frame[3])
+	}
+	for i := range 3 {
+		if frame[i] != frame[len(frame)-1-i] {
+			t.Errorf("Expected zones %d and %d to match, got %v and %v", i+1, len(frame)-i, frame[i], frame[len(frame)-1-i])
+		}
+	}
+}
+
+func TestTwoStopGradientMatchesConvertBackColorGradient(t *testing.T) {
+	commands := ConvertBackColorFrame(twoColors(red, blue).Frame())
+	expected := ConvertBackColorGradient(255, 0, 0, 0, 0, 255)
+	if len(commands) != len(expected) {
+		t.Fatalf("Expected %d commands, got %d", len(expected), len(commands))
+	}
+	for i := range expected {
+		if string(commands[i]) != string(expected[i]) {
+			t.Errorf("Command %d: expected % x, got % x", i, expected[i], commands[i])
+		}
+	}
+}
+
+func TestModelFrame(t *testing.T) {
+	var frame Frame
+	for i := range frame {
+		frame[i] = color.RGBA{R: uint8(i * 10), G: 2, B: 3, A: 0xff}
+	}
+
+	commands, err := LitraBeamLX.Frame(BackLight, frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 3 {
+		t.Fatalf("Expected 2 zone reports and a commit, got %d", len(commands))
+	}
+	zones := LitraBeamLX.ZoneColors(commands)
+	for i := range frame {
+		want := frame[i]
+		want.R = max(want.R, 1)
+		if zones[i] != want {
+			t.Errorf("Zone %d: expected %v, got %v", i+1, want, zones[i])
+		}
+	}
+
+	if _, err := LitraGlow.Frame(BackLight, frame); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported on a Glow, got %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package logitech_hid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HID++ 2.0 features describing the light itself.
+const (
+	FeatureDeviceInformation uint16 = 0x0003 // firmware versions and serial number
+	FeatureDeviceName        uint16 = 0x0005 // marketing name
+)
+
+// Device information functions, in function<<4 | softwareID form.
+const (
+	getDeviceInfo   = 0x0d // DeviceInformation getDeviceInfo
+	getFwInfo       = 0x1d // DeviceInformation getFwInfo
+	getSerialNumber = 0x2d // DeviceInformation getSerialNumber
+	getNameCount    = 0x0d // DeviceName getDeviceNameCount
+	getName         = 0x1d // DeviceName getDeviceName
+)
+
+// Firmware entity types reported by getFwInfo.
+const fwTypeApplication = 0x00
+
+// serialNumberCapability is set in getDeviceInfo's capabilities when the
+// light supports getSerialNumber.
+const serialNumberCapability = 0x01
+
+// DeviceInfo is what a light reports about itself.
+type DeviceInfo struct {
+	Name     string // e.g. "Litra Beam LX"
+	Firmware string // main application firmware, e.g. "RQM 64.02_B0006"
+	Serial   string
+}
+
+func (d DeviceInfo) String() string {
+	return fmt.Sprintf("%s, firmware %s, serial %s", d.Name, d.Firmware, d.Serial)
+}
+
+// GetDeviceInfo asks DeviceInformation, at index, how many firmware entities
+// the light has and whether it can report its serial number.
+func GetDeviceInfo(index byte) []byte {
+	return report(index, getDeviceInfo)
+}
+
+// DecodeDeviceInfo reads the answer to GetDeviceInfo.
+func DecodeDeviceInfo(r Response) (entities int, hasSerial bool, err error) {
+	if len(r.Params) < 15 {
+		return 0, false, ErrShortReport
+	}
+	return int(r.Params[0]), r.Params[14]&serialNumberCapability != 0, nil
+}
+
+// GetFirmwareInfo asks DeviceInformation, at index, about one firmware entity.
+func GetFirmwareInfo(index, entity byte) []byte {
+	return report(index, getFwInfo, entity)
+}
+
+// DecodeFirmwareInfo reads the answer to GetFirmwareInfo. The version is
+// formatted the way Logitech's own tools show it. application reports whether
+// this entity is the main firmware rather than the bootloader or hardware.
+func DecodeFirmwareInfo(r Response) (version string, application bool, err error) {
+	if len(r.Params) < 8 {
+		return "", false, ErrShortReport
+	}
+	p := r.Params
+	name := strings.TrimRight(string(p[1:4]), "\x00 ")
+	version = fmt.Sprintf("%s %02x.%02x_B%02x%02x", name, p[4], p[5], p[6], p[7])
+	return version, p[0] == fwTypeApplication, nil
+}
+
+// GetSerialNumber asks DeviceInformation, at index, for the serial number.
+func GetSerialNumber(index byte) []byte {
+	return report(index, getSerialNumber)
+}
+
+// DecodeSerialNumber reads the answer to GetSerialNumber.
+func DecodeSerialNumber(r Response) (string, error) {
+	if len(r.Params) < 12 {
+		return "", ErrShortReport
+	}
+	return strings.TrimRight(string(r.Params[:12]), "\x00 "), nil
+}
+
+// GetNameLength asks DeviceName, at index, how long the name is.
+func GetNameLength(index byte) []byte {
+	return report(index, getNameCount)
+}
+
+// DecodeNameLength reads the answer to GetNameLength.
+func DecodeNameLength(r Response) (int, error) {
+	if len(r.Params) < 1 {
+		return 0, ErrShortReport
+	}
+	return int(r.Params[0]), nil
+}
+
+// GetNamePart asks DeviceName, at index, for the name from offset on. Each
+// answer carries as much of the name as fits in a report.
+func GetNamePart(index, offset byte) []byte {
+	return report(index, getName, offset)
+}
+
+// DecodeNamePart reads the answer to GetNamePart.
+func DecodeNamePart(r Response) string {
+	return strings.TrimRight(string(r.Params), "\x00")
+}
@@ -0,0 +1,53 @@
+package logitech_hid
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDeviceInfoCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   []byte
+		expected []byte
+	}{
+		{"getDeviceInfo", GetDeviceInfo(0x02), []byte{0x11, 0xff, 0x02, 0x0d}},
+		{"getFwInfo", GetFirmwareInfo(0x02, 0x01), []byte{0x11, 0xff, 0x02, 0x1d, 0x01}},
+		{"getSerialNumber", GetSerialNumber(0x02), []byte{0x11, 0xff, 0x02, 0x2d}},
+		{"getDeviceNameCount", GetNameLength(0x03), []byte{0x11, 0xff, 0x03, 0x0d}},
+		{"getDeviceName", GetNamePart(0x03, 0x10), []byte{0x11, 0xff, 0x03, 0x1d, 0x10}},
+	}
+
+	for _, test := range tests {
+		if !bytes.Equal(test.result, extendByteSlice(test.expected)) {
+			t.Errorf("%s: expected % x, got % x", test.name, test.expected, test.result[:5])
+		}
+	}
+}
+
+func TestDecodeDeviceInfo(t *testing.T) {
+	params := make([]byte, 16)
+	params[0] = 2     // bootloader and application
+	params[14] = 0x01 // serial number supported
+	entities, hasSerial, err := DecodeDeviceInfo(Response{Params: params})
+	if err != nil || entities != 2 || !hasSerial {
+		t.Errorf("Expected 2 entities with a serial, got %d, %v (%v)", entities, hasSerial, err)
+	}
+
+	version, app, err := DecodeFirmwareInfo(Response{Params: []byte{0x00, 'R', 'Q', 'M', 0x64, 0x02, 0x00, 0x06}})
+	if err != nil || !app || version != "RQM 64.02_B0006" {
+		t.Errorf("Expected application firmware RQM 64.02_B0006, got %q, %v (%v)", version, app, err)
+	}
+	if _, app, _ := DecodeFirmwareInfo(Response{Params: []byte{0x01, 'B', 'O', 'T', 0x01, 0x00, 0x00, 0x01}}); app {
+		t.Error("Expected the bootloader not to be the application firmware")
+	}
+
+	serial, err := DecodeSerialNumber(Response{Params: []byte("2403FE0001\x00\x00\x00\x00")})
+	if err != nil || serial != "2403FE0001" {
+		t.Errorf("Expected 2403FE0001, got %q (%v)", serial, err)
+	}
+
+	if name := DecodeNamePart(Response{Params: []byte("Litra Beam LX\x00\x00\x00")}); name != "Litra Beam LX" {
+		t.Errorf("Expected Litra Beam LX, got %q", name)
+	}
+}
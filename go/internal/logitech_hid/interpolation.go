@@ -0,0 +1,203 @@
+package logitech_hid
+
+import (
+	"image/color"
+	"math"
+)
+
+// Interpolation is the colour space a gradient blends its stops in.
+type Interpolation int
+
+const (
+	// SRGB blends the red, green and blue bytes in a straight line, as the
+	// light takes them. Red to green passes through brown.
+	SRGB Interpolation = iota
+	// OKLab blends in a perceptual space, keeping lightness even, the way
+	// CSS's "in oklab" gradients do.
+	OKLab
+	// HSVShorter blends hue the short way round the colour wheel.
+	HSVShorter
+	// HSVLonger blends hue the long way round, through every hue between.
+	HSVLonger
+)
+
+var interpolationNames = map[Interpolation]string{
+	SRGB:       "srgb",
+	OKLab:      "oklab",
+	HSVShorter: "hsv",
+	HSVLonger:  "hsv-longer",
+}
+
+func (i Interpolation) String() string {
+	if name, ok := interpolationNames[i]; ok {
+		return name
+	}
+	return "srgb"
+}
+
+// ParseInterpolation reads an interpolation by its String name. Unknown and
+// empty names are SRGB, which is how gradients blended before there was a choice.
+func ParseInterpolation(name string) Interpolation {
+	for i, n := range interpolationNames {
+		if n == name {
+			return i
+		}
+	}
+	return SRGB
+}
+
+// mix blends from a to b in the interpolation's space, t from 0 to 1.
+func (i Interpolation) mix(a, b color.RGBA, t float64) color.RGBA {
+	switch i {
+	case OKLab:
+		return mixOKLab(a, b, t)
+	case HSVShorter, HSVLonger:
+		return mixHSV(a, b, t, i == HSVLonger)
+	}
+	return mix(a, b, t)
+}
+
+// --- OKLab, from https://bottosson.github.io/posts/oklab/ ---
+
+type lab struct{ L, A, B float64 }
+
+func toLinear(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func fromLinear(v float64) uint8 {
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(min(max(v, 0), 1) * 255))
+}
+
+func toOKLab(c color.RGBA) lab {
+	r, g, b := toLinear(c.R), toLinear(c.G), toLinear(c.B)
+	l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+	m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+	s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+	return lab{
+		L: 0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		A: 1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		B: 0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+func (c lab) rgba() color.RGBA {
+	l := c.L + 0.3963377774*c.A + 0.2158037573*c.B
+	m := c.L - 0.1055613458*c.A - 0.0638541728*c.B
+	s := c.L - 0.0894841775*c.A - 1.2914855480*c.B
+	l, m, s = l*l*l, m*m*m, s*s*s
+	return color.RGBA{
+		R: fromLinear(+4.0767416621*l - 3.3077115913*m + 0.2309699292*s),
+		G: fromLinear(-1.2684380046*l + 2.6097574011*m - 0.3413193965*s),
+		B: fromLinear(-0.0041960863*l - 0.7034186147*m + 1.7076147010*s),
+		A: 0xff,
+	}
+}
+
+func mixOKLab(a, b color.RGBA, t float64) color.RGBA {
+	x, y := toOKLab(a), toOKLab(b)
+	return lab{
+		L: x.L + (y.L-x.L)*t,
+		A: x.A + (y.A-x.A)*t,
+		B: x.B + (y.B-x.B)*t,
+	}.rgba()
+}
+
+// --- HSV ---
+
+type hsv struct{ H, S, V float64 } // H in degrees, -1 for a grey, which has no hue
+
+func toHSV(c color.RGBA) hsv {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	hi, lo := max(r, g, b), min(r, g, b)
+	chroma := hi - lo
+	if chroma == 0 {
+		return hsv{H: -1, V: hi}
+	}
+
+	var h float64
+	switch hi {
+	case r:
+		h = math.Mod((g-b)/chroma, 6)
+	case g:
+		h = (b-r)/chroma + 2
+	default:
+		h = (r-g)/chroma + 4
+	}
+	return hsv{H: math.Mod(h*60+360, 360), S: chroma / hi, V: hi}
+}
+
+func (c hsv) rgba() color.RGBA {
+	chroma := c.V * c.S
+	h := math.Mod(max(c.H, 0), 360) / 60
+	x := chroma * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = chroma, x
+	case 1:
+		r, g = x, chroma
+	case 2:
+		g, b = chroma, x
+	case 3:
+		g, b = x, chroma
+	case 4:
+		r, b = x, chroma
+	default:
+		r, b = chroma, x
+	}
+	lo := c.V - chroma
+	channel := func(v float64) uint8 {
+		return uint8(math.Round(min(max(v+lo, 0), 1) * 255))
+	}
+	return color.RGBA{R: channel(r), G: channel(g), B: channel(b), A: 0xff}
+}
+
+func mixHSV(a, b color.RGBA, t float64, longer bool) color.RGBA {
+	x, y := toHSV(a), toHSV(b)
+	// A grey has no hue of its own, so it takes the other colour's, and the
+	// blend only changes saturation and value.
+	if x.H < 0 {
+		x.H = max(y.H, 0)
+	}
+	if y.H < 0 {
+		y.H = x.H
+	}
+
+	delta := y.H - x.H
+	switch {
+	case !longer && delta > 180:
+		delta -= 360
+	case !longer && delta < -180:
+		delta += 360
+	case longer && delta > 0 && delta < 180:
+		delta -= 360
+	case longer && delta < 0 && delta > -180:
+		delta += 360
+	}
+	return hsv{
+		H: math.Mod(x.H+delta*t+360, 360),
+		S: x.S + (y.S-x.S)*t,
+		V: x.V + (y.V-x.V)*t,
+	}.rgba()
+}
+
+// Hue returns the fully saturated, brightest colour of a hue in degrees.
+func Hue(degrees float64) color.RGBA {
+	return hsv{H: math.Mod(math.Mod(degrees, 360)+360, 360), S: 1, V: 1}.rgba()
+}
+
+// HueOf returns the hue of c in degrees, or false for a grey, which has none.
+func HueOf(c color.RGBA) (float64, bool) {
+	h := toHSV(c).H
+	return h, h >= 0
+}
@@ -0,0 +1,123 @@
+package logitech_hid
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+// The expected colours were worked out independently with Python's colorsys
+// and Björn Ottosson's reference OKLab matrices.
+func TestInterpolationMidpoints(t *testing.T) {
+	var (
+		red    = rgb(255, 0, 0)
+		green  = rgb(0, 255, 0)
+		blue   = rgb(0, 0, 255)
+		yellow = rgb(255, 255, 0)
+		white  = rgb(255, 255, 255)
+	)
+
+	tests := []struct {
+		name     string
+		space    Interpolation
+		from, to color.RGBA
+		expected color.RGBA
+	}{
+		{"srgb red to green is brown", SRGB, red, green, rgb(128, 128, 0)},
+		{"oklab red to green", OKLab, red, green, rgb(208, 168, 0)},
+		{"srgb blue to yellow is grey", SRGB, blue, yellow, rgb(128, 128, 128)},
+		{"oklab blue to yellow", OKLab, blue, yellow, rgb(108, 171, 199)},
+		{"hsv red to green through yellow", HSVShorter, red, green, yellow},
+		{"hsv longer red to green through blue", HSVLonger, red, green, blue},
+		{"hsv white keeps the other hue", HSVShorter, white, red, rgb(255, 128, 128)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := Gradient{Stops: []Stop{{0, tt.from}, {1, tt.to}}, Space: tt.space}
+			if got := g.At(0.5); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+			if got := g.At(0); got != tt.from {
+				t.Errorf("Expected the first stop unchanged, got %v", got)
+			}
+			if got := g.At(1); got != tt.to {
+				t.Errorf("Expected the last stop unchanged, got %v", got)
+			}
+		})
+	}
+}
+
+func TestHSVFrames(t *testing.T) {
+	red, blue := rgb(255, 0, 0), rgb(0, 0, 255)
+
+	tests := []struct {
+		space    Interpolation
+		expected Frame
+	}{
+		{HSVShorter, Frame{
+			rgb(255, 0, 0), rgb(255, 0, 85), rgb(255, 0, 170), rgb(255, 0, 255),
+			rgb(170, 0, 255), rgb(85, 0, 255), rgb(0, 0, 255),
+		}},
+		{HSVLonger, Frame{
+			rgb(255, 0, 0), rgb(255, 170, 0), rgb(170, 255, 0), rgb(0, 255, 0),
+			rgb(0, 255, 170), rgb(0, 170, 255), rgb(0, 0, 255),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.space.String(), func(t *testing.T) {
+			got := Gradient{Stops: []Stop{{0, red}, {1, blue}}, Space: tt.space}.Frame()
+			if got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestOKLabRoundTrip(t *testing.T) {
+	for _, c := range []color.RGBA{rgb(0, 0, 0), rgb(255, 255, 255), rgb(18, 200, 77), rgb(1, 2, 3)} {
+		if got := toOKLab(c).rgba(); got != c {
+			t.Errorf("Expected %v back, got %v", c, got)
+		}
+	}
+}
+
+func TestParseInterpolation(t *testing.T) {
+	for _, i := range []Interpolation{SRGB, OKLab, HSVShorter, HSVLonger} {
+		if got := ParseInterpolation(i.String()); got != i {
+			t.Errorf("Expected %v, got %v", i, got)
+		}
+	}
+	if got := ParseInterpolation(""); got != SRGB {
+		t.Errorf("Expected SRGB for no name, got %v", got)
+	}
+	if got := ParseInterpolation("cmyk"); got != SRGB {
+		t.Errorf("Expected SRGB for an unknown name, got %v", got)
+	}
+}
+
+func TestHue(t *testing.T) {
+	tests := []struct {
+		degrees  float64
+		expected color.RGBA
+	}{
+		{0, rgb(255, 0, 0)},
+		{120, rgb(0, 255, 0)},
+		{240, rgb(0, 0, 255)},
+		{-120, rgb(0, 0, 255)},
+		{480, rgb(0, 255, 0)},
+	}
+	for _, tt := range tests {
+		c := Hue(tt.degrees)
+		if c != tt.expected {
+			t.Errorf("Hue(%v): expected %v, got %v", tt.degrees, tt.expected, c)
+		}
+		if h, ok := HueOf(c); !ok || math.Abs(h-math.Mod(tt.degrees+360, 360)) > 0.5 {
+			t.Errorf("HueOf(%v): expected %v, got %v, %v", c, tt.degrees, h, ok)
+		}
+	}
+	if _, ok := HueOf(rgb(128, 128, 128)); ok {
+		t.Error("Expected grey to have no hue")
+	}
+}
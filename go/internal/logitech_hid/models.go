@@ -0,0 +1,215 @@
+package logitech_hid
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Logitech's USB vendor ID, shared by every Litra.
+const VendorID = 0x046d
+
+// ErrUnsupported is wrapped by every error about a feature a model doesn't have.
+var ErrUnsupported = errors.New("not supported")
+
+// Model describes what one Litra product can do and how to address it.
+// A zero feature index means the model doesn't have that light.
+type Model struct {
+	Name      string
+	ProductID uint16
+
+	// HID++ feature indices
+	FrontIndex     byte
+	BackIndex      byte
+	BackColorIndex byte
+
+	// Front light output range
+	MinLumen       uint16
+	MaxLumen       uint16
+	MinTemperature uint16
+	MaxTemperature uint16
+
+	// Number of colour zones on the back light
+	ZoneCount int
+}
+
+var (
+	LitraGlow = Model{
+		Name:           "Litra Glow",
+		ProductID:      0xc900,
+		FrontIndex:     0x04,
+		MinLumen:       20,
+		MaxLumen:       250,
+		MinTemperature: 2700,
+		MaxTemperature: 6500,
+	}
+	LitraBeam = Model{
+		Name:           "Litra Beam",
+		ProductID:      0xc901,
+		FrontIndex:     0x04,
+		MinLumen:       30,
+		MaxLumen:       400,
+		MinTemperature: 2700,
+		MaxTemperature: 6500,
+	}
+	LitraBeamLX = Model{
+		Name:           "Litra Beam LX",
+		ProductID:      0xc903,
+		FrontIndex:     FrontLightFID,
+		BackIndex:      BackLightFID,
+		BackColorIndex: BackLightColorFID,
+		MinLumen:       20,
+		MaxLumen:       250,
+		MinTemperature: 2700,
+		MaxTemperature: 6500,
+		ZoneCount:      BackLightZoneCount,
+	}
+)
+
+// Models lists every supported product.
+var Models = []Model{LitraGlow, LitraBeam, LitraBeamLX}
+
+// ModelByProductID returns the model with the given USB product ID.
+func ModelByProductID(pid uint16) (Model, bool) {
+	for _, m := range Models {
+		if m.ProductID == pid {
+			return m, true
+		}
+	}
+	return Model{}, false
+}
+
+func (t LightTarget) String() string {
+	switch t {
+	case FrontLight:
+		return "front light"
+	case BackLight:
+		return "back light"
+	}
+	return fmt.Sprintf("light 0x%02x", byte(t))
+}
+
+// Supports reports whether the model has the target light.
+func (m Model) Supports(target LightTarget) bool {
+	switch target {
+	case FrontLight:
+		return m.FrontIndex != 0
+	case BackLight:
+		return m.BackIndex != 0
+	}
+	return false
+}
+
+// Targets lists the lights the model has.
+func (m Model) Targets() []LightTarget {
+	var targets []LightTarget
+	for _, t := range []LightTarget{FrontLight, BackLight} {
+		if m.Supports(t) {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// HasColor reports whether the model has an RGB back light.
+func (m Model) HasColor() bool {
+	return m.BackColorIndex != 0 && m.ZoneCount > 0
+}
+
+func (m Model) unsupported(what string) error {
+	return fmt.Errorf("the %s has no %s: %w", m.Name, what, ErrUnsupported)
+}
+
+func (m Model) check(target LightTarget) error {
+	if !m.Supports(target) {
+		return m.unsupported(target.String())
+	}
+	return nil
+}
+
+// Lumen maps a 1-100% brightness onto the model's front light output range.
+func (m Model) Lumen(percentage uint8) uint16 {
+	span := float64(m.MaxLumen - m.MinLumen)
+	return uint16(float64(percentage-1)/99.0*span) + m.MinLumen
+}
+
+// LightsOn turns the target light on.
+func (m Model) LightsOn(target LightTarget) ([]byte, error) {
+	return m.power(target, 0x01)
+}
+
+// LightsOff turns the target light off.
+func (m Model) LightsOff(target LightTarget) ([]byte, error) {
+	return m.power(target, 0x00)
+}
+
+func (m Model) power(target LightTarget, state byte) ([]byte, error) {
+	if err := m.check(target); err != nil {
+		return nil, err
+	}
+
+	if state == 0x00 {
+		return ConvertLightsOffTarget(target, m.index(target)), nil
+	}
+	return ConvertLightsOnTarget(target, m.index(target)), nil
+}
+
+// index returns the feature index of the target light.
+func (m Model) index(target LightTarget) byte {
+	if target == BackLight {
+		return m.BackIndex
+	}
+	return m.FrontIndex
+}
+
+// Brightness sets the target light's brightness (1-100%).
+func (m Model) Brightness(target LightTarget, percentage uint8) ([]byte, error) {
+	if err := m.check(target); err != nil {
+		return nil, err
+	}
+	if target == BackLight {
+		return ConvertBrightnessTarget(target, m.BackIndex, percentage)
+	}
+	if err := CheckBrightness(percentage); err != nil {
+		return nil, err
+	}
+
+	// Front light brightness takes the output in lumen
+	return Brightness{Target: target, Index: m.FrontIndex, Value: m.Lumen(percentage)}.MarshalBinary()
+}
+
+// Temperature sets the front light's colour temperature in Kelvin.
+func (m Model) Temperature(target LightTarget, temperature uint16) ([]byte, error) {
+	if target != FrontLight {
+		return nil, m.unsupported("colour temperature on the " + target.String())
+	}
+	if err := m.check(target); err != nil {
+		return nil, err
+	}
+	if temperature < m.MinTemperature {
+		return nil, fmt.Errorf("temperature must be greater than %d, was %d", m.MinTemperature, temperature)
+	}
+	if temperature > m.MaxTemperature {
+		return nil, fmt.Errorf("temperature must be less than %d, was %d", m.MaxTemperature, temperature)
+	}
+
+	return ConvertTemperatureTarget(target, m.FrontIndex, temperature)
+}
+
+// Color sets every zone of the back light to one colour, including the commit command.
+func (m Model) Color(target LightTarget, r, g, b uint8) ([][]byte, error) {
+	if target != BackLight || !m.HasColor() {
+		return nil, m.unsupported("RGB " + target.String())
+	}
+
+	return m.Frame(target, Fill(rgb(r, g, b)))
+}
+
+// Gradient interpolates the back light from colour 1 to colour 2 across its
+// zones, including the commit command. Use Frame with a Gradient for more stops.
+func (m Model) Gradient(target LightTarget, r1, g1, b1, r2, g2, b2 uint8) ([][]byte, error) {
+	if target != BackLight || !m.HasColor() {
+		return nil, m.unsupported("RGB " + target.String())
+	}
+
+	return m.Frame(target, twoColors(rgb(r1, g1, b1), rgb(r2, g2, b2)).Frame())
+}
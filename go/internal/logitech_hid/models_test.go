@@ -0,0 +1,162 @@
+package logitech_hid
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestModelByProductID(t *testing.T) {
+	tests := []struct {
+		pid  uint16
+		name string
+	}{
+		{0xc900, "Litra Glow"},
+		{0xc901, "Litra Beam"},
+		{0xc903, "Litra Beam LX"},
+	}
+
+	for _, test := range tests {
+		m, ok := ModelByProductID(test.pid)
+		if !ok || m.Name != test.name {
+			t.Errorf("For PID 0x%04x, expected %s, got %q (found=%v)", test.pid, test.name, m.Name, ok)
+		}
+	}
+
+	if _, ok := ModelByProductID(0xc52b); ok {
+		t.Error("Expected an unknown PID not to match a model")
+	}
+}
+
+func TestModelTargets(t *testing.T) {
+	if got := LitraGlow.Targets(); len(got) != 1 || got[0] != FrontLight {
+		t.Errorf("Expected the Glow to only have a front light, got %v", got)
+	}
+	if got := LitraBeamLX.Targets(); len(got) != 2 {
+		t.Errorf("Expected the Beam LX to have front and back lights, got %v", got)
+	}
+	if LitraBeam.HasColor() || !LitraBeamLX.HasColor() {
+		t.Error("Only the Beam LX has an RGB back light")
+	}
+}
+
+func TestModelUnsupportedTargets(t *testing.T) {
+	_, err := LitraGlow.LightsOn(BackLight)
+	if !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("Expected ErrUnsupported, got %v", err)
+	}
+	if err.Error() != "the Litra Glow has no back light: not supported" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+
+	if _, err := LitraBeam.Brightness(BackLight, 50); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for Beam back brightness, got %v", err)
+	}
+	if _, err := LitraBeamLX.Temperature(BackLight, 4000); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for back light temperature, got %v", err)
+	}
+	if _, err := LitraBeam.Color(BackLight, 255, 0, 0); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported for Beam colour, got %v", err)
+	}
+}
+
+func TestModelFeatureIndices(t *testing.T) {
+	tests := []struct {
+		model Model
+		index byte
+	}{
+		{LitraGlow, 0x04},
+		{LitraBeam, 0x04},
+		{LitraBeamLX, 0x06},
+	}
+
+	for _, test := range tests {
+		on, err := test.model.LightsOn(FrontLight)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(on, extendByteSlice([]byte{0x11, 0xff, test.index, 0x1c, 0x01})) {
+			t.Errorf("%s front ON incorrect: % x", test.model.Name, on[:6])
+		}
+	}
+}
+
+func TestModelBrightness(t *testing.T) {
+	tests := []struct {
+		model      Model
+		percentage uint8
+		expected   []byte
+	}{
+		// The Glow and Beam LX ranges match the original 20-250 mapping
+		{LitraGlow, 1, []byte{0x11, 0xff, 0x04, 0x4c, 0x00, 0x14}},
+		{LitraGlow, 50, []byte{0x11, 0xff, 0x04, 0x4c, 0x00, 0x85}},
+		{LitraGlow, 100, []byte{0x11, 0xff, 0x04, 0x4c, 0x00, 0xfa}},
+		{LitraBeam, 1, []byte{0x11, 0xff, 0x04, 0x4c, 0x00, 0x1e}},
+		{LitraBeam, 100, []byte{0x11, 0xff, 0x04, 0x4c, 0x01, 0x90}},
+		{LitraBeamLX, 100, []byte{0x11, 0xff, 0x06, 0x4c, 0x00, 0xfa}},
+	}
+
+	for _, test := range tests {
+		result, err := test.model.Brightness(FrontLight, test.percentage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(result, extendByteSlice(test.expected)) {
+			t.Errorf("%s at %d%%: expected % x, got % x", test.model.Name, test.percentage, test.expected, result[:6])
+		}
+	}
+
+	back, err := LitraBeamLX.Brightness(BackLight, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := ConvertBrightnessTarget(BackLight, BackLightFID, 50)
+	if !bytes.Equal(back, expected) {
+		t.Errorf("Beam LX back brightness: expected % x, got % x", expected, back)
+	}
+
+	if _, err := LitraBeamLX.Brightness(FrontLight, 0); err == nil {
+		t.Error("Expected 0% to be rejected")
+	}
+}
+
+func TestModelTemperature(t *testing.T) {
+	result, err := LitraGlow.Temperature(FrontLight, 4000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(result, extendByteSlice([]byte{0x11, 0xff, 0x04, 0x9c, 0x0f, 0xa0})) {
+		t.Errorf("Glow temperature incorrect: % x", result[:6])
+	}
+
+	if _, err := LitraBeamLX.Temperature(FrontLight, 2600); err == nil {
+		t.Error("Expected 2600K to be rejected")
+	}
+}
+
+func TestModelColorMatchesBeamLXLayout(t *testing.T) {
+	color, err := LitraBeamLX.Color(BackLight, 10, 20, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ConvertBackColorAllZones(10, 20, 30)
+	if len(color) != len(expected) {
+		t.Fatalf("Expected %d commands, got %d", len(expected), len(color))
+	}
+	for i := range expected {
+		if !bytes.Equal(color[i], expected[i]) {
+			t.Errorf("Command %d: expected % x, got % x", i, expected[i], color[i])
+		}
+	}
+
+	gradient, err := LitraBeamLX.Gradient(BackLight, 255, 0, 0, 0, 0, 255)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = ConvertBackColorGradient(255, 0, 0, 0, 0, 255)
+	for i := range expected {
+		if !bytes.Equal(gradient[i], expected[i]) {
+			t.Errorf("Gradient command %d: expected % x, got % x", i, expected[i], gradient[i])
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package logitech_hid
+
+import (
+	"errors"
+	"image/color"
+)
+
+// The "get" functions, in the same function<<4 | softwareID form as the set
+// commands. The device echoes this byte in its answer.
+const (
+	getFrontPower       = 0x0c // Illumination getIllumination
+	getFrontBrightness  = 0x3c // Illumination getBrightness
+	getFrontTemperature = 0x8c // Illumination getColorTemperature
+	getBackBrightness   = 0x1b // BrightnessControl getBrightness
+	getBackPower        = 0x3b // BrightnessControl getIllumination
+)
+
+// errorReport marks an HID++ error in place of the feature index.
+const errorReport = 0xff
+
+// ErrShortReport is returned for a report too short to be an HID++ long report.
+var ErrShortReport = errors.New("short HID++ report")
+
+// Response is an HID++ report received from a light.
+type Response struct {
+	FeatureIndex byte
+	Function     byte // function<<4 | softwareID, as sent
+	Params       []byte
+}
+
+// ParseResponse decodes a report read from the light. An error report returns
+// a *DeviceError, and still returns the feature index and function it refers
+// to, so the caller can tell whether it answers its request.
+func ParseResponse(report []byte) (Response, error) {
+	if len(report) < 5 || report[0] != 0x11 {
+		return Response{}, ErrShortReport
+	}
+	if report[2] == errorReport {
+		r := Response{FeatureIndex: report[3], Function: report[4]}
+		err := &DeviceError{FeatureIndex: report[3], Function: report[4], Code: ErrUnknown}
+		if len(report) > 5 {
+			err.Code = ErrorCode(report[5])
+		}
+		return r, err
+	}
+	return Response{FeatureIndex: report[2], Function: report[3], Params: report[4:]}, nil
+}
+
+// Answers reports whether r is the device's answer to request.
+func (r Response) Answers(request []byte) bool {
+	return len(request) > 3 && r.FeatureIndex == request[2] && r.Function == request[3]
+}
+
+func (m Model) get(target LightTarget, front, back byte) ([]byte, error) {
+	if err := m.check(target); err != nil {
+		return nil, err
+	}
+
+	if target == BackLight {
+		return Query{Target: target, Index: m.BackIndex, Function: back}.MarshalBinary()
+	}
+	return Query{Target: target, Index: m.FrontIndex, Function: front}.MarshalBinary()
+}
+
+// GetPower asks whether the target light is on.
+func (m Model) GetPower(target LightTarget) ([]byte, error) {
+	return m.get(target, getFrontPower, getBackPower)
+}
+
+// GetBrightness asks for the target light's brightness.
+func (m Model) GetBrightness(target LightTarget) ([]byte, error) {
+	return m.get(target, getFrontBrightness, getBackBrightness)
+}
+
+// GetTemperature asks for the front light's colour temperature.
+func (m Model) GetTemperature(target LightTarget) ([]byte, error) {
+	if target != FrontLight {
+		return nil, m.unsupported("colour temperature on the " + target.String())
+	}
+	return m.get(target, getFrontTemperature, 0)
+}
+
+// DecodePower reads the answer to GetPower.
+func (m Model) DecodePower(r Response) (bool, error) {
+	if len(r.Params) < 1 {
+		return false, ErrShortReport
+	}
+	return r.Params[0] != 0x00, nil
+}
+
+// DecodeBrightness reads the answer to GetBrightness as a 1-100% value.
+func (m Model) DecodeBrightness(target LightTarget, r Response) (uint8, error) {
+	if len(r.Params) < 2 {
+		return 0, ErrShortReport
+	}
+	value := uint16(r.Params[0])<<8 | uint16(r.Params[1])
+	if target == BackLight {
+		return uint8(min(max(value, minPercentage), maxPercentage)), nil
+	}
+	return m.Percentage(value), nil
+}
+
+// DecodeTemperature reads the answer to GetTemperature in Kelvin.
+func (m Model) DecodeTemperature(r Response) (uint16, error) {
+	if len(r.Params) < 2 {
+		return 0, ErrShortReport
+	}
+	return uint16(r.Params[0])<<8 | uint16(r.Params[1]), nil
+}
+
+// Percentage maps a front light output in lumen back to 1-100%, the inverse of Lumen.
+func (m Model) Percentage(lumen uint16) uint8 {
+	lumen = min(max(lumen, m.MinLumen), m.MaxLumen)
+	span := float64(m.MaxLumen - m.MinLumen)
+	return uint8(float64(lumen-m.MinLumen)/span*99.0+0.5) + 1
+}
+
+// ZoneColors reads the zone colours out of colour commands built by Color or
+// Gradient. The colour feature has no function to read the zones back, so the
+// last frame written is the only record of them. Zones the commands don't set
+// are left as zero.
+func (m Model) ZoneColors(commands [][]byte) []color.RGBA {
+	zones := make([]color.RGBA, m.ZoneCount)
+	for _, cmd := range commands {
+		var c ZoneColor
+		if len(cmd) < 4 || cmd[2] != m.BackColorIndex || c.UnmarshalBinary(cmd) != nil {
+			continue
+		}
+		for _, z := range c.Zones {
+			if z.Number < 1 || int(z.Number) > m.ZoneCount {
+				continue
+			}
+			zones[z.Number-1] = z.Color
+		}
+	}
+	return zones
+}
@@ -0,0 +1,118 @@
+package logitech_hid
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestGetCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func() ([]byte, error)
+		expected []byte
+	}{
+		{"front power", func() ([]byte, error) { return LitraBeamLX.GetPower(FrontLight) }, []byte{0x11, 0xff, 0x06, 0x0c}},
+		{"back power", func() ([]byte, error) { return LitraBeamLX.GetPower(BackLight) }, []byte{0x11, 0xff, 0x0a, 0x3b}},
+		{"front brightness", func() ([]byte, error) { return LitraGlow.GetBrightness(FrontLight) }, []byte{0x11, 0xff, 0x04, 0x3c}},
+		{"back brightness", func() ([]byte, error) { return LitraBeamLX.GetBrightness(BackLight) }, []byte{0x11, 0xff, 0x0a, 0x1b}},
+		{"temperature", func() ([]byte, error) { return LitraBeamLX.GetTemperature(FrontLight) }, []byte{0x11, 0xff, 0x06, 0x8c}},
+	}
+
+	for _, test := range tests {
+		result, err := test.build()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(result, extendByteSlice(test.expected)) {
+			t.Errorf("%s: expected % x, got % x", test.name, test.expected, result[:4])
+		}
+	}
+
+	if _, err := LitraGlow.GetPower(BackLight); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	request, _ := LitraBeamLX.GetBrightness(FrontLight)
+
+	r, err := ParseResponse(extendByteSlice([]byte{0x11, 0xff, 0x06, 0x3c, 0x00, 0x85}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Answers(request) {
+		t.Error("Expected the response to answer the request")
+	}
+	if pct, _ := LitraBeamLX.DecodeBrightness(FrontLight, r); pct != 50 {
+		t.Errorf("Expected 50%%, got %d", pct)
+	}
+
+	// A notification from another feature doesn't answer the request.
+	other, err := ParseResponse(extendByteSlice([]byte{0x11, 0xff, 0x06, 0x00, 0x01}))
+	if err != nil || other.Answers(request) {
+		t.Errorf("Expected an unrelated report, got %+v (%v)", other, err)
+	}
+
+	// An error report still says which request failed.
+	failed, err := ParseResponse(extendByteSlice([]byte{0x11, 0xff, 0xff, 0x06, 0x3c, 0x02}))
+	if !errors.Is(err, ErrInvalidArgument) || !failed.Answers(request) {
+		t.Errorf("Expected an invalid argument error answering the request, got %+v (%v)", failed, err)
+	}
+	var deviceErr *DeviceError
+	if !errors.As(err, &deviceErr) || deviceErr.FeatureIndex != 0x06 || deviceErr.Function != 0x3c {
+		t.Errorf("Expected the error to name feature 0x06 function 0x3c, got %v", err)
+	}
+
+	if _, err := ParseResponse([]byte{0x11, 0xff}); !errors.Is(err, ErrShortReport) {
+		t.Errorf("Expected ErrShortReport, got %v", err)
+	}
+}
+
+func TestDecodeState(t *testing.T) {
+	on, err := LitraBeamLX.DecodePower(Response{Params: []byte{0x01}})
+	if err != nil || !on {
+		t.Errorf("Expected on, got %v (%v)", on, err)
+	}
+
+	temp, err := LitraBeamLX.DecodeTemperature(Response{Params: []byte{0x0f, 0xa0}})
+	if err != nil || temp != 4000 {
+		t.Errorf("Expected 4000K, got %d (%v)", temp, err)
+	}
+
+	back, err := LitraBeamLX.DecodeBrightness(BackLight, Response{Params: []byte{0x00, 0x28}})
+	if err != nil || back != 40 {
+		t.Errorf("Expected 40%%, got %d (%v)", back, err)
+	}
+
+	if _, err := LitraBeamLX.DecodeTemperature(Response{Params: []byte{0x0f}}); !errors.Is(err, ErrShortReport) {
+		t.Errorf("Expected ErrShortReport, got %v", err)
+	}
+}
+
+func TestPercentageInvertsLumen(t *testing.T) {
+	for _, m := range Models {
+		for pct := uint8(1); pct <= 100; pct++ {
+			if got := m.Percentage(m.Lumen(pct)); got != pct {
+				t.Errorf("%s: %d%% came back as %d%%", m.Name, pct, got)
+			}
+		}
+	}
+}
+
+func TestZoneColors(t *testing.T) {
+	commands, _ := LitraBeamLX.Gradient(BackLight, 255, 0, 0, 0, 0, 255)
+	zones := LitraBeamLX.ZoneColors(commands)
+
+	if len(zones) != 7 {
+		t.Fatalf("Expected 7 zones, got %d", len(zones))
+	}
+	// Zero channels are sent as 1, so they come back as 1.
+	if zones[0] != (color.RGBA{R: 255, G: 1, B: 1, A: 0xff}) {
+		t.Errorf("Unexpected first zone %v", zones[0])
+	}
+	if zones[6] != (color.RGBA{R: 1, G: 1, B: 255, A: 0xff}) {
+		t.Errorf("Unexpected last zone %v", zones[6])
+	}
+}
@@ -0,0 +1,71 @@
+package logitech_hid
+
+import (
+	"image/color"
+	"math"
+	"slices"
+
+	temperatureconverter "github.com/maruel/temperature"
+)
+
+// WhitePoint scales the back light's red, green and blue at a colour
+// temperature, so white made of its RGB LEDs looks like the front light at
+// that temperature.
+type WhitePoint struct {
+	Kelvin           uint16
+	Red, Green, Blue float64
+}
+
+// whiteBalance is the correction table of each model with a coloured back
+// light, by product ID, in increasing Kelvin. Between two points the scales
+// are interpolated; beyond the ends the nearest point is used.
+var whiteBalance = map[uint16][]WhitePoint{
+	// The Beam LX's back LEDs look cooler than its front panel at the same
+	// colour, most of all when warm, so green and blue are turned down.
+	0xc903: {
+		{Kelvin: 2700, Red: 1, Green: 0.82, Blue: 0.55},
+		{Kelvin: 4000, Red: 1, Green: 0.90, Blue: 0.75},
+		{Kelvin: 6500, Red: 1, Green: 0.96, Blue: 0.92},
+	},
+}
+
+// Kelvin returns the sRGB colour of a black body at a colour temperature.
+func Kelvin(kelvin uint16) color.RGBA {
+	r, g, b := temperatureconverter.ToRGB(kelvin)
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
+
+// White returns the back light colour matching the front light at a colour
+// temperature: Kelvin's colour, corrected with the model's white balance.
+func (m Model) White(kelvin uint16) color.RGBA {
+	c := Kelvin(kelvin)
+	points := whiteBalance[m.ProductID]
+	if len(points) == 0 {
+		return c
+	}
+
+	i, _ := slices.BinarySearchFunc(points, kelvin, func(p WhitePoint, k uint16) int {
+		return int(p.Kelvin) - int(k)
+	})
+	var p WhitePoint
+	switch {
+	case i == 0:
+		p = points[0]
+	case i == len(points):
+		p = points[len(points)-1]
+	default:
+		lo, hi := points[i-1], points[i]
+		t := float64(kelvin-lo.Kelvin) / float64(hi.Kelvin-lo.Kelvin)
+		p = WhitePoint{
+			Kelvin: kelvin,
+			Red:    lo.Red + (hi.Red-lo.Red)*t,
+			Green:  lo.Green + (hi.Green-lo.Green)*t,
+			Blue:   lo.Blue + (hi.Blue-lo.Blue)*t,
+		}
+	}
+
+	channel := func(v uint8, scale float64) uint8 {
+		return uint8(math.Round(float64(v) * min(max(scale, 0), 1)))
+	}
+	return color.RGBA{R: channel(c.R, p.Red), G: channel(c.G, p.Green), B: channel(c.B, p.Blue), A: 0xff}
+}
@@ -0,0 +1,41 @@
+package logitech_hid
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestKelvin(t *testing.T) {
+	if got := Kelvin(6500); got != (color.RGBA{R: 255, G: 255, B: 255, A: 0xff}) {
+		t.Errorf("Expected 6500K white, got %v", got)
+	}
+	warm := Kelvin(2700)
+	if warm.R != 255 || warm.G >= warm.R || warm.B >= warm.G {
+		t.Errorf("Expected 2700K orange, got %v", warm)
+	}
+}
+
+func TestWhiteCorrectsForTheModel(t *testing.T) {
+	// A model without a table gets the plain colour.
+	if got, want := LitraGlow.White(3000), Kelvin(3000); got != want {
+		t.Errorf("Expected %v uncorrected, got %v", want, got)
+	}
+
+	tests := []struct {
+		kelvin uint16
+		want   color.RGBA
+	}{
+		// At a point of the table: 2700K is (255, 171, 88)
+		{2700, color.RGBA{R: 255, G: 140, B: 48, A: 0xff}},
+		// Beyond the ends, the nearest point: 6500K is white
+		{6500, color.RGBA{R: 255, G: 245, B: 235, A: 0xff}},
+		// Half way between 2700K and 4000K: scales of 1, 0.86 and 0.65
+		// applied to 3350K's (255, 192, 131)
+		{3350, color.RGBA{R: 255, G: 165, B: 85, A: 0xff}},
+	}
+	for _, tt := range tests {
+		if got := LitraBeamLX.White(tt.kelvin); got != tt.want {
+			t.Errorf("%dK: expected %v, got %v (uncorrected %v)", tt.kelvin, tt.want, got, Kelvin(tt.kelvin))
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when using a fake device that was closed or detached.
+var ErrClosed = errors.New("device closed")
+
+// Report is one report written to a fake device.
+type Report struct {
+	Path string
+	Data []byte
+}
+
+// Fake is an in-memory Transport for tests. It records every report written
+// to any device it opened and can be told to fail at each step.
+type Fake struct {
+	mu      sync.Mutex
+	devices []DeviceInfo
+	open    map[string]*fakeDevice
+	reports []Report
+	opens   int
+
+	initErr      error
+	enumerateErr error
+	openErr      error
+	writeErr     error
+	writeFails   int
+
+	responder func(info DeviceInfo, report []byte) [][]byte
+	ignore    func(report []byte) bool
+}
+
+// NewFake returns a Fake with the given interfaces attached.
+func NewFake(devices ...DeviceInfo) *Fake {
+	return &Fake{
+		devices: devices,
+		open:    make(map[string]*fakeDevice),
+	}
+}
+
+// Attach plugs in an interface.
+func (f *Fake) Attach(info DeviceInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.devices = append(f.devices, info)
+}
+
+// Detach unplugs the interface at path. Any open handle to it starts failing.
+func (f *Fake) Detach(path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, d := range f.devices {
+		if d.Path == path {
+			f.devices = append(f.devices[:i], f.devices[i+1:]...)
+			break
+		}
+	}
+	if d, ok := f.open[path]; ok {
+		d.close()
+		delete(f.open, path)
+	}
+}
+
+// FailInit makes Init return err until it is called again with nil.
+func (f *Fake) FailInit(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.initErr = err
+}
+
+// FailEnumerate makes Enumerate return err until it is called again with nil.
+func (f *Fake) FailEnumerate(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.enumerateErr = err
+}
+
+// FailOpen makes Open return err until it is called again with nil.
+func (f *Fake) FailOpen(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.openErr = err
+}
+
+// FailWrites makes the next n writes, on any device, return err.
+func (f *Fake) FailWrites(n int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writeFails = n
+	f.writeErr = err
+}
+
+// Respond makes every device answer each written report with the reports fn
+// returns, as if the light replied. A nil fn stops the answers.
+func (f *Fake) Respond(fn func(info DeviceInfo, report []byte) [][]byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responder = fn
+}
+
+// Ignore keeps the written reports fn matches out of Reports and Written,
+// for traffic a test isn't about. They are still answered.
+func (f *Fake) Ignore(fn func(report []byte) bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ignore = fn
+}
+
+// QueueRead makes the device at path return report from its next read.
+func (f *Fake) QueueRead(path string, report []byte) error {
+	f.mu.Lock()
+	d, ok := f.open[path]
+	f.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("fake: %s is not open", path)
+	}
+	select {
+	case d.reads <- append([]byte(nil), report...):
+		return nil
+	case <-d.closed:
+		return ErrClosed
+	}
+}
+
+// Reports returns every report successfully written so far, in order.
+func (f *Fake) Reports() []Report {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Report(nil), f.reports...)
+}
+
+// Written returns the data of every report written to path, in order.
+func (f *Fake) Written(path string) [][]byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out [][]byte
+	for _, r := range f.reports {
+		if r.Path == path {
+			out = append(out, r.Data)
+		}
+	}
+	return out
+}
+
+// Reset forgets the recorded reports.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reports = nil
+}
+
+// Opens returns how many times Open succeeded.
+func (f *Fake) Opens() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.opens
+}
+
+func (f *Fake) Init() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.initErr
+}
+
+func (f *Fake) Exit() error {
+	return nil
+}
+
+func (f *Fake) Enumerate(vid, pid uint16) ([]DeviceInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.enumerateErr != nil {
+		return nil, f.enumerateErr
+	}
+	var out []DeviceInfo
+	for _, d := range f.devices {
+		if d.VendorID == vid && (pid == 0 || d.ProductID == pid) {
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func (f *Fake) Open(path string) (Device, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.openErr != nil {
+		return nil, f.openErr
+	}
+	var info *DeviceInfo
+	for i := range f.devices {
+		if f.devices[i].Path == path {
+			info = &f.devices[i]
+			break
+		}
+	}
+	if info == nil {
+		return nil, fmt.Errorf("fake: no device at %s", path)
+	}
+	d := &fakeDevice{
+		fake:   f,
+		info:   *info,
+		path:   path,
+		reads:  make(chan []byte, 64),
+		closed: make(chan struct{}),
+	}
+	f.open[path] = d
+	f.opens++
+	return d, nil
+}
+
+// write records p, unless a failure was injected. Must not be called with mu held.
+func (f *Fake) write(path string, p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.writeFails > 0 {
+		f.writeFails--
+		return -1, f.writeErr
+	}
+	if f.ignore != nil && f.ignore(p) {
+		return len(p), nil
+	}
+	f.reports = append(f.reports, Report{Path: path, Data: append([]byte(nil), p...)})
+	return len(p), nil
+}
+
+type fakeDevice struct {
+	fake      *Fake
+	info      DeviceInfo
+	path      string
+	reads     chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (d *fakeDevice) close() {
+	d.closeOnce.Do(func() { close(d.closed) })
+}
+
+func (d *fakeDevice) Write(p []byte) (int, error) {
+	select {
+	case <-d.closed:
+		return -1, ErrClosed
+	default:
+	}
+	n, err := d.fake.write(d.path, p)
+	if err != nil {
+		return n, err
+	}
+
+	d.fake.mu.Lock()
+	respond := d.fake.responder
+	d.fake.mu.Unlock()
+	if respond != nil {
+		for _, r := range respond(d.info, p) {
+			select {
+			case d.reads <- append([]byte(nil), r...):
+			default: // a real device drops reports nobody reads, too
+			}
+		}
+	}
+	return n, nil
+}
+
+func (d *fakeDevice) ReadWithTimeout(p []byte, timeout time.Duration) (int, error) {
+	select {
+	case r := <-d.reads:
+		return copy(p, r), nil
+	case <-d.closed:
+		return -1, ErrClosed
+	case <-time.After(timeout):
+		return 0, ErrTimeout
+	}
+}
+
+func (d *fakeDevice) Close() error {
+	d.close()
+	d.fake.mu.Lock()
+	defer d.fake.mu.Unlock()
+	if d.fake.open[d.path] == d {
+		delete(d.fake.open, d.path)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"errors"
+	"time"
+
+	"github.com/sstallion/go-hid"
+)
+
+// HID is the Transport backed by hidapi through sstallion/go-hid.
+type HID struct{}
+
+func (HID) Init() error {
+	return hid.Init()
+}
+
+func (HID) Exit() error {
+	return hid.Exit()
+}
+
+func (HID) Enumerate(vid, pid uint16) ([]DeviceInfo, error) {
+	var infos []DeviceInfo
+	err := hid.Enumerate(vid, pid, func(info *hid.DeviceInfo) error {
+		infos = append(infos, DeviceInfo{
+			Path:         info.Path,
+			VendorID:     info.VendorID,
+			ProductID:    info.ProductID,
+			SerialNbr:    info.SerialNbr,
+			ProductStr:   info.ProductStr,
+			UsagePage:    info.UsagePage,
+			InterfaceNbr: info.InterfaceNbr,
+		})
+		return nil
+	})
+	return infos, err
+}
+
+func (HID) Open(path string) (Device, error) {
+	d, err := hid.OpenPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return hidDevice{d}, nil
+}
+
+type hidDevice struct {
+	*hid.Device
+}
+
+// ReadWithTimeout translates hid.ErrTimeout into ErrTimeout so callers
+// don't need to import go-hid.
+func (d hidDevice) ReadWithTimeout(p []byte, timeout time.Duration) (int, error) {
+	n, err := d.Device.ReadWithTimeout(p, timeout)
+	if errors.Is(err, hid.ErrTimeout) {
+		return n, ErrTimeout
+	}
+	return n, err
+}
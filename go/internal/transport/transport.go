@@ -0,0 +1,44 @@
+// Package transport abstracts the HID layer used to talk to Litra lights, so the
+// device manager can be driven by real hardware or by an in-memory fake in tests.
+package transport
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTimeout is returned by Device.ReadWithTimeout when no report arrived in time.
+var ErrTimeout = errors.New("timeout")
+
+// DeviceInfo describes one HID interface found during enumeration.
+type DeviceInfo struct {
+	Path         string
+	VendorID     uint16
+	ProductID    uint16
+	SerialNbr    string
+	ProductStr   string
+	UsagePage    uint16
+	InterfaceNbr int
+}
+
+// Transport enumerates and opens HID devices.
+type Transport interface {
+	// Init prepares the backend. It is called before every enumeration.
+	Init() error
+	// Exit releases any resources held by the backend.
+	Exit() error
+	// Enumerate lists the interfaces matching the vendor and product IDs.
+	// A product ID of 0 matches every product of the vendor.
+	Enumerate(vid, pid uint16) ([]DeviceInfo, error)
+	// Open opens the interface at path.
+	Open(path string) (Device, error)
+}
+
+// Device is an open HID interface. Reports are 20 bytes for HID++ long messages.
+type Device interface {
+	Write(p []byte) (int, error)
+	// ReadWithTimeout blocks until a report arrives or the timeout expires,
+	// in which case it returns ErrTimeout.
+	ReadWithTimeout(p []byte, timeout time.Duration) (int, error)
+	Close() error
+}
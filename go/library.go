@@ -0,0 +1,42 @@
+package main
+
+// Library is the plugin-wide collection of presets and scenes, by ID. The
+// Property Inspector keeps it in the global settings and forwards it, like
+// the light groups. Keys refer to its entries by ID, so editing one changes
+// every key using it.
+type Library struct {
+	Presets map[string]Preset `json:"presets,omitempty"`
+	Scenes  map[string]Scene  `json:"scenes,omitempty"`
+}
+
+// preset resolves a key's preset: a preset with an ID is the library's preset
+// of that ID, and any other is the key's own. It reports false for an ID the
+// library doesn't have.
+func (lib Library) preset(p Preset) (Preset, bool) {
+	if p.ID == "" {
+		return p, true
+	}
+	found, ok := lib.Presets[p.ID]
+	return found, ok
+}
+
+// presets resolves a key's presets, leaving out those deleted from the library.
+func (lib Library) presets(presets []Preset) []Preset {
+	resolved := make([]Preset, 0, len(presets))
+	for _, p := range presets {
+		if p, ok := lib.preset(p); ok {
+			resolved = append(resolved, p)
+		}
+	}
+	return resolved
+}
+
+// presetsByID returns the library's presets with the given IDs, in order,
+// leaving out deleted ones.
+func (lib Library) presetsByID(ids []string) []Preset {
+	presets := make([]Preset, len(ids))
+	for i, id := range ids {
+		presets[i] = Preset{ID: id}
+	}
+	return lib.presets(presets)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+func TestLibraryResolvesPresets(t *testing.T) {
+	lib := Library{Presets: map[string]Preset{"red": {Name: "Red", Mode: "solid", Color: "#ff0000"}}}
+	got := lib.presets([]Preset{
+		{Mode: "solid", Color: "#00ff00"},
+		{ID: "red"},
+		{ID: "deleted"},
+	})
+	if len(got) != 2 || got[0].Color != "#00ff00" || got[1].Name != "Red" {
+		t.Errorf("Expected the key's own preset then the library's, got %+v", got)
+	}
+}
+
+func TestGradientCycleFollowsLibraryEdits(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	ctx := t.Name()
+	settings := map[string]any{"presets": []Preset{{ID: "brand"}}}
+	setLibrary := func(color string) {
+		d.sendPayload(backPresetsUUID, streamdeck.SendToPlugin, ctx, piMessage{
+			Event:   "setLibrary",
+			Library: &Library{Presets: map[string]Preset{"brand": {Name: "Brand", Mode: "solid", Color: color}}},
+		})
+	}
+
+	d.send(backPresetsUUID, streamdeck.WillAppear, ctx, settings)
+	if title := d.expectTitle(ctx); title != "None" {
+		t.Errorf("Expected no presets before the library arrives, got %q", title)
+	}
+
+	setLibrary("#ff0000")
+	if title := d.expectTitle(ctx); title != "Brand\n1/1" {
+		t.Errorf("Expected the library preset's name, got %q", title)
+	}
+
+	// Editing the library preset changes what the key sets.
+	setLibrary("#0000ff")
+	d.expectTitle(ctx)
+	d.send(backPresetsUUID, streamdeck.KeyDown, ctx, settings)
+	d.expectTitle(ctx)
+	want := append(
+		[][]byte{logitech.ConvertLightsOnTarget(logitech.BackLight, logitech.BackLightFID)},
+		logitech.ConvertBackColorFrame(logitech.Fill(hexToRGBA("#0000ff")))...,
+	)
+	assertReports(t, d.waitReports(len(want)), want...)
+}
+
+func TestLibraryFromGlobalSettings(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	ctx := t.Name()
+	settings := map[string]any{"presets": []Preset{{ID: "brand"}}}
+
+	d.send(backPresetsUUID, streamdeck.WillAppear, ctx, settings)
+	if title := d.expectTitle(ctx); title != "None" {
+		t.Errorf("Expected no presets before the library arrives, got %q", title)
+	}
+
+	// The plugin reads the library itself, without a Property Inspector open.
+	d.sendPayload("", streamdeck.DeviceDidConnect, "", nil)
+	d.expect(streamdeck.GetGlobalSettings, "test-plugin")
+	global, err := json.Marshal(globalSettings{
+		Library: &Library{Presets: map[string]Preset{"brand": {Name: "Brand", Mode: "solid", Color: "#ff0000"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.sendPayload("", streamdeck.DidReceiveGlobalSettings, "test-plugin", streamdeck.DidReceiveGlobalSettingsPayload{Settings: global})
+	if title := d.expectTitle(ctx); title != "Brand\n1/1" {
+		t.Errorf("Expected the library preset's name, got %q", title)
+	}
+}
+
+func TestApplySceneFromLibrary(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	m := logitech.LitraBeamLX
+	ctx := t.Name()
+	settings := SceneSettings{SceneID: "call"}
+
+	d.send(sceneUUID, streamdeck.WillAppear, ctx, settings)
+	d.expect(streamdeck.SetImage, ctx)
+	d.expectTitle(ctx)
+
+	// The key redraws when its library scene arrives.
+	d.sendPayload(sceneUUID, streamdeck.SendToPlugin, ctx, piMessage{
+		Event: "setLibrary",
+		Library: &Library{Scenes: map[string]Scene{"call": {
+			Name:  "Call",
+			Front: SceneLight{Power: "on", Brightness: 90},
+			Back:  SceneLight{Power: "off"},
+		}}},
+	})
+	d.expect(streamdeck.SetImage, ctx)
+	if title := d.expectTitle(ctx); title != "Call" {
+		t.Errorf("Expected the library scene's name, got %q", title)
+	}
+
+	d.send(sceneUUID, streamdeck.KeyDown, ctx, settings)
+	assertReports(t, d.waitReports(3),
+		mustBytes(m.Brightness(logitech.FrontLight, 90)),
+		mustBytes(m.LightsOn(logitech.FrontLight)),
+		mustBytes(m.LightsOff(logitech.BackLight)),
+	)
+}
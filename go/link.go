@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+// links are the targets whose back lights follow their front light's
+// temperature. A light plugged in later is linked if a target selects it.
+// The DeviceManager's mu, when held, is taken before this one's.
+type links struct {
+	mu      sync.Mutex
+	running []*link
+}
+
+// link is one target's back lights following the front.
+type link struct {
+	target   string
+	unlinked func() // called once the link is removed
+}
+
+// LinkBackToFront makes the back lights of target white matching their front
+// light's temperature after every change to it, until UnlinkBack. It first
+// removes the links of targets sharing lights with it. unlinked is called
+// when the link is removed.
+func (dm *DeviceManager) LinkBackToFront(target string, unlinked func()) {
+	dm.UnlinkBack(target)
+	dm.links.mu.Lock()
+	defer dm.links.mu.Unlock()
+	dm.links.running = append(dm.links.running, &link{target: target, unlinked: unlinked})
+}
+
+// UnlinkBack stops the back lights of any of target's lights following the
+// front. It reports whether any did.
+func (dm *DeviceManager) UnlinkBack(target string) bool {
+	dm.links.mu.Lock()
+	current := slices.Clone(dm.links.running)
+	dm.links.mu.Unlock()
+
+	// overlaps takes mu, so it is called without holding the links' lock.
+	var removed []*link
+	for _, l := range current {
+		if dm.overlaps(l.target, target) {
+			removed = append(removed, l)
+		}
+	}
+
+	dm.links.mu.Lock()
+	dm.links.running = slices.DeleteFunc(dm.links.running, func(l *link) bool {
+		return slices.Contains(removed, l)
+	})
+	dm.links.mu.Unlock()
+
+	for _, l := range removed {
+		if l.unlinked != nil {
+			l.unlinked()
+		}
+	}
+	return len(removed) > 0
+}
+
+// Linked reports whether the back lights of exactly target follow the front.
+func (dm *DeviceManager) Linked(target string) bool {
+	dm.links.mu.Lock()
+	defer dm.links.mu.Unlock()
+	return slices.ContainsFunc(dm.links.running, func(l *link) bool {
+		return l.target == target
+	})
+}
+
+// linked reports whether the back light of the light with the given ID
+// follows its front light. Must be called with mu held.
+func (dm *DeviceManager) linked(id string) bool {
+	dm.links.mu.Lock()
+	defer dm.links.mu.Unlock()
+	return slices.ContainsFunc(dm.links.running, func(l *link) bool {
+		return dm.selects(l.target, id)
+	})
+}
+
+// matchBack returns the commands making l's back light white at the front
+// light's temperature, remembered like any back light colour once written.
+func matchBack(l *Light, kelvin uint16) ([][]byte, error) {
+	colorCmds, err := l.Model.Frame(logitech.BackLight, logitech.Fill(l.Model.White(kelvin)))
+	if err != nil {
+		return nil, err
+	}
+	// Remembered for back power restore once the write succeeds
+	l.rememberBackColors(colorCmds)
+	return colorCmds, nil
+}
+
+// followFront matches a linked light's back light to the temperature commands
+// just set on its front light. Must be called with mu held.
+func (dm *DeviceManager) followFront(ctx context.Context, l *Light, commands [][]byte) {
+	var kelvin uint16
+	for _, cmd := range commands {
+		if e, ok := l.Model.DecodeSetting(cmd); ok && e.Kind == logitech.TemperatureChanged {
+			kelvin = e.Temperature
+		}
+	}
+	if kelvin == 0 || !dm.linked(l.ID) {
+		return
+	}
+	backCmds, err := matchBack(l, kelvin)
+	if errors.Is(err, logitech.ErrUnsupported) {
+		return
+	}
+	if err == nil {
+		err = dm.write(ctx, l, backCmds)
+	}
+	if err != nil {
+		log.Printf("Unable to match %s's back light to its front: %v", l.ID, err)
+	}
+}
+
+// followReportedFront matches a linked light's back light to a temperature
+// the light reported changing on its own, such as with its buttons.
+func (dm *DeviceManager) followReportedFront(id string, e logitech.Event) {
+	if e.Kind != logitech.TemperatureChanged {
+		return
+	}
+	dm.mu.Lock()
+	linked := dm.linked(id)
+	dm.mu.Unlock()
+	if !linked {
+		return
+	}
+	dm.Submit(context.Background(), Command{Target: id, Attribute: "back.color", Build: func(l *Light) ([][]byte, error) {
+		return matchBack(l, e.Temperature)
+	}})
+}
+
+// MatchBackCommand makes the back lights of target white at their front
+// light's current temperature.
+func (dm *DeviceManager) MatchBackCommand(target string) Command {
+	return Command{Target: target, Attribute: "back.color", Build: func(l *Light) ([][]byte, error) {
+		kelvin, err := dm.frontTemperature(l)
+		if err != nil {
+			return nil, err
+		}
+		return matchBack(l, kelvin)
+	}}
+}
+
+// frontTemperature returns the temperature of l's front light, asking the
+// light when it isn't known yet. Must be called with mu held.
+func (dm *DeviceManager) frontTemperature(l *Light) (uint16, error) {
+	if e, ok := l.recall(logitech.FrontLight, logitech.TemperatureChanged); ok {
+		return e.Temperature, nil
+	}
+	request, err := l.Model.GetTemperature(logitech.FrontLight)
+	if err != nil {
+		return 0, err
+	}
+	r, err := dm.query(l, request)
+	if err != nil {
+		return 0, err
+	}
+	kelvin, err := l.Model.DecodeTemperature(r)
+	if err != nil {
+		return 0, err
+	}
+	l.remember(logitech.Event{Target: logitech.FrontLight, Kind: logitech.TemperatureChanged, Temperature: kelvin})
+	return kelvin, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/michaelabon/streamdeck-logitech-litra/internal/transport"
+	"github.com/samwho/streamdeck"
+)
+
+func TestLinkedBackLightFollowsFrontTemperature(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	m := logitech.LitraBeamLX
+	temp := mustBytes(m.Temperature(logitech.FrontLight, 3000))
+	white := mustAll(m.Frame(logitech.BackLight, logitech.Fill(m.White(3000))))
+
+	dm.LinkBackToFront(AllLights, nil)
+	if err := result(t, dm.Submit(context.Background(), writeCmd("front.temperature", temp))); err != nil {
+		t.Fatal(err)
+	}
+	assertReports(t, fake.Written(testPath), append([][]byte{temp}, white...)...)
+	if commands, _ := dm.State.BackColors(testLight.SerialNbr); len(commands) == 0 {
+		t.Error("Expected the white remembered for the back light")
+	}
+
+	// Other commands leave the back light alone, and so does a light unlinked.
+	fake.Reset()
+	bright := mustBytes(m.Brightness(logitech.FrontLight, 50))
+	<-dm.Submit(context.Background(), writeCmd("front.brightness", bright))
+	if !dm.UnlinkBack(AllLights) {
+		t.Error("Expected UnlinkBack to report the link")
+	}
+	<-dm.Submit(context.Background(), writeCmd("front.temperature", temp))
+	assertReports(t, fake.Written(testPath), bright, temp)
+}
+
+func TestLinkedBackLightRemembersOnlyWhatItTook(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	m := logitech.LitraBeamLX
+	fake.Respond(func(info transport.DeviceInfo, report []byte) [][]byte {
+		if len(report) > 2 && report[2] == m.BackColorIndex {
+			return [][]byte{hidError(report, logitech.ErrBusy)}
+		}
+		return emulateLitras(info, report)
+	})
+
+	dm.LinkBackToFront(AllLights, nil)
+	if err := result(t, dm.Submit(context.Background(), writeCmd("front.temperature", mustBytes(m.Temperature(logitech.FrontLight, 3000))))); err != nil {
+		t.Fatal(err)
+	}
+	if commands, _ := dm.State.BackColors(testLight.SerialNbr); commands != nil {
+		t.Errorf("Expected the refused white not remembered, got %d commands", len(commands))
+	}
+}
+
+func TestMatchKeyFollowsTheLight(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	m := logitech.LitraBeamLX
+
+	d.send(backMatchUUID, streamdeck.WillAppear, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "Match\nOFF" {
+		t.Errorf("Expected Match OFF, got %q", title)
+	}
+	d.send(backMatchUUID, streamdeck.KeyDown, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "Match\nON" {
+		t.Errorf("Expected Match ON, got %q", title)
+	}
+	// The emulated front light is at 4000K.
+	written := d.fake.Written(testPath)
+	want := mustAll(m.Frame(logitech.BackLight, logitech.Fill(m.White(4000))))
+	assertReports(t, written[len(written)-len(want):], want...)
+
+	// Turning the temperature on the light itself is followed too.
+	if err := d.fake.QueueRead(testPath, []byte{0x11, 0xff, 0x06, 0x20, 0x0b, 0xb8}); err != nil {
+		t.Fatal(err)
+	}
+	want = mustAll(m.Frame(logitech.BackLight, logitech.Fill(m.White(3000))))
+	written = d.waitReports(len(written) + len(want))
+	assertReports(t, written[len(written)-len(want):], want...)
+
+	// Picking a back light colour stops matching.
+	d.send(backColorUUID, streamdeck.KeyDown, "color", nil)
+	if title := d.expectTitle(t.Name()); title != "Match\nOFF" {
+		t.Errorf("Expected Match OFF, got %q", title)
+	}
+	d.expectTitle("color")
+	if deviceMgr.Linked(AllLights) {
+		t.Error("Expected the back light unlinked")
+	}
+}
+
+func TestWhitePreset(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	m := logitech.LitraBeamLX
+	settings := map[string]any{"presets": []Preset{{Mode: "white", Kelvin: 3200}}}
+
+	d.send(backPresetsUUID, streamdeck.KeyDown, t.Name(), settings)
+	if title := d.expectTitle(t.Name()); title != "3200K\n1/1" {
+		t.Errorf("Expected 3200K 1/1, got %q", title)
+	}
+
+	want := append([][]byte{mustBytes(m.LightsOn(logitech.BackLight))},
+		mustAll(m.Frame(logitech.BackLight, logitech.Fill(m.White(3200))))...)
+	assertReports(t, d.waitReports(len(want)), want...)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+	sdcontext "github.com/samwho/streamdeck/context"
+)
+
+const (
+	setLightsUUID      = "ca.michaelabon.logitech-litra-lights.set"
+	backColorUUID      = "ca.michaelabon.logitech-litra-lights.back.color"
+	backPresetsUUID    = "ca.michaelabon.logitech-litra-lights.back.presets"
+	backEffectUUID     = "ca.michaelabon.logitech-litra-lights.back.effect"
+	frontCircadianUUID = "ca.michaelabon.logitech-litra-lights.front.circadian"
+	backMatchUUID      = "ca.michaelabon.logitech-litra-lights.back.match"
+
+	// Actions whose keys show a light's state and follow its changes.
+	frontPowerUUID       = "ca.michaelabon.logitech-litra-lights.front.power"
+	backPowerUUID        = "ca.michaelabon.logitech-litra-lights.back.power"
+	frontTemperatureUUID = "ca.michaelabon.logitech-litra-lights.front.temperature"
+	frontBrightnessUUID  = "ca.michaelabon.logitech-litra-lights.front.brightness"
+	backBrightnessUUID   = "ca.michaelabon.logitech-litra-lights.back.brightness"
+)
+
+// offlineTitle marks a key while a light it targets is unplugged.
+const offlineTitle = "Offline"
+
+// liveKey is a visible key targeting lights.
+type liveKey struct {
+	action string
+	target string
+}
+
+// liveKeys tracks the visible keys that target lights. It's read from the
+// goroutine delivering light events, so it has its own lock.
+type liveKeys struct {
+	mu   sync.Mutex
+	keys map[string]liveKey // by key context
+}
+
+func newLiveKeys() *liveKeys {
+	return &liveKeys{keys: make(map[string]liveKey)}
+}
+
+func (k *liveKeys) show(ctx string, key liveKey) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys[ctx] = key
+}
+
+func (k *liveKeys) hide(ctx string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.keys, ctx)
+}
+
+// selecting returns the keys, by context, whose target selects light id in dm.
+func (k *liveKeys) selecting(dm *DeviceManager, id string) map[string]liveKey {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := make(map[string]liveKey)
+	for ctx, key := range k.keys {
+		if dm.Selects(key.target, id) {
+			keys[ctx] = key
+		}
+	}
+	return keys
+}
+
+// trackLiveKeys keeps keys of the given actions in k while they are visible.
+func trackLiveKeys(client *streamdeck.Client, k *liveKeys, uuids ...string) {
+	for _, uuid := range uuids {
+		show := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			k.show(event.Context, liveKey{action: uuid, target: targetFromEvent(event)})
+			return nil
+		}
+		hide := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			k.hide(event.Context)
+			return nil
+		}
+
+		action := client.Action(uuid)
+		action.RegisterHandler(streamdeck.WillAppear, show)
+		action.RegisterHandler(streamdeck.DidReceiveSettings, show)
+		action.RegisterHandler(streamdeck.WillDisappear, hide)
+	}
+}
+
+// liveTitles are the titles of the keys showing a light's state, by action.
+var liveTitles = map[string]struct {
+	which logitech.LightTarget
+	kind  logitech.EventKind
+	title func(LightState) string
+}{
+	frontPowerUUID:       {logitech.FrontLight, logitech.PowerChanged, func(s LightState) string { return onOffTitle(s.On) }},
+	backPowerUUID:        {logitech.BackLight, logitech.PowerChanged, func(s LightState) string { return onOffTitle(s.On) }},
+	frontTemperatureUUID: {logitech.FrontLight, logitech.TemperatureChanged, func(s LightState) string { return strconv.Itoa(int(s.Temperature)) + "K" }},
+	frontBrightnessUUID:  {logitech.FrontLight, logitech.BrightnessChanged, func(s LightState) string { return strconv.Itoa(int(s.Brightness)) + "%" }},
+	backBrightnessUUID:   {logitech.BackLight, logitech.BrightnessChanged, func(s LightState) string { return strconv.Itoa(int(s.Brightness)) + "%" }},
+
+	stepFrontBrightnessUUID:  {logitech.FrontLight, logitech.BrightnessChanged, func(s LightState) string { return strconv.Itoa(int(s.Brightness)) + "%" }},
+	stepFrontTemperatureUUID: {logitech.FrontLight, logitech.TemperatureChanged, func(s LightState) string { return strconv.Itoa(int(s.Temperature)) + "K" }},
+	stepBackBrightnessUUID:   {logitech.BackLight, logitech.BrightnessChanged, func(s LightState) string { return strconv.Itoa(int(s.Brightness)) + "%" }},
+}
+
+// followLights updates the titles of visible keys whenever a light's state
+// changes, whether another key changed it or the light reported it, e.g.
+// from its buttons or another app.
+func followLights(client *streamdeck.Client, k *liveKeys) {
+	dm := deviceMgr
+	dm.State.Subscribe(func(change StateChange) {
+		for ctxStr, key := range k.selecting(dm, change.ID) {
+			live, ok := liveTitles[key.action]
+			if !ok || live.which != change.Which || live.kind != change.Kind {
+				continue
+			}
+			ctx := sdcontext.WithContext(context.Background(), ctxStr)
+			if err := client.SetTitle(ctx, live.title(change.State), streamdeck.HardwareAndSoftware); err != nil {
+				log.Printf("Unable to update key %s: %v\n", ctxStr, err)
+			}
+		}
+	})
+}
+
+// followConnections marks the keys of an unplugged light, and shows the state
+// the light was restored to once it is plugged back in.
+func followConnections(client *streamdeck.Client, k *liveKeys) {
+	dm := deviceMgr
+	dm.SubscribeConnections(func(id string, connected bool) {
+		for ctxStr, key := range k.selecting(dm, id) {
+			ctx := sdcontext.WithContext(context.Background(), ctxStr)
+			title := offlineTitle
+			if connected {
+				title = refreshTitle(dm, key)
+			}
+			if err := client.SetTitle(ctx, title, streamdeck.HardwareAndSoftware); err != nil {
+				log.Printf("Unable to update key %s: %v\n", ctxStr, err)
+			}
+		}
+	})
+}
+
+// refreshTitle reads the state a key shows from its light. Keys that don't
+// show light state get an empty title, which brings back their own.
+func refreshTitle(dm *DeviceManager, key liveKey) string {
+	live, ok := liveTitles[key.action]
+	if !ok {
+		return ""
+	}
+	state, err := dm.ReadState(key.target, live.which)
+	if err != nil {
+		log.Printf("Unable to read the %s state: %v\n", live.which, err)
+		return "Err"
+	}
+	return live.title(state)
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image/color"
 	"log"
 	"os"
 	"strconv"
-	"sync"
 	"time"
 
 	"os/signal"
@@ -15,21 +17,163 @@ import (
 
 	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
 	"github.com/samwho/streamdeck"
-	"github.com/sstallion/go-hid"
+	sdcontext "github.com/samwho/streamdeck/context"
 )
 
+// TargetSettings selects which lights an action controls. Every action's settings embed it.
+type TargetSettings struct {
+	Device string `json:"device"` // light ID, "group:<name>", or empty for all lights
+}
+
+// targetFromEvent reads the light target from an event carrying settings.
+func targetFromEvent(event streamdeck.Event) string {
+	p := streamdeck.KeyDownPayload{}
+	s := TargetSettings{}
+	if err := json.Unmarshal(event.Payload, &p); err == nil && len(p.Settings) > 0 {
+		if err := json.Unmarshal(p.Settings, &s); err != nil {
+			log.Println("Error reading light target:", err)
+		}
+	}
+	return s.Device
+}
+
+// transitionFromEvent reads how an event's change fades in from its settings.
+func transitionFromEvent(event streamdeck.Event) Transition {
+	p := streamdeck.KeyDownPayload{}
+	s := TransitionSettings{}
+	if err := json.Unmarshal(event.Payload, &p); err == nil && len(p.Settings) > 0 {
+		if err := json.Unmarshal(p.Settings, &s); err != nil {
+			log.Println("Error reading the transition:", err)
+		}
+	}
+	return s.transition()
+}
+
+// showError logs a failed action and marks the key: "N/A" when the light's
+// model doesn't have the feature, "Err" otherwise, followed by the HID++ error
+// when the light refused the command.
+func showError(ctx context.Context, client *streamdeck.Client, what string, err error) error {
+	log.Printf("Error %s: %v\n", what, err)
+	title := "Err"
+	var deviceErr *logitech.DeviceError
+	switch {
+	case errors.Is(err, logitech.ErrUnsupported):
+		title = "N/A"
+	case errors.As(err, &deviceErr):
+		title = "Err\n" + deviceErr.Code.String()
+	}
+	return client.SetTitle(ctx, title, streamdeck.HardwareAndSoftware)
+}
+
+// submit queues cmd and returns at once, so the keys stay responsive while
+// the lights are slow or reconnecting. Once the lights took the command, the
+// key shows title, unless it is empty; if they couldn't, it shows the error.
+// A command a newer one superseded leaves the key to the newer one.
+func submit(ctx context.Context, client *streamdeck.Client, what string, cmd Command, title string) error {
+	takeOver(cmd.Target, cmd.Attribute)
+	await(ctx, client, what, deviceMgr.Submit(ctx, cmd), title)
+	return nil
+}
+
+// submitFade is submit for a change that fades in over tr. The key shows
+// title once the fade finished.
+func submitFade(ctx context.Context, client *streamdeck.Client, what string, fade Fade, tr Transition, title string) error {
+	takeOver(fade.Target, fade.Attribute)
+	await(ctx, client, what, deviceMgr.SubmitFade(ctx, fade, tr), title)
+	return nil
+}
+
+// await updates the key once done reports how a submitted change went.
+func await(ctx context.Context, client *streamdeck.Client, what string, done <-chan error, title string) {
+	go func() {
+		var err error
+		switch result := <-done; {
+		case errors.Is(result, ErrSuperseded):
+		case result != nil:
+			err = showError(ctx, client, what, result)
+		case title != "":
+			err = client.SetTitle(ctx, title, streamdeck.HardwareAndSoftware)
+		}
+		if err != nil {
+			log.Printf("Unable to update the key after %s: %v\n", what, err)
+		}
+	}()
+}
+
+// takesOverBackLight lists the attributes of commands that stop an effect
+// running on the back lights they change, so the effect's next frame doesn't
+// undo them.
+var takesOverBackLight = map[string]bool{
+	"back.color": true,
+	"back.power": true,
+	"power":      true,
+	"scene":      true,
+}
+
+// takesOverFrontTemperature lists the attributes of commands that stop the
+// front lights they change from following the sun.
+var takesOverFrontTemperature = map[string]bool{
+	"front.temperature": true,
+	"front":             true,
+	"scene":             true,
+}
+
+// takeOver stops what would undo a key's change to the lights of target: a
+// back light effect, the back light matching the front, or the front light
+// following the sun.
+func takeOver(target, attribute string) {
+	if takesOverBackLight[attribute] {
+		deviceMgr.StopEffects(target)
+	}
+	if attribute == "back.color" || attribute == "scene" {
+		deviceMgr.UnlinkBack(target)
+	}
+	if takesOverFrontTemperature[attribute] {
+		deviceMgr.StopFollowing(target)
+	}
+}
+
+// showState gives a key that just appeared the state of the light it
+// targets, so it shows the real value instead of a guess. What the plugin
+// already knows is shown at once; otherwise the light is asked in the
+// background, like await, so a page of keys on a slow or unplugged light
+// doesn't hold up every other event. ok is false if the light couldn't say.
+func showState(event streamdeck.Event, which logitech.LightTarget, show func(state LightState, ok bool) error) error {
+	dm, target := deviceMgr, targetFromEvent(event)
+	if state, ok := dm.KnownState(target, which); ok {
+		return show(state, true)
+	}
+	go func() {
+		state, err := dm.ReadState(target, which)
+		if err != nil {
+			log.Printf("Unable to read the %s state: %v\n", which, err)
+		}
+		if err := show(state, err == nil); err != nil {
+			log.Printf("Unable to update key %s: %v\n", event.Context, err)
+		}
+	}()
+	return nil
+}
+
+// nextPresetIndex returns the index of the first preset above current, so a
+// cycle continues from the light's real value.
+func nextPresetIndex[T cmp.Ordered](presets []T, current T) int {
+	for i, p := range presets {
+		if p > current {
+			return i
+		}
+	}
+	return 0
+}
+
 // Settings for the existing "Set Brightness & Temperature" action
 type Settings struct {
+	TargetSettings
+	TransitionSettings
 	Temperature uint16 `json:"temperature,string"`
 	Brightness  uint8  `json:"brightness,string"`
 }
 
-// CycleSettings stores a list of preset values and the current index
-type CycleSettings struct {
-	Presets []string `json:"presets"`
-	Index   int      `json:"cycleIndex"`
-}
-
 // RGBSettings for the back light color picker
 type RGBSettings struct {
 	Red    uint8  `json:"red,string"`
@@ -67,149 +211,82 @@ func hexToRGB(hex string) (uint8, uint8, uint8) {
 	return uint8(r), uint8(g), uint8(b)
 }
 
-// Preset represents a saved color or gradient
+// Preset represents a saved color, gradient or per-zone frame
+//
+// A key's preset with an ID refers to the library's preset of that ID instead.
 type Preset struct {
-	Mode   string `json:"mode"`   // "solid" or "gradient"
-	Color  string `json:"color"`  // hex color like "#ff0000"
-	Color2 string `json:"color2"` // second hex color for gradient
+	ID     string       `json:"id,omitempty"`
+	Name   string       `json:"name,omitempty"`
+	Mode   string       `json:"mode"`             // "solid", "gradient", "zones" or "white"
+	Color  string       `json:"color"`            // hex color like "#ff0000"
+	Color2 string       `json:"color2"`           // second hex color for gradient
+	Stops  []PresetStop `json:"stops,omitempty"`  // gradient stops, used instead of color and color2
+	Colors []string     `json:"colors,omitempty"` // one hex color per zone, zone 1 first
+	Kelvin uint16       `json:"kelvin,omitempty"` // the temperature a "white" preset matches
+
+	// Interpolation is the color space a gradient blends in: "srgb" (the
+	// default), "oklab", "hsv" or "hsv-longer".
+	Interpolation string `json:"interpolation,omitempty"`
+}
+
+// PresetStop is one color of a multi-stop gradient preset.
+type PresetStop struct {
+	Position float64 `json:"position"` // 0 (zone 1) to 1 (zone 7)
+	Color    string  `json:"color"`
 }
 
 // PresetCycleSettings stores a list of presets and the current index
 type PresetCycleSettings struct {
+	TargetSettings
+	TransitionSettings
 	Presets []Preset `json:"presets"`
 	Index   int      `json:"cycleIndex"`
 }
 
-// --- Internal helpers for color conversion ---
-
-func getRGBFromPreset(p Preset) (r, g, b, r2, g2, b2 uint8) {
-	r, g, b = hexToRGB(p.Color)
-	if p.Mode == "gradient" {
-		r2, g2, b2 = hexToRGB(p.Color2)
-	}
-	return
-}
-
-const (
-	VID       = 0x046d
-	PID       = 0xc903
-	UsagePage = 0xff43
-
-	maxRetries  = 3
-	retryDelay  = 500 * time.Millisecond
-	reopenDelay = 1 * time.Second
-)
-
-// DeviceManager maintains a persistent HID connection to the Litra device.
-// All writes are serialized through a mutex to prevent overlapped I/O conflicts.
-type DeviceManager struct {
-	mu     sync.Mutex
-	device *hid.Device
-	path   string
-}
-
-var deviceMgr = &DeviceManager{}
-
-// connect finds and opens the Litra HID device. Must be called with mu held.
-func (dm *DeviceManager) connect() error {
-	if dm.device != nil {
-		return nil // already connected
-	}
-
-	if err := hid.Init(); err != nil {
-		return fmt.Errorf("hid.Init: %w", err)
-	}
-
-	var foundPath string
-	err := hid.Enumerate(VID, PID, func(info *hid.DeviceInfo) error {
-		if info.UsagePage == UsagePage {
-			foundPath = info.Path
-		}
-		return nil
-	})
-	if err != nil {
-		hid.Exit()
-		return fmt.Errorf("hid.Enumerate: %w", err)
-	}
-
-	if foundPath == "" {
-		hid.Exit()
-		return fmt.Errorf("no Litra device found (VID=0x%04x PID=0x%04x UsagePage=0x%04x)", VID, PID, UsagePage)
-	}
-
-	d, err := hid.OpenPath(foundPath)
-	if err != nil {
-		hid.Exit()
-		return fmt.Errorf("hid.OpenPath(%s): %w", foundPath, err)
-	}
-
-	dm.device = d
-	dm.path = foundPath
-	log.Printf("HID device connected: %s", foundPath)
-	return nil
-}
-
-// reconnect closes the current connection and opens a new one. Must be called with mu held.
-func (dm *DeviceManager) reconnect() error {
-	if dm.device != nil {
-		dm.device.Close()
-		dm.device = nil
-	}
-	hid.Exit()
-	time.Sleep(reopenDelay)
-	return dm.connect()
-}
-
-// Close shuts down the device connection.
-func (dm *DeviceManager) Close() {
-	dm.mu.Lock()
-	defer dm.mu.Unlock()
-	if dm.device != nil {
-		dm.device.Close()
-		dm.device = nil
-	}
-	hid.Exit()
-}
-
-// WriteCommands sends one or more byte sequences to the device, with retry on failure.
-func (dm *DeviceManager) WriteCommands(commands ...[]byte) error {
-	dm.mu.Lock()
-	defer dm.mu.Unlock()
-
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if err := dm.connect(); err != nil {
-			log.Printf("Connect failed (attempt %d/%d): %v", attempt+1, maxRetries+1, err)
-			if attempt < maxRetries {
-				time.Sleep(retryDelay)
-				dm.reconnect()
-			}
-			continue
-		}
-
-		var writeErr error
-		for _, cmd := range commands {
-			if _, err := dm.device.Write(cmd); err != nil {
-				writeErr = err
-				break
+// Frame returns the zone colors the preset sets. Zones a "zones" preset
+// doesn't list are white, like an invalid hex color. A "white" preset is
+// the uncorrected color of its temperature; the lights use fadeWhite.
+func (p Preset) Frame() logitech.Frame {
+	switch p.Mode {
+	case "white":
+		return logitech.Fill(logitech.Kelvin(p.Kelvin))
+	case "zones":
+		var frame logitech.Frame
+		for i := range frame {
+			frame[i] = hexToRGBA("")
+			if i < len(p.Colors) {
+				frame[i] = hexToRGBA(p.Colors[i])
 			}
 		}
-
-		if writeErr == nil {
-			return nil // success
+		return frame
+	case "gradient":
+		gradient := logitech.Gradient{
+			Stops: []logitech.Stop{
+				{Position: 0, Color: hexToRGBA(p.Color)},
+				{Position: 1, Color: hexToRGBA(p.Color2)},
+			},
+			Space: logitech.ParseInterpolation(p.Interpolation),
 		}
-
-		log.Printf("Write failed (attempt %d/%d): %v", attempt+1, maxRetries+1, writeErr)
-		if attempt < maxRetries {
-			time.Sleep(retryDelay)
-			if err := dm.reconnect(); err != nil {
-				log.Printf("Reconnect failed: %v", err)
+		if len(p.Stops) > 0 {
+			gradient.Stops = nil
+			for _, stop := range p.Stops {
+				gradient.Stops = append(gradient.Stops, logitech.Stop{Position: stop.Position, Color: hexToRGBA(stop.Color)})
 			}
-		} else {
-			return writeErr
 		}
+		return gradient.Frame()
 	}
+	return logitech.Fill(hexToRGBA(p.Color))
+}
+
+// --- Internal helpers for color conversion ---
 
-	return fmt.Errorf("all %d write attempts failed", maxRetries+1)
+func hexToRGBA(hex string) color.RGBA {
+	r, g, b := hexToRGB(hex)
+	return color.RGBA{R: r, G: g, B: b, A: 0xff}
+}
+
+func rgbaToHex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
 }
 
 func main() {
@@ -250,8 +327,13 @@ func run(ctx context.Context) error {
 		return err
 	}
 
+	sf := openStateFile(stateFilePath())
+	sf.Apply(deviceMgr)
+
 	client := streamdeck.NewClient(ctx, params)
-	setup(client)
+	setup(client, sf, params.PluginUUID)
+	deviceMgr.Watch(ctx)
+	sf.Persist(ctx, deviceMgr)
 
 	// Set up signal handling for graceful shutdown
 	stop := make(chan os.Signal, 1)
@@ -259,24 +341,57 @@ func run(ctx context.Context) error {
 
 	go func() {
 		<-stop
-		log.Println("Received termination signal, turning off lights...")
-		turnOffAllLights()
+		log.Println("Received termination signal")
+		saveState(sf)
+		turnOffOnExit(sf)
 		deviceMgr.Close()
 		os.Exit(0)
 	}()
 
 	err = client.Run()
 
-	// Power off all lights when Stream Deck quits
-	log.Println("Plugin exiting, turning off lights...")
-	turnOffAllLights()
+	log.Println("Plugin exiting")
+	saveState(sf)
+	turnOffOnExit(sf)
 	deviceMgr.Close()
 
 	return err
 }
 
-func setup(client *streamdeck.Client) {
+// saveState saves the lights' state before they're turned off on exit, so
+// they come back as they were rather than off.
+func saveState(sf *stateFile) {
+	if err := sf.Close(deviceMgr); err != nil {
+		log.Printf("Unable to save the state file: %v\n", err)
+	}
+}
+
+// lightActions are the actions whose keys target lights, chosen in the light picker.
+var lightActions = []string{
+	setLightsUUID,
+	frontPowerUUID,
+	backPowerUUID,
+	frontTemperatureUUID,
+	frontBrightnessUUID,
+	backBrightnessUUID,
+	backColorUUID,
+	backPresetsUUID,
+	backEffectUUID,
+	frontCircadianUUID,
+	backMatchUUID,
+	sceneUUID,
+	stepFrontBrightnessUUID,
+	stepFrontTemperatureUUID,
+	stepBackBrightnessUUID,
+	dialFrontBrightnessUUID,
+	dialFrontTemperatureUUID,
+	dialBackBrightnessUUID,
+	dialBackHueUUID,
+}
+
+func setup(client *streamdeck.Client, sf *stateFile, pluginUUID string) {
 	settings := make(map[string]*Settings)
+	positions := sf.cycles
 
 	// Existing actions
 	setupSetLightsAction(client, settings)
@@ -285,24 +400,146 @@ func setup(client *streamdeck.Client) {
 	// Litra Beam LX actions
 	setupFrontPowerAction(client)
 	setupBackPowerAction(client)
-	setupFrontTempCycleAction(client)
-	setupFrontBrightnessCycleAction(client)
-	setupBackBrightnessCycleAction(client)
-	setupBackColorCycleAction(client)
-	setupBackGradientCycleAction(client)
+	setupFrontTempCycleAction(client, positions)
+	setupFrontCircadianAction(client)
+	setupFrontBrightnessCycleAction(client, positions)
+	setupBackBrightnessCycleAction(client, positions)
+	setupBackColorCycleAction(client, sf)
+	setupBackGradientCycleAction(client, sf)
+	setupBackEffectAction(client)
+	setupBackMatchAction(client)
+	setupSceneAction(client, sf)
+	setupStepActions(client)
+
+	// Stream Deck+ dials
+	dials := newDials()
+	setupDialActions(client, dials)
+
+	// Every key that targets lights is tracked, to mark it while its light is unplugged.
+	keys := newLiveKeys()
+	trackLiveKeys(client, keys, lightActions...)
+	followLights(client, keys)
+	followConnections(client, keys)
+	followDials(client, dials)
+
+	setupLightPicker(client, sf, pluginUUID, lightActions...)
+}
+
+// piMessage is exchanged with the Property Inspector through sendToPlugin and sendToPropertyInspector.
+type piMessage struct {
+	Event   string              `json:"event"`
+	Lights  []LightInfo         `json:"lights,omitempty"`
+	Groups  map[string][]string `json:"groups,omitempty"`
+	Library *Library            `json:"library,omitempty"`
+	Startup *StartupPolicy      `json:"startup,omitempty"`
+	Scene   *Scene              `json:"scene,omitempty"`  // the lights' current look, captured as a scene
+	Name    string              `json:"name,omitempty"`   // what to call the captured scene
+	Device  string              `json:"device,omitempty"` // the lights to capture
+}
+
+// globalSettings are the settings the Property Inspector shares between every key.
+type globalSettings struct {
+	Groups  map[string][]string `json:"groups"`
+	Library *Library            `json:"library"`
+}
+
+// setupLightPicker answers the Property Inspector's light picker.
+//
+// The Property Inspector owns the light groups and the library: it keeps them
+// in the global settings, which the plugin asks for once it's connected and
+// the Property Inspector also forwards as it edits them.
+//
+// The state file keeps the last copy of both, with the startup policy the
+// Property Inspector also edits, for the keys that appear before the global
+// settings arrive.
+func setupLightPicker(client *streamdeck.Client, sf *stateFile, pluginUUID string, uuids ...string) {
+	setGroups := func(groups map[string][]string) {
+		log.Printf("Light groups updated: %v\n", groups)
+		deviceMgr.SetGroups(groups)
+		sf.SetGroups(groups)
+	}
+	setLibrary := func(lib Library) {
+		log.Printf("Library updated: %d presets, %d scenes\n", len(lib.Presets), len(lib.Scenes))
+		sf.SetLibrary(lib)
+	}
+
+	// The Stream Deck app tells the plugin of each device as soon as it has
+	// registered, which is the first chance to ask for the global settings.
+	client.RegisterNoActionHandler(streamdeck.DeviceDidConnect, func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		return client.GetGlobalSettings(sdcontext.WithContext(ctx, pluginUUID))
+	})
+	client.RegisterNoActionHandler(streamdeck.DidReceiveGlobalSettings, func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		payload := streamdeck.DidReceiveGlobalSettingsPayload{}
+		if err := json.Unmarshal(event.Payload, &payload); err != nil {
+			return err
+		}
+		global := globalSettings{}
+		if len(payload.Settings) > 0 {
+			if err := json.Unmarshal(payload.Settings, &global); err != nil {
+				return err
+			}
+		}
+		// Settings the Property Inspector has never saved leave the state file's copy.
+		if global.Groups != nil {
+			setGroups(global.Groups)
+		}
+		if global.Library != nil {
+			setLibrary(*global.Library)
+		}
+		return nil
+	})
+
+	handler := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		msg := piMessage{}
+		if err := json.Unmarshal(event.Payload, &msg); err != nil {
+			return err
+		}
+
+		switch msg.Event {
+		case "getLights":
+			return client.SendToPropertyInspector(ctx, piMessage{Event: "lights", Lights: deviceMgr.Lights()})
+		case "setGroups":
+			setGroups(msg.Groups)
+		case "setLibrary":
+			if msg.Library == nil {
+				return nil
+			}
+			setLibrary(*msg.Library)
+		case "getStartup":
+			startup := sf.Startup()
+			return client.SendToPropertyInspector(ctx, piMessage{Event: "startup", Startup: &startup})
+		case "setStartup":
+			if msg.Startup == nil {
+				return nil
+			}
+			log.Printf("Startup policy: %+v\n", *msg.Startup)
+			sf.SetStartup(*msg.Startup)
+		case "captureScene":
+			scene := currentScene(deviceMgr, cmp.Or(msg.Device, AllLights), msg.Name)
+			return client.SendToPropertyInspector(ctx, piMessage{Event: "capturedScene", Scene: &scene})
+		}
+		return nil
+	}
+
+	for _, uuid := range uuids {
+		client.Action(uuid).RegisterHandler(streamdeck.SendToPlugin, handler)
+	}
 }
 
 // ColorCycleSettings stores configurable solid color presets
 type ColorCycleSettings struct {
+	TargetSettings
+	TransitionSettings
 	ColorPresets []string `json:"colorPresets"`
+	PresetIDs    []string `json:"presetIds,omitempty"` // library presets, cycled instead of the colors
 	Index        int      `json:"cycleIndex"`
 }
 
 var defaultColorPresets = []string{"#FF0000", "#00FF00", "#0000FF", "#FF00FF", "#FFFF00", "#00FFFF"}
 
 // --- Back Color Cycle (configurable solid color presets) ---
-func setupBackColorCycleAction(client *streamdeck.Client) {
-	action := client.Action("ca.michaelabon.logitech-litra-lights.back.color")
+func setupBackColorCycleAction(client *streamdeck.Client, sf *stateFile) {
+	action := client.Action(backColorUUID)
 	settings := make(map[string]*ColorCycleSettings)
 
 	handler := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
@@ -329,42 +566,41 @@ func setupBackColorCycleAction(client *streamdeck.Client) {
 			s.ColorPresets = append([]string{}, defaultColorPresets...)
 		}
 
+		// Library presets, when the key has any left, replace its colors.
+		library := sf.Library().presetsByID(s.PresetIDs)
+		count := len(s.ColorPresets)
+		if len(library) > 0 {
+			count = len(library)
+		}
+
 		if event.Event == streamdeck.KeyDown {
-			idx := s.Index % len(s.ColorPresets)
-			hex := s.ColorPresets[idx]
-			r, g, b := hexToRGB(hex)
+			idx := s.Index % count
 
 			// Advance index for next press
-			s.Index = (s.Index + 1) % len(s.ColorPresets)
+			s.Index = (s.Index + 1) % count
 			client.SetSettings(ctx, s)
 
-			log.Printf("Back Color Cycle: %s (%d, %d, %d) [%d/%d]\n", hex, r, g, b, idx+1, len(s.ColorPresets))
-
-			onBytes := logitech.ConvertLightsOnTarget(logitech.BackLight)
-			colorCmds, err := logitech.ConvertColorTarget(logitech.BackLight, r, g, b)
-			if err != nil {
-				log.Println("Error building color commands:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-			}
-			commands := append([][]byte{onBytes}, colorCmds...)
-
-			if err := deviceMgr.WriteCommands(commands...); err != nil {
-				log.Println("Error setting back color:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
+			var colors fader
+			if len(library) > 0 {
+				preset := library[idx]
+				log.Printf("Back Color Cycle: library preset %q [%d/%d]\n", preset.Name, idx+1, count)
+				colors = presetColors(preset)
+			} else {
+				hex := s.ColorPresets[idx]
+				r, g, b := hexToRGB(hex)
+				log.Printf("Back Color Cycle: %s (%d, %d, %d) [%d/%d]\n", hex, r, g, b, idx+1, count)
+				colors = fadeFrame(logitech.Fill(hexToRGBA(hex)))
 			}
 
-			// Remember for back power restore
-			lastBackLightCmds = colorCmds
+			fade := Fade{Target: s.Device, Attribute: "back.color", Fader: fadeAll(turnOn(logitech.BackLight), colors)}
 
 			// Show a short label
-			return client.SetTitle(ctx, fmt.Sprintf("%d/%d", idx+1, len(s.ColorPresets)), streamdeck.HardwareAndSoftware)
+			return submitFade(ctx, client, "setting back color", fade, s.transition(), fmt.Sprintf("%d/%d", idx+1, count))
 		}
 
 		// Non-keydown: show current position
-		if len(s.ColorPresets) > 0 {
-			idx := s.Index % len(s.ColorPresets)
-			client.SetTitle(ctx, fmt.Sprintf("%d/%d", idx+1, len(s.ColorPresets)), streamdeck.HardwareAndSoftware)
-		}
+		idx := s.Index % count
+		client.SetTitle(ctx, fmt.Sprintf("%d/%d", idx+1, count), streamdeck.HardwareAndSoftware)
 
 		return nil
 	}
@@ -374,9 +610,40 @@ func setupBackColorCycleAction(client *streamdeck.Client) {
 	action.RegisterHandler(streamdeck.KeyDown, handler)
 }
 
+// presetColors sets the back light to a preset's colors. A white preset is
+// corrected for each light.
+func presetColors(p Preset) fader {
+	if p.Mode == "white" {
+		return fadeWhite(p.Kelvin)
+	}
+	return fadeFrame(p.Frame())
+}
+
+// showPresetPosition titles a Back Gradient Cycle key with the preset it's at.
+func showPresetPosition(ctx context.Context, client *streamdeck.Client, presets []Preset, index int) error {
+	if len(presets) == 0 {
+		return client.SetTitle(ctx, "None", streamdeck.HardwareAndSoftware)
+	}
+	idx := index % len(presets)
+	preset := presets[idx]
+	title := "Set"
+	switch preset.Mode {
+	case "gradient":
+		title = "Grad"
+	case "zones":
+		title = "Zones"
+	case "white":
+		title = strconv.Itoa(int(preset.Kelvin)) + "K"
+	}
+	if preset.Name != "" {
+		title = preset.Name
+	}
+	return client.SetTitle(ctx, fmt.Sprintf("%s\n%d/%d", title, idx+1, len(presets)), streamdeck.HardwareAndSoftware)
+}
+
 // --- Back Gradient Cycle ---
-func setupBackGradientCycleAction(client *streamdeck.Client) {
-	action := client.Action("ca.michaelabon.logitech-litra-lights.back.presets")
+func setupBackGradientCycleAction(client *streamdeck.Client, sf *stateFile) {
+	action := client.Action(backPresetsUUID)
 	settings := make(map[string]*PresetCycleSettings)
 
 	handler := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
@@ -398,52 +665,127 @@ func setupBackGradientCycleAction(client *streamdeck.Client) {
 			return err
 		}
 
-		if len(s.Presets) > 0 {
-			idx := s.Index % len(s.Presets)
-			preset := s.Presets[idx]
-			title := "Set"
-			if preset.Mode == "gradient" {
-				title = "Grad"
-			}
-			client.SetTitle(ctx, fmt.Sprintf("%s\n%d/%d", title, idx+1, len(s.Presets)), streamdeck.HardwareAndSoftware)
-		} else {
-			client.SetTitle(ctx, "None", streamdeck.HardwareAndSoftware)
-		}
+		presets := sf.Library().presets(s.Presets)
+		showPresetPosition(ctx, client, presets, s.Index)
 
 		if event.Event == streamdeck.KeyDown {
-			if len(s.Presets) == 0 {
+			if len(presets) == 0 {
 				return nil
 			}
 
-			idx := s.Index % len(s.Presets)
-			preset := s.Presets[idx]
+			idx := s.Index % len(presets)
+			preset := presets[idx]
 
 			// Advance index for next time
-			s.Index = (s.Index + 1) % len(s.Presets)
+			s.Index = (s.Index + 1) % len(presets)
 			client.SetSettings(ctx, s)
 
-			r1, g1, b1, r2, g2, b2 := getRGBFromPreset(preset)
-			log.Printf("Back Preset Cycle: Applying preset %d/%d (mode=%s)\n", idx+1, len(s.Presets), preset.Mode)
+			log.Printf("Back Preset Cycle: Applying preset %d/%d (mode=%s)\n", idx+1, len(presets), preset.Mode)
 
-			onBytes := logitech.ConvertLightsOnTarget(logitech.BackLight)
-			commands := [][]byte{onBytes}
-			if preset.Mode == "gradient" {
-				commands = append(commands, logitech.ConvertBackColorGradient(r1, g1, b1, r2, g2, b2)...)
-			} else {
-				colorCmds, _ := logitech.ConvertColorTarget(logitech.BackLight, r1, g1, b1)
-				commands = append(commands, colorCmds...)
-			}
+			fade := Fade{Target: s.Device, Attribute: "back.color", Fader: fadeAll(turnOn(logitech.BackLight), presetColors(preset))}
+			return submitFade(ctx, client, "applying preset", fade, s.transition(), "")
+		}
 
-			if err := deviceMgr.WriteCommands(commands...); err != nil {
-				log.Println("Error applying preset:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-			}
+		return nil
+	}
 
-			// Remember for back power restore (commands without the onBytes)
-			lastBackLightCmds = commands[1:]
+	action.RegisterHandler(streamdeck.WillAppear, handler)
+	action.RegisterHandler(streamdeck.DidReceiveSettings, handler)
+	action.RegisterHandler(streamdeck.KeyDown, handler)
+
+	// Keys showing library presets retitle when the library is edited.
+	action.RegisterHandler(
+		streamdeck.WillDisappear,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			delete(settings, event.Context)
+			return nil
+		},
+	)
+	sf.WatchLibrary(func(lib Library) {
+		for ctxStr, s := range settings {
+			showPresetPosition(sdcontext.WithContext(context.Background(), ctxStr), client, lib.presets(s.Presets), s.Index)
 		}
+	})
+}
 
-		return nil
+// EffectSettings for the Back Light Effect action
+type EffectSettings struct {
+	TargetSettings
+	Effect    string   `json:"effect"`            // a key of effects
+	Speed     float64  `json:"speed,string"`      // 1 is the effect's normal pace
+	Palette   []string `json:"palette,omitempty"` // hex colors; the effect's own when empty
+	FrameRate int      `json:"fps,string"`        // frames per second
+}
+
+func (s *EffectSettings) params() EffectParams {
+	p := EffectParams{Speed: s.Speed}
+	for _, hex := range s.Palette {
+		p.Palette = append(p.Palette, hexToRGBA(hex))
+	}
+	return p
+}
+
+// --- Back Light Effect ---
+func setupBackEffectAction(client *streamdeck.Client) {
+	action := client.Action(backEffectUUID)
+	settings := make(map[string]*EffectSettings)
+
+	title := func(s *EffectSettings, on bool) string {
+		name := effects[s.Effect].Title
+		if on {
+			return name + "\nOn"
+		}
+		return name + "\nOff"
+	}
+
+	handler := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		p := streamdeck.WillAppearPayload{}
+		if err := json.Unmarshal(event.Payload, &p); err != nil {
+			return err
+		}
+
+		s, ok := settings[event.Context]
+		if !ok {
+			s = &EffectSettings{}
+			settings[event.Context] = s
+		}
+		if err := json.Unmarshal(p.Settings, s); err != nil {
+			return err
+		}
+		if _, ok := effects[s.Effect]; !ok {
+			s.Effect = "rainbow"
+		}
+
+		running, ok := deviceMgr.RunningEffect(s.Device)
+		on := ok && running == s.Effect
+
+		if event.Event != streamdeck.KeyDown {
+			return client.SetTitle(ctx, title(s, on), streamdeck.HardwareAndSoftware)
+		}
+
+		if on {
+			deviceMgr.StopEffects(s.Device)
+			return nil
+		}
+
+		effect, err := NewEffect(s.Effect, s.params())
+		if err != nil {
+			return showError(ctx, client, "starting an effect", err)
+		}
+		log.Printf("Back Light Effect: starting %s on %q\n", s.Effect, s.Device)
+		deviceMgr.UnlinkBack(s.Device)
+		off := title(s, false)
+		deviceMgr.StartEffect(s.Device, s.Effect, effect, s.FrameRate, func(err error) {
+			if err != nil {
+				err = showError(ctx, client, "running an effect", err)
+			} else {
+				err = client.SetTitle(ctx, off, streamdeck.HardwareAndSoftware)
+			}
+			if err != nil {
+				log.Printf("Unable to update the key after an effect stopped: %v\n", err)
+			}
+		})
+		return client.SetTitle(ctx, title(s, true), streamdeck.HardwareAndSoftware)
 	}
 
 	action.RegisterHandler(streamdeck.WillAppear, handler)
@@ -451,180 +793,347 @@ func setupBackGradientCycleAction(client *streamdeck.Client) {
 	action.RegisterHandler(streamdeck.KeyDown, handler)
 }
 
-// --- Power States (in-memory tracking) ---
-var (
-	frontOn           = make(map[string]bool)
-	backOn            = make(map[string]bool)
-	lastBackLightCmds [][]byte // tracks the last color/gradient commands sent to the back light
-)
+// --- Back Light Match Front ---
+func setupBackMatchAction(client *streamdeck.Client) {
+	action := client.Action(backMatchUUID)
+
+	title := func(on bool) string {
+		return "Match\n" + onOffTitle(on)
+	}
+
+	action.RegisterHandler(
+		streamdeck.WillAppear,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			return client.SetTitle(ctx, title(deviceMgr.Linked(targetFromEvent(event))), streamdeck.HardwareAndSoftware)
+		},
+	)
+
+	action.RegisterHandler(
+		streamdeck.KeyDown,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			target := targetFromEvent(event)
+			if deviceMgr.UnlinkBack(target) {
+				return nil
+			}
+
+			log.Printf("Back Light Match Front: linking %q\n", target)
+			err := submit(ctx, client, "matching the back light", deviceMgr.MatchBackCommand(target), title(true))
+			// Linked after submit, which unlinks the lights it sets a colour on.
+			deviceMgr.LinkBackToFront(target, func() {
+				if err := client.SetTitle(ctx, title(false), streamdeck.HardwareAndSoftware); err != nil {
+					log.Printf("Unable to update the key after unlinking the back light: %v\n", err)
+				}
+			})
+			return err
+		},
+	)
+}
+
+// poweredOn reports whether the front or back lights of target are on, as far as
+// the plugin knows, so every key toggling them agrees.
+func poweredOn(which logitech.LightTarget, target string) bool {
+	state, _ := deviceMgr.KnownState(target, which)
+	return state.On
+}
+
+func onOffTitle(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
+
+// powerCommand turns the front or back lights of target on or off. The back
+// light comes back on in its last colour.
+func powerCommand(which logitech.LightTarget, target string, on bool) Command {
+	attribute := "front.power"
+	if which == logitech.BackLight {
+		attribute = "back.power"
+	}
+	return Command{Target: target, Attribute: attribute, Build: func(l *Light) ([][]byte, error) {
+		if !on {
+			offBytes, err := l.Model.LightsOff(which)
+			return [][]byte{offBytes}, err
+		}
+		onBytes, err := l.Model.LightsOn(which)
+		if err != nil || which != logitech.BackLight {
+			return [][]byte{onBytes}, err
+		}
+		// Re-apply each light's last color if available
+		return append([][]byte{onBytes}, l.backColors()...), nil
+	}}
+}
 
 // --- Front Power On/Off ---
 func setupFrontPowerAction(client *streamdeck.Client) {
-	action := client.Action("ca.michaelabon.logitech-litra-lights.front.power")
+	action := client.Action(frontPowerUUID)
+
+	action.RegisterHandler(
+		streamdeck.WillAppear,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			return showState(event, logitech.FrontLight, func(state LightState, ok bool) error {
+				if !ok {
+					return nil
+				}
+				return client.SetTitle(ctx, onOffTitle(state.On), streamdeck.HardwareAndSoftware)
+			})
+		},
+	)
 
 	action.RegisterHandler(
 		streamdeck.KeyDown,
 		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
-			isOn := frontOn[event.Context]
-			frontOn[event.Context] = !isOn
+			target := targetFromEvent(event)
+			isOn := poweredOn(logitech.FrontLight, target)
 
-			var byteSequence []byte
 			if !isOn {
 				log.Println("Front Power: ON")
-				byteSequence = logitech.ConvertLightsOnTarget(logitech.FrontLight)
 			} else {
 				log.Println("Front Power: OFF")
-				byteSequence = logitech.ConvertLightsOffTarget(logitech.FrontLight)
-			}
-
-			if err := deviceMgr.WriteCommands(byteSequence); err != nil {
-				log.Println("Error toggling front power:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
 			}
 
-			if !isOn {
-				return client.SetTitle(ctx, "ON", streamdeck.HardwareAndSoftware)
-			}
-			return client.SetTitle(ctx, "OFF", streamdeck.HardwareAndSoftware)
+			cmd := powerCommand(logitech.FrontLight, target, !isOn)
+			return submit(ctx, client, "toggling front power", cmd, onOffTitle(!isOn))
 		},
 	)
 }
 
 // --- Back Power On/Off ---
 func setupBackPowerAction(client *streamdeck.Client) {
-	action := client.Action("ca.michaelabon.logitech-litra-lights.back.power")
+	action := client.Action(backPowerUUID)
+
+	action.RegisterHandler(
+		streamdeck.WillAppear,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			return showState(event, logitech.BackLight, func(state LightState, ok bool) error {
+				if !ok {
+					return nil
+				}
+				return client.SetTitle(ctx, onOffTitle(state.On), streamdeck.HardwareAndSoftware)
+			})
+		},
+	)
 
 	action.RegisterHandler(
 		streamdeck.KeyDown,
 		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
-			isOn := backOn[event.Context]
-			backOn[event.Context] = !isOn
+			target := targetFromEvent(event)
+			isOn := poweredOn(logitech.BackLight, target)
 
 			if !isOn {
 				log.Println("Back Power: ON")
-				onBytes := logitech.ConvertLightsOnTarget(logitech.BackLight)
-				// Re-apply last color if available
-				if len(lastBackLightCmds) > 0 {
-					cmds := append([][]byte{onBytes}, lastBackLightCmds...)
-					if err := deviceMgr.WriteCommands(cmds...); err != nil {
-						log.Println("Error toggling back power:", err)
-						return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-					}
-				} else {
-					if err := deviceMgr.WriteCommands(onBytes); err != nil {
-						log.Println("Error toggling back power:", err)
-						return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-					}
-				}
 			} else {
 				log.Println("Back Power: OFF")
-				if err := deviceMgr.WriteCommands(logitech.ConvertLightsOffTarget(logitech.BackLight)); err != nil {
-					log.Println("Error toggling back power:", err)
-					return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-				}
 			}
 
-			if !isOn {
-				return client.SetTitle(ctx, "ON", streamdeck.HardwareAndSoftware)
-			}
-			return client.SetTitle(ctx, "OFF", streamdeck.HardwareAndSoftware)
+			cmd := powerCommand(logitech.BackLight, target, !isOn)
+			return submit(ctx, client, "toggling back power", cmd, onOffTitle(!isOn))
 		},
 	)
 }
 
 // --- Front Temperature Cycle ---
-func setupFrontTempCycleAction(client *streamdeck.Client) {
-	action := client.Action("ca.michaelabon.logitech-litra-lights.front.temperature")
-	cycleIndexes := make(map[string]int)
+func setupFrontTempCycleAction(client *streamdeck.Client, positions *cycles) {
+	action := client.Action(frontTemperatureUUID)
 
-	defaultTemps := []uint16{2700, 3200, 4000, 5000, 6500}
+	show := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		c, err := cycleFromEvent(event, defaultTemperatures, logitech.CheckTemperature)
+		if err != nil {
+			return showCycleError(ctx, client, err)
+		}
+		return showState(event, logitech.FrontLight, func(state LightState, ok bool) error {
+			placeCycle(positions, event, c, state.Temperature, ok)
+			if !ok {
+				return nil
+			}
+			return client.SetTitle(ctx, strconv.Itoa(int(state.Temperature))+"K", streamdeck.HardwareAndSoftware)
+		})
+	}
+	action.RegisterHandler(streamdeck.WillAppear, show)
+	action.RegisterHandler(streamdeck.DidReceiveSettings, show)
 
 	action.RegisterHandler(
 		streamdeck.KeyDown,
 		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
-			idx := cycleIndexes[event.Context]
-			temp := defaultTemps[idx%len(defaultTemps)]
-			cycleIndexes[event.Context] = (idx + 1) % len(defaultTemps)
-
-			log.Printf("Front Temp Cycle: %dK\n", temp)
-
-			onBytes := logitech.ConvertLightsOnTarget(logitech.FrontLight)
-			tempBytes, err := logitech.ConvertTemperatureTarget(logitech.FrontLight, temp)
+			c, err := cycleFromEvent(event, defaultTemperatures, logitech.CheckTemperature)
 			if err != nil {
-				log.Println("Error building temp command:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
+				return showCycleError(ctx, client, err)
 			}
+			temp := c.at(positions.advance(event.Context, c.len()))
 
-			if err := deviceMgr.WriteCommands(onBytes, tempBytes); err != nil {
-				log.Println("Error setting front temp:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-			}
+			log.Printf("Front Temp Cycle: %dK\n", temp)
 
-			return client.SetTitle(ctx, strconv.Itoa(int(temp))+"K", streamdeck.HardwareAndSoftware)
+			fade := Fade{Target: targetFromEvent(event), Attribute: "front.temperature", Fader: fadeAll(turnOn(logitech.FrontLight), fadeTemperature(temp))}
+			return submitFade(ctx, client, "setting front temp", fade, transitionFromEvent(event), strconv.Itoa(int(temp))+"K")
 		},
 	)
 }
 
+// CircadianSettings for the Follow the Sun action
+type CircadianSettings struct {
+	TargetSettings
+	Latitude  string `json:"latitude"`  // degrees, north positive
+	Longitude string `json:"longitude"` // degrees, east positive
+	TimeZone  string `json:"timezone"`  // IANA name, such as "America/Toronto"; this computer's when empty
+	Warm      uint16 `json:"warm,string"`
+	Cool      uint16 `json:"cool,string"`
+}
+
+// errNoLocation is returned for a Follow the Sun key without a valid place.
+var errNoLocation = errors.New("no latitude and longitude")
+
+func (s *CircadianSettings) schedule() (Circadian, error) {
+	c := Circadian{Warm: cmp.Or(s.Warm, 2700), Cool: cmp.Or(s.Cool, 6500), Location: time.Local}
+	lat, err := strconv.ParseFloat(s.Latitude, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return c, errNoLocation
+	}
+	lon, err := strconv.ParseFloat(s.Longitude, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return c, errNoLocation
+	}
+	c.Latitude, c.Longitude = lat, lon
+	if s.TimeZone != "" {
+		if c.Location, err = time.LoadLocation(s.TimeZone); err != nil {
+			return c, fmt.Errorf("time zone %q: %w", s.TimeZone, err)
+		}
+	}
+	return c, nil
+}
+
+// --- Front Follow the Sun ---
+func setupFrontCircadianAction(client *streamdeck.Client) {
+	action := client.Action(frontCircadianUUID)
+	settings := make(map[string]*CircadianSettings)
+
+	handler := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		p := streamdeck.WillAppearPayload{}
+		if err := json.Unmarshal(event.Payload, &p); err != nil {
+			return err
+		}
+
+		s, ok := settings[event.Context]
+		if !ok {
+			s = &CircadianSettings{}
+			settings[event.Context] = s
+		}
+		if err := json.Unmarshal(p.Settings, s); err != nil {
+			return err
+		}
+
+		on := deviceMgr.Following(s.Device)
+		if event.Event != streamdeck.KeyDown {
+			return client.SetTitle(ctx, "Sun\n"+onOffTitle(on), streamdeck.HardwareAndSoftware)
+		}
+
+		if on {
+			deviceMgr.StopFollowing(s.Device)
+			return nil
+		}
+
+		schedule, err := s.schedule()
+		if errors.Is(err, errNoLocation) {
+			return client.SetTitle(ctx, "Set\nLocation", streamdeck.HardwareAndSoftware)
+		}
+		if err != nil {
+			return showError(ctx, client, "following the sun", err)
+		}
+		log.Printf("Follow the Sun: %.2f, %.2f in %s, %dK to %dK on %q\n",
+			schedule.Latitude, schedule.Longitude, schedule.Location, schedule.Warm, schedule.Cool, s.Device)
+		update := func(title string) {
+			if err := client.SetTitle(ctx, title, streamdeck.HardwareAndSoftware); err != nil {
+				log.Printf("Unable to update the key while following the sun: %v\n", err)
+			}
+		}
+		deviceMgr.FollowSchedule(s.Device, schedule, func(kelvin uint16) {
+			update("Sun\n" + strconv.Itoa(int(kelvin)) + "K")
+		}, func(err error) {
+			if err != nil {
+				if err := showError(ctx, client, "following the sun", err); err != nil {
+					log.Printf("Unable to update the key after following the sun: %v\n", err)
+				}
+				return
+			}
+			update("Sun\n" + onOffTitle(false))
+		})
+		return client.SetTitle(ctx, "Sun\n"+onOffTitle(true), streamdeck.HardwareAndSoftware)
+	}
+
+	action.RegisterHandler(streamdeck.WillAppear, handler)
+	action.RegisterHandler(streamdeck.DidReceiveSettings, handler)
+	action.RegisterHandler(streamdeck.KeyDown, handler)
+}
+
 // --- Front Brightness Cycle ---
-func setupFrontBrightnessCycleAction(client *streamdeck.Client) {
-	action := client.Action("ca.michaelabon.logitech-litra-lights.front.brightness")
-	cycleIndexes := make(map[string]int)
+func setupFrontBrightnessCycleAction(client *streamdeck.Client, positions *cycles) {
+	action := client.Action(frontBrightnessUUID)
 
-	defaultBrightness := []uint8{20, 40, 60, 80, 100}
+	show := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		c, err := cycleFromEvent(event, defaultBrightnesses, logitech.CheckBrightness)
+		if err != nil {
+			return showCycleError(ctx, client, err)
+		}
+		return showState(event, logitech.FrontLight, func(state LightState, ok bool) error {
+			placeCycle(positions, event, c, state.Brightness, ok)
+			if !ok {
+				return nil
+			}
+			return client.SetTitle(ctx, strconv.Itoa(int(state.Brightness))+"%", streamdeck.HardwareAndSoftware)
+		})
+	}
+	action.RegisterHandler(streamdeck.WillAppear, show)
+	action.RegisterHandler(streamdeck.DidReceiveSettings, show)
 
 	action.RegisterHandler(
 		streamdeck.KeyDown,
 		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
-			idx := cycleIndexes[event.Context]
-			brightness := defaultBrightness[idx%len(defaultBrightness)]
-			cycleIndexes[event.Context] = (idx + 1) % len(defaultBrightness)
-
-			log.Printf("Front Brightness Cycle: %d%%\n", brightness)
-
-			brightBytes, err := logitech.ConvertBrightnessTarget(logitech.FrontLight, brightness)
+			c, err := cycleFromEvent(event, defaultBrightnesses, logitech.CheckBrightness)
 			if err != nil {
-				log.Println("Error building brightness command:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
+				return showCycleError(ctx, client, err)
 			}
+			brightness := c.at(positions.advance(event.Context, c.len()))
 
-			if err := deviceMgr.WriteCommands(brightBytes); err != nil {
-				log.Println("Error setting front brightness:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-			}
+			log.Printf("Front Brightness Cycle: %d%%\n", brightness)
 
-			return client.SetTitle(ctx, strconv.Itoa(int(brightness))+"%", streamdeck.HardwareAndSoftware)
+			fade := Fade{Target: targetFromEvent(event), Attribute: "front.brightness", Fader: fadeBrightness(logitech.FrontLight, brightness)}
+			return submitFade(ctx, client, "setting front brightness", fade, transitionFromEvent(event), strconv.Itoa(int(brightness))+"%")
 		},
 	)
 }
 
 // --- Back Brightness Cycle ---
-func setupBackBrightnessCycleAction(client *streamdeck.Client) {
-	action := client.Action("ca.michaelabon.logitech-litra-lights.back.brightness")
-	cycleIndexes := make(map[string]int)
+func setupBackBrightnessCycleAction(client *streamdeck.Client, positions *cycles) {
+	action := client.Action(backBrightnessUUID)
 
-	defaultBrightness := []uint8{20, 40, 60, 80, 100}
+	show := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		c, err := cycleFromEvent(event, defaultBrightnesses, logitech.CheckBrightness)
+		if err != nil {
+			return showCycleError(ctx, client, err)
+		}
+		return showState(event, logitech.BackLight, func(state LightState, ok bool) error {
+			placeCycle(positions, event, c, state.Brightness, ok)
+			if !ok {
+				return nil
+			}
+			return client.SetTitle(ctx, strconv.Itoa(int(state.Brightness))+"%", streamdeck.HardwareAndSoftware)
+		})
+	}
+	action.RegisterHandler(streamdeck.WillAppear, show)
+	action.RegisterHandler(streamdeck.DidReceiveSettings, show)
 
 	action.RegisterHandler(
 		streamdeck.KeyDown,
 		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
-			idx := cycleIndexes[event.Context]
-			brightness := defaultBrightness[idx%len(defaultBrightness)]
-			cycleIndexes[event.Context] = (idx + 1) % len(defaultBrightness)
-
-			log.Printf("Back Brightness Cycle: %d%%\n", brightness)
-
-			brightBytes, err := logitech.ConvertBrightnessTarget(logitech.BackLight, brightness)
+			c, err := cycleFromEvent(event, defaultBrightnesses, logitech.CheckBrightness)
 			if err != nil {
-				log.Println("Error building brightness command:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
+				return showCycleError(ctx, client, err)
 			}
+			brightness := c.at(positions.advance(event.Context, c.len()))
 
-			if err := deviceMgr.WriteCommands(brightBytes); err != nil {
-				log.Println("Error setting back brightness:", err)
-				return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-			}
+			log.Printf("Back Brightness Cycle: %d%%\n", brightness)
 
-			return client.SetTitle(ctx, strconv.Itoa(int(brightness))+"%", streamdeck.HardwareAndSoftware)
+			fade := Fade{Target: targetFromEvent(event), Attribute: "back.brightness", Fader: fadeBrightness(logitech.BackLight, brightness)}
+			return submitFade(ctx, client, "setting back brightness", fade, transitionFromEvent(event), strconv.Itoa(int(brightness))+"%")
 		},
 	)
 }
@@ -643,7 +1152,7 @@ func setupTurnOffLightsAction(client *streamdeck.Client) {
 }
 
 func setupSetLightsAction(client *streamdeck.Client, settings map[string]*Settings) {
-	setLightsAction := client.Action("ca.michaelabon.logitech-litra-lights.set")
+	setLightsAction := client.Action(setLightsUUID)
 
 	setLightsAction.RegisterHandler(
 		streamdeck.WillAppear,
@@ -746,11 +1255,7 @@ func setupSetLightsAction(client *streamdeck.Client, settings map[string]*Settin
 }
 
 func handleTurnOffLights(ctx context.Context, client *streamdeck.Client) error {
-	if err := turnOffAllLights(); err != nil {
-		log.Println("Error: ", err)
-		return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-	}
-	return nil
+	return submit(ctx, client, "turning off lights", turnOffCommand, "")
 }
 
 func handleSetLights(
@@ -776,35 +1281,53 @@ func handleSetLights(
 		return err
 	}
 
-	// Build commands: turn on, set brightness, set temperature
-	onBytes := logitech.ConvertLightsOn()
-	brightBytes, err := logitech.ConvertBrightness(s.Brightness)
-	if err != nil {
-		log.Println("Error building brightness:", err)
-		return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-	}
-	tempBytes, err := logitech.ConvertTemperature(s.Temperature)
-	if err != nil {
-		log.Println("Error building temperature:", err)
-		return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-	}
-
-	if err := deviceMgr.WriteCommands(onBytes, brightBytes, tempBytes); err != nil {
-		log.Println("Error: ", err)
-		return client.SetTitle(ctx, "Err", streamdeck.HardwareAndSoftware)
-	}
-
 	if err := client.SetImage(ctx, background, streamdeck.HardwareAndSoftware); err != nil {
 		log.Println("Error while setting the light background", err)
 		return err
 	}
 
-	return client.SetTitle(ctx, strconv.Itoa(int(s.Temperature)), streamdeck.HardwareAndSoftware)
+	// Turn on, then fade brightness and temperature. The settings can change
+	// before the writer gets to them, so they are copied here.
+	brightness, temperature := s.Brightness, s.Temperature
+	fade := Fade{Target: s.Device, Attribute: "front", Fader: fadeAll(
+		turnOn(logitech.FrontLight),
+		fadeBrightness(logitech.FrontLight, brightness),
+		fadeTemperature(temperature),
+	)}
+	return submitFade(ctx, client, "setting lights", fade, s.transition(), strconv.Itoa(int(temperature)))
+}
+
+// turnOffCommand turns off every light each attached device has.
+var turnOffCommand = Command{Target: AllLights, Attribute: "power", Build: func(l *Light) ([][]byte, error) {
+	var commands [][]byte
+	for _, target := range l.Model.Targets() {
+		off, err := l.Model.LightsOff(target)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, off)
+	}
+	return commands, nil
+}}
+
+// turnOffOnExit powers off every light when Stream Deck quits, unless the
+// startup policy leaves them untouched: then the plugin leaves them as they
+// are on the way out too.
+func turnOffOnExit(sf *stateFile) {
+	if sf.Startup().Mode == startupUntouched {
+		log.Println("Leaving the lights as they are")
+		return
+	}
+	log.Println("Turning off lights...")
+	if err := turnOffAllLights(); err != nil {
+		log.Printf("Unable to turn off the lights: %v\n", err)
+	}
 }
 
-// turnOffAllLights sends commands to turn off both front and back lights.
+// turnOffAllLights turns off every light right away, when the plugin exits.
 func turnOffAllLights() error {
-	frontOff := logitech.ConvertLightsOffTarget(logitech.FrontLight)
-	backOff := logitech.ConvertLightsOffTarget(logitech.BackLight)
-	return deviceMgr.WriteCommands(frontOff, backOff)
+	deviceMgr.StopEffects(AllLights)
+	deviceMgr.StopFollowing(AllLights)
+	deviceMgr.Lights() // rescan so lights plugged in since the last write are included
+	return deviceMgr.Apply(turnOffCommand.Target, turnOffCommand.Build)
 }
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// queueSize bounds the commands waiting for the writer.
+	queueSize = 32
+
+	// commandDeadline limits how long a submitted command may wait and retry
+	// when the caller's context sets no deadline of its own.
+	commandDeadline = 10 * time.Second
+)
+
+var (
+	// ErrSuperseded is the result of a command a newer one replaced before it was written.
+	ErrSuperseded = errors.New("superseded by a newer command")
+
+	// ErrQueueFull is the result of a command submitted while too many were waiting.
+	ErrQueueFull = errors.New("too many commands waiting")
+)
+
+// Command is a change to the lights selected by Target, built for each light
+// when it is written.
+type Command struct {
+	Target string
+
+	// Attribute names what the command changes, e.g. "front.brightness". A
+	// waiting command is superseded by a newer one with the same Target and
+	// Attribute. Commands without an Attribute are never superseded.
+	Attribute string
+
+	Build func(l *Light) ([][]byte, error)
+
+	fade *fadeRun // the fade this command is a step of, if any
+}
+
+type queuedCommand struct {
+	Command
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan error
+}
+
+// commandQueue holds the commands waiting for the writer goroutine, oldest first.
+type commandQueue struct {
+	mu      sync.Mutex
+	pending []*queuedCommand
+	wake    chan struct{}
+	start   sync.Once
+}
+
+// Submit queues cmd for the writer goroutine and returns at once. The
+// returned channel receives the command's result: nil once every light took
+// it, ErrSuperseded if a newer command replaced it, or the error that stopped
+// it. The command is dropped when ctx is done before it is written, and its
+// retries stop at ctx's deadline.
+func (dm *DeviceManager) Submit(ctx context.Context, cmd Command) <-chan error {
+	q := &dm.queue
+	q.start.Do(func() {
+		q.wake = make(chan struct{}, 1)
+		go dm.runQueue()
+	})
+
+	c := &queuedCommand{Command: cmd, done: make(chan error, 1)}
+	if _, ok := ctx.Deadline(); ok {
+		c.ctx, c.cancel = context.WithCancel(ctx)
+	} else {
+		c.ctx, c.cancel = context.WithTimeout(ctx, commandDeadline)
+	}
+
+	if cmd.Attribute != "" {
+		dm.cancelFade(cmd)
+	}
+
+	q.mu.Lock()
+	if cmd.Attribute != "" {
+		q.pending = slices.DeleteFunc(q.pending, func(old *queuedCommand) bool {
+			if old.Target != cmd.Target || old.Attribute != cmd.Attribute {
+				return false
+			}
+			old.finish(ErrSuperseded)
+			return true
+		})
+	}
+	if len(q.pending) >= queueSize {
+		q.mu.Unlock()
+		log.Printf("Dropping a %s command for %q: %v", cmd.Attribute, cmd.Target, ErrQueueFull)
+		c.finish(ErrQueueFull)
+		return c.done
+	}
+	q.pending = append(q.pending, c)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default: // the writer is already awake
+	}
+	return c.done
+}
+
+// runQueue writes the queued commands one at a time, for the life of the plugin.
+func (dm *DeviceManager) runQueue() {
+	q := &dm.queue
+	for range q.wake {
+		for {
+			q.mu.Lock()
+			if len(q.pending) == 0 {
+				q.mu.Unlock()
+				break
+			}
+			c := q.pending[0]
+			q.pending = q.pending[1:]
+			q.mu.Unlock()
+
+			if err := c.ctx.Err(); err != nil {
+				c.finish(err)
+				continue
+			}
+			c.finish(dm.ApplyContext(c.ctx, c.Target, c.Build))
+		}
+	}
+}
+
+func (c *queuedCommand) finish(err error) {
+	c.cancel()
+	c.done <- err
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+// writeCmd builds a Command writing cmd to every light.
+func writeCmd(attribute string, cmd []byte) Command {
+	return Command{Target: AllLights, Attribute: attribute, Build: func(*Light) ([][]byte, error) {
+		return [][]byte{cmd}, nil
+	}}
+}
+
+// holdWriter keeps the writer goroutine busy on a first command until the
+// returned function is called, so the next ones wait in the queue.
+func holdWriter(t *testing.T, dm *DeviceManager, first Command) (release func()) {
+	t.Helper()
+	dm.mu.Lock()
+	dm.Submit(context.Background(), first)
+	waitFor(t, func() bool {
+		dm.queue.mu.Lock()
+		defer dm.queue.mu.Unlock()
+		return len(dm.queue.pending) == 0
+	})
+	return dm.mu.Unlock
+}
+
+func result(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("The command never finished")
+		return nil
+	}
+}
+
+func TestSubmitSupersedesWaitingCommands(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.Lights()
+	m := logitech.LitraBeamLX
+	on := mustBytes(m.LightsOn(logitech.FrontLight))
+	dim := mustBytes(m.Brightness(logitech.FrontLight, 20))
+	bright := mustBytes(m.Brightness(logitech.FrontLight, 80))
+	back := mustBytes(m.Brightness(logitech.BackLight, 80))
+
+	release := holdWriter(t, dm, writeCmd("front.power", on))
+	superseded := dm.Submit(context.Background(), writeCmd("front.brightness", dim))
+	other := dm.Submit(context.Background(), writeCmd("back.brightness", back))
+	latest := dm.Submit(context.Background(), writeCmd("front.brightness", bright))
+	release()
+
+	if err := result(t, superseded); !errors.Is(err, ErrSuperseded) {
+		t.Errorf("Expected the first brightness to be superseded, got %v", err)
+	}
+	for _, done := range []<-chan error{other, latest} {
+		if err := result(t, done); err != nil {
+			t.Error(err)
+		}
+	}
+	assertReports(t, fake.Written(testPath), on, back, bright)
+}
+
+func TestSubmitRejectsCommandsWhenFull(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.Lights()
+	cmd := mustBytes(logitech.LitraBeamLX.LightsOn(logitech.FrontLight))
+
+	release := holdWriter(t, dm, writeCmd("", cmd))
+	var waiting []<-chan error
+	for range queueSize {
+		waiting = append(waiting, dm.Submit(context.Background(), writeCmd("", cmd)))
+	}
+	if err := result(t, dm.Submit(context.Background(), writeCmd("", cmd))); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected ErrQueueFull, got %v", err)
+	}
+	release()
+
+	for _, done := range waiting {
+		if err := result(t, done); err != nil {
+			t.Error(err)
+		}
+	}
+	if got := len(fake.Written(testPath)); got != queueSize+1 {
+		t.Errorf("Expected %d reports, got %d", queueSize+1, got)
+	}
+}
+
+func TestSubmitDropsCommandsWhoseContextEnded(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.Lights()
+	on := mustBytes(logitech.LitraBeamLX.LightsOn(logitech.FrontLight))
+	off := mustBytes(logitech.LitraBeamLX.LightsOff(logitech.FrontLight))
+
+	release := holdWriter(t, dm, writeCmd("", on))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := dm.Submit(ctx, writeCmd("", off))
+	cancel()
+	release()
+
+	if err := result(t, done); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	assertReports(t, fake.Written(testPath), on)
+}
+
+func TestSubmitStopsRetryingAtTheDeadline(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	dm.retryDelay = 20 * time.Millisecond
+	dm.Lights()
+	fake.FailWrites(maxRetries+1, errors.New("stalled"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := result(t, dm.Submit(ctx, writeCmd("", mustBytes(logitech.LitraBeamLX.LightsOn(logitech.FrontLight)))))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to stop the retries, got %v", err)
+	}
+	if fake.Opens() > 2 {
+		t.Errorf("Expected at most one retry, opened %d times", fake.Opens())
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/michaelabon/streamdeck-logitech-litra/internal/transport"
+)
+
+// readPoll is how long each read waits before checking whether the reader
+// should stop. Closing a light waits for at most this long.
+const readPoll = 100 * time.Millisecond
+
+// reader owns the reads from one open light. It hands each answer to the
+// query waiting for it and decodes the reports the light sends on its own.
+type reader struct {
+	id     string
+	device transport.Device
+	events chan<- lightEvent
+
+	mu      sync.Mutex
+	model   logitech.Model
+	pending *pendingQuery
+	err     error // set once reading failed
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+type pendingQuery struct {
+	request []byte
+	answer  chan queryResult
+}
+
+type queryResult struct {
+	r   logitech.Response
+	err error
+
+	// broken is set when the connection failed, rather than the light answering with an error.
+	broken bool
+}
+
+// lightEvent is a change one light reported on its own, or it being plugged
+// in or unplugged.
+type lightEvent struct {
+	id         string
+	event      logitech.Event
+	connection connectionChange
+}
+
+func startReader(l *Light, poll time.Duration, events chan<- lightEvent) *reader {
+	rd := &reader{
+		id:      l.ID,
+		device:  l.device,
+		events:  events,
+		model:   l.Model,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go rd.run(poll)
+	return rd
+}
+
+func (rd *reader) run(poll time.Duration) {
+	defer close(rd.stopped)
+
+	buf := make([]byte, 20)
+	for {
+		select {
+		case <-rd.stop:
+			return
+		default:
+		}
+
+		n, err := rd.device.ReadWithTimeout(buf, poll)
+		if errors.Is(err, transport.ErrTimeout) {
+			continue
+		}
+		if err != nil {
+			rd.fail(err)
+			return
+		}
+
+		r, err := logitech.ParseResponse(buf[:n])
+		r.Params = append([]byte(nil), r.Params...) // buf is reused
+		if rd.answer(r, err) || err != nil {
+			continue
+		}
+		rd.notify(r)
+	}
+}
+
+// answer hands r to the pending query if it answers it.
+func (rd *reader) answer(r logitech.Response, err error) bool {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	if rd.pending == nil || !r.Answers(rd.pending.request) {
+		return false
+	}
+	rd.pending.answer <- queryResult{r: r, err: err}
+	rd.pending = nil
+	return true
+}
+
+func (rd *reader) notify(r logitech.Response) {
+	rd.mu.Lock()
+	e, ok := rd.model.DecodeEvent(r)
+	rd.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	log.Printf("Light %s reported a %s change on its %s: %+v", rd.id, e.Kind, e.Target, e)
+	select {
+	case rd.events <- lightEvent{id: rd.id, event: e}:
+	default:
+		log.Printf("Dropped an event from %s: too many pending", rd.id)
+	}
+}
+
+// fail ends the pending query and any later one with err.
+func (rd *reader) fail(err error) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	rd.err = err
+	if rd.pending != nil {
+		rd.pending.answer <- queryResult{err: err, broken: true}
+		rd.pending = nil
+	}
+}
+
+// failed reports whether reading failed, e.g. because the light was unplugged.
+func (rd *reader) failed() bool {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	return rd.err != nil
+}
+
+// expect registers request as the pending query. Call it before writing the
+// request so a fast answer isn't missed.
+func (rd *reader) expect(request []byte) <-chan queryResult {
+	answer := make(chan queryResult, 1)
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	if rd.err != nil {
+		answer <- queryResult{err: rd.err, broken: true}
+		return answer
+	}
+	rd.pending = &pendingQuery{request: request, answer: answer}
+	return answer
+}
+
+// cancel forgets the pending query.
+func (rd *reader) cancel() {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	rd.pending = nil
+}
+
+// setModel updates the model used to decode events, once features are discovered.
+func (rd *reader) setModel(m logitech.Model) {
+	rd.mu.Lock()
+	defer rd.mu.Unlock()
+	rd.model = m
+}
+
+// close stops the reader and waits for it, so the device can be closed safely.
+func (rd *reader) close() {
+	close(rd.stop)
+	<-rd.stopped
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"image/color"
+	"log"
+	"slices"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+	sdcontext "github.com/samwho/streamdeck/context"
+)
+
+const sceneUUID = "ca.michaelabon.logitech-litra-lights.scene"
+
+// Scene is a whole look for a light: the state of its front and back light.
+// Settings a scene leaves out are left as they are.
+type Scene struct {
+	Name  string     `json:"name,omitempty"`
+	Front SceneLight `json:"front"`
+	Back  SceneLight `json:"back"`
+}
+
+// SceneLight is the state of a front or back light in a scene.
+type SceneLight struct {
+	Power       string   `json:"power,omitempty"`       // "on" or "off"
+	Brightness  uint8    `json:"brightness,omitempty"`  // percent
+	Temperature uint16   `json:"temperature,omitempty"` // kelvin, front light only
+	Colors      []string `json:"colors,omitempty"`      // hex colours, back light only: one for every zone, or one per zone
+
+	// Gradient blends the back light's zones instead of Colors.
+	Gradient      []PresetStop `json:"gradient,omitempty"`
+	Interpolation string       `json:"interpolation,omitempty"` // as in Preset
+}
+
+// light returns the scene's state of the front or back light.
+func (s Scene) light(which logitech.LightTarget) SceneLight {
+	if which == logitech.BackLight {
+		return s.Back
+	}
+	return s.Front
+}
+
+// commands builds what puts l into the scene. Lights the model doesn't have
+// are skipped. The back light's colours are remembered once the commands are
+// written.
+func (s Scene) commands(l *Light) ([][]byte, error) {
+	var commands [][]byte
+	for _, target := range l.Model.Targets() {
+		sl := s.light(target)
+		if sl.Brightness > 0 {
+			cmd, err := l.Model.Brightness(target, sl.Brightness)
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, cmd)
+		}
+		if sl.Temperature > 0 && target == logitech.FrontLight {
+			cmd, err := l.Model.Temperature(target, sl.Temperature)
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, cmd)
+		}
+		if sl.hasColors() && target == logitech.BackLight && l.Model.HasColor() {
+			colors, err := l.Model.Frame(target, sl.frame())
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, colors...)
+			l.rememberBackColors(colors)
+		}
+		// Power last, so the light doesn't flash at its old settings.
+		if sl.Power != "" {
+			cmd, err := l.Model.Restore(logitech.Event{Target: target, Kind: logitech.PowerChanged, On: sl.Power == "on"})
+			if err != nil {
+				return nil, err
+			}
+			commands = append(commands, cmd)
+		}
+	}
+	return commands, nil
+}
+
+// hasColors reports whether a back light scene sets the zones' colours.
+func (sl SceneLight) hasColors() bool {
+	return len(sl.Colors) > 0 || len(sl.Gradient) > 0
+}
+
+// frame returns the zone colours of a back light scene.
+func (sl SceneLight) frame() logitech.Frame {
+	if len(sl.Gradient) > 0 {
+		return Preset{Mode: "gradient", Stops: sl.Gradient, Interpolation: sl.Interpolation}.Frame()
+	}
+	colors := make([]color.RGBA, len(sl.Colors))
+	for i, hex := range sl.Colors {
+		colors[i] = hexToRGBA(hex)
+	}
+	return zoneFrame(colors)
+}
+
+// zoneFrame spreads zone colours over every zone. One colour fills them all;
+// zones past the last colour take the last one.
+func zoneFrame(colors []color.RGBA) logitech.Frame {
+	var frame logitech.Frame
+	if len(colors) == 0 {
+		return frame
+	}
+	for i := range frame {
+		frame[i] = colors[min(i, len(colors)-1)]
+	}
+	return frame
+}
+
+// sceneLight describes a light's known state as a scene.
+func sceneLight(which logitech.LightTarget, known map[setting]logitech.Event, colors []color.RGBA) SceneLight {
+	var sl SceneLight
+	if e, ok := known[setting{which, logitech.PowerChanged}]; ok {
+		sl.Power = "off"
+		if e.On {
+			sl.Power = "on"
+		}
+	}
+	sl.Brightness = known[setting{which, logitech.BrightnessChanged}].Brightness
+	if which == logitech.FrontLight {
+		sl.Temperature = known[setting{which, logitech.TemperatureChanged}].Temperature
+	}
+	if which == logitech.BackLight {
+		sl.Colors = hexColors(colors)
+	}
+	return sl
+}
+
+// hexColors writes zone colours as hex, or nil if no zone was ever set.
+func hexColors(colors []color.RGBA) []string {
+	if !slices.ContainsFunc(colors, func(c color.RGBA) bool { return c != color.RGBA{} }) {
+		return nil
+	}
+	hex := make([]string, len(colors))
+	for i, c := range colors {
+		hex[i] = rgbaToHex(c)
+	}
+	return hex
+}
+
+// events are the settings of a scene light, as the light would report them.
+func (sl SceneLight) events(which logitech.LightTarget) []logitech.Event {
+	var events []logitech.Event
+	if sl.Power != "" {
+		events = append(events, logitech.Event{Target: which, Kind: logitech.PowerChanged, On: sl.Power == "on"})
+	}
+	if sl.Brightness > 0 {
+		events = append(events, logitech.Event{Target: which, Kind: logitech.BrightnessChanged, Brightness: sl.Brightness})
+	}
+	if sl.Temperature > 0 && which == logitech.FrontLight {
+		events = append(events, logitech.Event{Target: which, Kind: logitech.TemperatureChanged, Temperature: sl.Temperature})
+	}
+	return events
+}
+
+// SceneSettings for the Apply Scene action
+type SceneSettings struct {
+	TargetSettings
+	Scene   *Scene `json:"scene"`
+	SceneID string `json:"sceneId,omitempty"` // a scene in the library, used instead of Scene
+}
+
+// defaultScene is what an Apply Scene key sets before it's given a scene.
+var defaultScene = Scene{Front: SceneLight{Power: "on", Brightness: 50, Temperature: 4000}}
+
+// scene returns the key's scene: the library's scene it refers to, or its
+// own. A scene deleted from the library leaves the key with its own.
+func (s SceneSettings) scene(lib Library) Scene {
+	if scene, ok := lib.Scenes[s.SceneID]; ok && s.SceneID != "" {
+		return scene
+	}
+	if s.Scene == nil {
+		return defaultScene
+	}
+	return *s.Scene
+}
+
+// sceneSettingsFromEvent reads the settings of an Apply Scene key from an
+// event carrying them.
+func sceneSettingsFromEvent(event streamdeck.Event) (SceneSettings, error) {
+	p := streamdeck.KeyDownPayload{}
+	s := SceneSettings{}
+	if err := json.Unmarshal(event.Payload, &p); err != nil {
+		return s, err
+	}
+	if len(p.Settings) > 0 {
+		if err := json.Unmarshal(p.Settings, &s); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// showScene draws a scene on its key.
+func showScene(ctx context.Context, client *streamdeck.Client, scene Scene) error {
+	background, err := streamdeck.Image(sceneImage(scene))
+	if err != nil {
+		log.Println("Error while generating streamdeck image", err)
+		return err
+	}
+	if err := client.SetImage(ctx, background, streamdeck.HardwareAndSoftware); err != nil {
+		return err
+	}
+	return client.SetTitle(ctx, scene.Name, streamdeck.HardwareAndSoftware)
+}
+
+// --- Apply Scene ---
+func setupSceneAction(client *streamdeck.Client, sf *stateFile) {
+	action := client.Action(sceneUUID)
+	settings := make(map[string]SceneSettings) // of the visible keys, by context
+
+	show := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		s, err := sceneSettingsFromEvent(event)
+		if err != nil {
+			return err
+		}
+		settings[event.Context] = s
+		return showScene(ctx, client, s.scene(sf.Library()))
+	}
+	action.RegisterHandler(streamdeck.WillAppear, show)
+	action.RegisterHandler(streamdeck.DidReceiveSettings, show)
+
+	action.RegisterHandler(
+		streamdeck.WillDisappear,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			delete(settings, event.Context)
+			return nil
+		},
+	)
+
+	// Keys showing a library scene redraw when it's edited.
+	sf.WatchLibrary(func(lib Library) {
+		for ctxStr, s := range settings {
+			if s.SceneID == "" {
+				continue
+			}
+			if err := showScene(sdcontext.WithContext(context.Background(), ctxStr), client, s.scene(lib)); err != nil {
+				log.Printf("Unable to redraw a scene key: %v\n", err)
+			}
+		}
+	})
+
+	action.RegisterHandler(
+		streamdeck.KeyDown,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			s, err := sceneSettingsFromEvent(event)
+			if err != nil {
+				return err
+			}
+			scene := s.scene(sf.Library())
+
+			log.Printf("Apply Scene: %+v\n", scene)
+
+			// Every setting of every light goes out in one batch, so the
+			// lights change together.
+			cmd := Command{Target: s.Device, Attribute: "scene", Build: scene.commands}
+			return submit(ctx, client, "applying scene", cmd, "")
+		},
+	)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+func TestApplySceneAction(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	m := logitech.LitraBeamLX
+	ctx := t.Name()
+	settings := SceneSettings{Scene: &Scene{
+		Name:  "Call",
+		Front: SceneLight{Power: "on", Brightness: 80, Temperature: 5000},
+		Back: SceneLight{Power: "on", Brightness: 30, Gradient: []PresetStop{
+			{Position: 0, Color: "#0000ff"},
+			{Position: 1, Color: "#ff0000"},
+		}},
+	}}
+
+	d.send(sceneUUID, streamdeck.WillAppear, ctx, settings)
+	d.expect(streamdeck.SetImage, ctx)
+	if title := d.expectTitle(ctx); title != "Call" {
+		t.Errorf("Expected the scene's name, got %q", title)
+	}
+
+	d.send(sceneUUID, streamdeck.KeyDown, ctx, settings)
+	gradient := logitech.Gradient{Stops: []logitech.Stop{
+		{Position: 0, Color: color.RGBA{B: 255, A: 255}},
+		{Position: 1, Color: color.RGBA{R: 255, A: 255}},
+	}}
+	want := [][]byte{
+		mustBytes(m.Brightness(logitech.FrontLight, 80)),
+		mustBytes(m.Temperature(logitech.FrontLight, 5000)),
+		mustBytes(m.LightsOn(logitech.FrontLight)),
+		mustBytes(m.Brightness(logitech.BackLight, 30)),
+	}
+	want = append(want, mustAll(m.Frame(logitech.BackLight, gradient.Frame()))...)
+	want = append(want, mustBytes(m.LightsOn(logitech.BackLight)))
+	assertReports(t, d.waitReports(len(want)), want...)
+}
+
+func TestSceneRemembersColoursOnceWritten(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	scene := Scene{Back: SceneLight{Power: "on", Colors: []string{"#ff0000"}}}
+	if err := dm.WriteTo(AllLights); err != nil {
+		t.Fatal(err)
+	}
+
+	fake.FailWrites(maxRetries+1, errors.New("unplugged"))
+	if err := dm.Apply(AllLights, scene.commands); err == nil {
+		t.Fatal("Expected the write to fail")
+	}
+	if _, colors := dm.State.BackColors(testLight.SerialNbr); colors != nil {
+		t.Errorf("Expected no colours remembered from a failed write, got %v", colors)
+	}
+
+	if err := dm.Apply(AllLights, scene.commands); err != nil {
+		t.Fatal(err)
+	}
+	if _, colors := dm.State.BackColors(testLight.SerialNbr); len(colors) == 0 || colors[0].R != 255 {
+		t.Errorf("Expected the scene's red remembered, got %v", colors)
+	}
+}
+
+func TestSceneImage(t *testing.T) {
+	img := sceneImage(Scene{
+		Front: SceneLight{Power: "off"},
+		Back:  SceneLight{Power: "on", Brightness: 100, Colors: []string{"#ff0000"}},
+	})
+	if c := img.At(36, 10); c != (color.RGBA{A: opaque}) {
+		t.Errorf("Expected the front light dark, got %v", c)
+	}
+	if c := img.At(36, 70); c != (color.RGBA{R: 255, A: opaque}) {
+		t.Errorf("Expected the back light red, got %v", c)
+	}
+}
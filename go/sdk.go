@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/samwho/streamdeck"
+)
+
+// Stream Deck+ events, which the streamdeck package predates.
+const (
+	DialRotate  = "dialRotate"
+	DialDown    = "dialDown"
+	DialUp      = "dialUp"
+	TouchTap    = "touchTap"
+	SetFeedback = "setFeedback"
+)
+
+// DialRotatePayload is sent when a dial is turned. Ticks is negative turning
+// left; Pressed is set while the dial is held down.
+type DialRotatePayload struct {
+	Settings    json.RawMessage        `json:"settings,omitempty"`
+	Coordinates streamdeck.Coordinates `json:"coordinates,omitempty"`
+	Ticks       int                    `json:"ticks"`
+	Pressed     bool                   `json:"pressed"`
+}
+
+// DialPayload is sent when a dial is pressed or released.
+type DialPayload struct {
+	Settings    json.RawMessage        `json:"settings,omitempty"`
+	Coordinates streamdeck.Coordinates `json:"coordinates,omitempty"`
+}
+
+// TouchTapPayload is sent when the touch strip above a dial is tapped. Hold
+// is set for a long touch.
+type TouchTapPayload struct {
+	Settings    json.RawMessage        `json:"settings,omitempty"`
+	Coordinates streamdeck.Coordinates `json:"coordinates,omitempty"`
+	TapPos      [2]int                 `json:"tapPos"`
+	Hold        bool                   `json:"hold"`
+}
+
+// setFeedback updates the touch strip layout of the dial behind ctx. Keys of
+// payload name items of the layout.
+func setFeedback(ctx context.Context, client *streamdeck.Client, payload any) error {
+	return client.Send(streamdeck.NewEvent(ctx, SetFeedback, payload))
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"image/color"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+// StateStore is the plugin's one record of each light's state, by light ID:
+// what the plugin set and what the light reported. Keys read it rather than
+// keeping their own copies, and hear of every change through Subscribe,
+// whichever key or light made it. It is kept for unplugged lights too, so
+// their state can be replayed. It is safe for concurrent use; a
+// DeviceManager's mu, when held, is taken before this one's.
+type StateStore struct {
+	mu     sync.Mutex
+	lights map[string]*storedLight
+
+	changes     chan StateChange
+	subMu       sync.Mutex
+	subscribers []func(StateChange)
+	dispatch    sync.Once
+}
+
+// storedLight is what is known of one light.
+type storedLight struct {
+	known map[setting]logitech.Event
+
+	// backCommands are the last colour commands sent to the back light,
+	// re-sent when it is turned back on; colors are the zones they set.
+	backCommands [][]byte
+	colors       []color.RGBA
+}
+
+// StateChange is the state of one light's front or back light after one of
+// its settings changed.
+type StateChange struct {
+	ID    string
+	Which logitech.LightTarget
+	Kind  logitech.EventKind // the setting that changed; zero for the back light's colours
+	State LightState
+}
+
+func NewStateStore() *StateStore {
+	return &StateStore{
+		lights:  make(map[string]*storedLight),
+		changes: make(chan StateChange, 64),
+	}
+}
+
+// light returns the record of light id, creating it. Must be called with mu held.
+func (s *StateStore) light(id string) *storedLight {
+	l, ok := s.lights[id]
+	if !ok {
+		l = &storedLight{known: make(map[setting]logitech.Event)}
+		s.lights[id] = l
+	}
+	return l
+}
+
+// Remember records e as the current state of light id, telling subscribers
+// if it changed. Learning a setting for the first time isn't a change.
+func (s *StateStore) Remember(id string, e logitech.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.light(id)
+	key := setting{e.Target, e.Kind}
+	old, known := l.known[key]
+	l.known[key] = e
+	if known && old != e {
+		s.publish(StateChange{ID: id, Which: e.Target, Kind: e.Kind, State: l.state(e.Target)})
+	}
+}
+
+// Recall returns the last known value of one setting of light id.
+func (s *StateStore) Recall(id string, target logitech.LightTarget, kind logitech.EventKind) (logitech.Event, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.lights[id]
+	if !ok {
+		return logitech.Event{}, false
+	}
+	e, ok := l.known[setting{target, kind}]
+	return e, ok
+}
+
+// RememberBackColors records the colour commands last sent to the back light
+// of light id, and the zone colours they set.
+func (s *StateStore) RememberBackColors(id string, commands [][]byte, colors []color.RGBA) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.light(id)
+	changed := l.colors != nil && !slices.Equal(l.colors, colors)
+	l.backCommands, l.colors = commands, colors
+	if changed {
+		s.publish(StateChange{ID: id, Which: logitech.BackLight, State: l.state(logitech.BackLight)})
+	}
+}
+
+// BackColors returns the colour commands last sent to the back light of light
+// id and the zone colours they set. Colours loaded by Load come without
+// commands.
+func (s *StateStore) BackColors(id string) ([][]byte, []color.RGBA) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.lights[id]; ok {
+		return l.backCommands, slices.Clone(l.colors)
+	}
+	return nil, nil
+}
+
+// State returns what is known of the front or back light of light id. It
+// reports false when nothing is.
+func (s *StateStore) State(id string, which logitech.LightTarget) (LightState, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.lights[id]
+	if !ok {
+		return LightState{}, false
+	}
+	_, powered := l.known[setting{which, logitech.PowerChanged}]
+	_, bright := l.known[setting{which, logitech.BrightnessChanged}]
+	if !powered && !bright {
+		return LightState{}, false
+	}
+	return l.state(which), true
+}
+
+// state is State for callers holding mu.
+func (l *storedLight) state(which logitech.LightTarget) LightState {
+	state := LightState{
+		On:         l.known[setting{which, logitech.PowerChanged}].On,
+		Brightness: l.known[setting{which, logitech.BrightnessChanged}].Brightness,
+	}
+	if which == logitech.FrontLight {
+		state.Temperature = l.known[setting{which, logitech.TemperatureChanged}].Temperature
+	} else {
+		state.Colors = slices.Clone(l.colors)
+	}
+	return state
+}
+
+// Snapshot describes the known state of every light as a scene, by light ID,
+// to be saved and given back to Load.
+func (s *StateStore) Snapshot() map[string]Scene {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scenes := make(map[string]Scene, len(s.lights))
+	for id, l := range s.lights {
+		scenes[id] = Scene{
+			Front: sceneLight(logitech.FrontLight, l.known, nil),
+			Back:  sceneLight(logitech.BackLight, l.known, l.colors),
+		}
+	}
+	return scenes
+}
+
+// Load takes the state of lights from an earlier Snapshot, as if it had been
+// learned from them. Lights already known are left as they are.
+func (s *StateStore) Load(scenes map[string]Scene) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, scene := range scenes {
+		if _, ok := s.lights[id]; ok {
+			continue
+		}
+		l := s.light(id)
+		for _, which := range []logitech.LightTarget{logitech.FrontLight, logitech.BackLight} {
+			for _, e := range scene.light(which).events(which) {
+				l.known[setting{e.Target, e.Kind}] = e
+			}
+		}
+		for _, hex := range scene.Back.Colors {
+			l.colors = append(l.colors, hexToRGBA(hex))
+		}
+	}
+}
+
+// Subscribe calls fn after every change to a light's state. Calls happen one
+// at a time, in the order of the changes, on a goroutine of their own.
+func (s *StateStore) Subscribe(fn func(StateChange)) {
+	s.subMu.Lock()
+	s.subscribers = append(s.subscribers, fn)
+	s.subMu.Unlock()
+	s.dispatch.Do(func() {
+		go func() {
+			for change := range s.changes {
+				s.subMu.Lock()
+				subscribers := slices.Clone(s.subscribers)
+				s.subMu.Unlock()
+				for _, fn := range subscribers {
+					fn(change)
+				}
+			}
+		}()
+	})
+}
+
+// publish queues a change for the subscribers without waiting for them, since
+// it's called while a light is being written to. Must be called with mu held,
+// so changes are queued in order.
+func (s *StateStore) publish(change StateChange) {
+	s.subMu.Lock()
+	listening := len(s.subscribers) > 0
+	s.subMu.Unlock()
+	if !listening {
+		return
+	}
+	select {
+	case s.changes <- change:
+	default:
+		log.Printf("Dropped a state change of %s: too many pending", change.ID)
+	}
+}
+
+// cycles are the positions of the keys cycling through fixed presets, by key
+// context. Every cycling action shares it, so it has its own lock.
+type cycles struct {
+	mu        sync.Mutex
+	positions map[string]int
+
+	// saved are positions from before the plugin restarted, taken up by
+	// resume when their key appears.
+	saved map[string]int
+}
+
+func newCycles() *cycles {
+	return &cycles{positions: make(map[string]int), saved: make(map[string]int)}
+}
+
+// resume moves the key ctx to the position it had before the plugin
+// restarted, reporting false if there's none. Each position is resumed once,
+// so later appearances follow the light again.
+func (c *cycles) resume(ctx string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, ok := c.saved[ctx]
+	if ok {
+		c.positions[ctx] = i
+		delete(c.saved, ctx)
+	}
+	return ok
+}
+
+// snapshot returns every key's position, including saved ones not yet resumed.
+func (c *cycles) snapshot() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	positions := maps.Clone(c.saved)
+	maps.Copy(positions, c.positions)
+	return positions
+}
+
+// load saves positions from an earlier snapshot for resume.
+func (c *cycles) load(positions map[string]int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	maps.Copy(c.saved, positions)
+}
+
+// set moves the key ctx to position i.
+func (c *cycles) set(ctx string, i int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.positions[ctx] = i
+}
+
+// advance returns the position of the key ctx in a cycle of n presets and
+// moves it on to the next.
+func (c *cycles) advance(ctx string, n int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.positions[ctx] % n
+	c.positions[ctx] = (i + 1) % n
+	return i
+}
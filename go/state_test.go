@@ -0,0 +1,94 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+func TestStateStorePublishesChanges(t *testing.T) {
+	s := NewStateStore()
+	changes := make(chan StateChange, 10)
+	s.Subscribe(func(c StateChange) { changes <- c })
+	expectNone := func() {
+		t.Helper()
+		select {
+		case c := <-changes:
+			t.Errorf("Expected no change, got %+v", c)
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+
+	// Learning the state isn't a change.
+	s.Remember("a", logitech.Event{Target: logitech.FrontLight, Kind: logitech.PowerChanged, On: true})
+	s.Remember("a", logitech.Event{Target: logitech.FrontLight, Kind: logitech.BrightnessChanged, Brightness: 50})
+	expectNone()
+
+	s.Remember("a", logitech.Event{Target: logitech.FrontLight, Kind: logitech.BrightnessChanged, Brightness: 70})
+	want := StateChange{ID: "a", Which: logitech.FrontLight, Kind: logitech.BrightnessChanged, State: LightState{On: true, Brightness: 70}}
+	if c := <-changes; c.ID != want.ID || c.Which != want.Which || c.Kind != want.Kind || c.State.On != want.State.On || c.State.Brightness != want.State.Brightness {
+		t.Errorf("Expected %+v, got %+v", want, c)
+	}
+
+	// Setting the same value again isn't either.
+	s.Remember("a", logitech.Event{Target: logitech.FrontLight, Kind: logitech.BrightnessChanged, Brightness: 70})
+	expectNone()
+
+	red := []color.RGBA{{R: 255, A: 255}}
+	blue := []color.RGBA{{B: 255, A: 255}}
+	s.RememberBackColors("a", [][]byte{{1}}, red)
+	expectNone()
+	s.RememberBackColors("a", [][]byte{{2}}, blue)
+	if c := <-changes; c.Which != logitech.BackLight || len(c.State.Colors) != 1 || c.State.Colors[0] != blue[0] {
+		t.Errorf("Expected the back light blue, got %+v", c)
+	}
+
+	if state, ok := s.State("a", logitech.FrontLight); !ok || !state.On || state.Brightness != 70 {
+		t.Errorf("Expected the front light on at 70%%, got %+v, %v", state, ok)
+	}
+	if _, ok := s.State("b", logitech.FrontLight); ok {
+		t.Error("Expected nothing known of an unseen light")
+	}
+}
+
+func TestPowerKeysAgree(t *testing.T) {
+	d := newTestDeck(t, testLight)
+
+	for _, ctx := range []string{"first", "second"} {
+		d.send(frontPowerUUID, streamdeck.WillAppear, ctx, nil)
+		if title := d.expectTitle(ctx); title != "ON" {
+			t.Fatalf("%s: expected ON, got %q", ctx, title)
+		}
+	}
+
+	// Turning the light off with one key updates the other.
+	d.send(frontPowerUUID, streamdeck.KeyDown, "first", nil)
+	d.waitTitles(map[string]string{"first": "OFF", "second": "OFF"})
+
+	// So the other key turns it back on, rather than off again.
+	d.send(frontPowerUUID, streamdeck.KeyDown, "second", nil)
+	d.waitTitle("second", "ON")
+	written := d.waitReports(2)
+	assertReports(t, written[len(written)-1:], mustBytes(logitech.LitraBeamLX.LightsOn(logitech.FrontLight)))
+}
+
+func TestCycles(t *testing.T) {
+	c := newCycles()
+	for _, want := range []int{0, 1, 2, 0} {
+		if got := c.advance("key", 3); got != want {
+			t.Errorf("Expected position %d, got %d", want, got)
+		}
+	}
+	c.set("key", 2)
+	if got := c.advance("key", 3); got != 2 {
+		t.Errorf("Expected the position set, got %d", got)
+	}
+	// A position past the end of a shorter cycle wraps around.
+	c.set("key", 5)
+	if got := c.advance("key", 3); got != 2 {
+		t.Errorf("Expected position 2 of 3, got %d", got)
+	}
+}
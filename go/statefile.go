@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+// saveInterval is how often the state file is brought up to date.
+const saveInterval = 2 * time.Second
+
+// What the plugin does with the lights when it starts.
+const (
+	startupRestore   = "restore"   // put every light back into its last known state
+	startupUntouched = "untouched" // leave the lights as they are
+	startupScene     = "scene"     // apply a scene from the library to every light
+)
+
+// StartupPolicy is what the plugin does with the lights when it starts.
+type StartupPolicy struct {
+	Mode  string `json:"mode"`
+	Scene string `json:"scene,omitempty"` // the library ID of the scene, for startupScene
+}
+
+// savedState is the content of the state file.
+type savedState struct {
+	Startup StartupPolicy       `json:"startup"`
+	Lights  map[string]Scene    `json:"lights,omitempty"` // last known state, by light ID
+	Cycles  map[string]int      `json:"cycles,omitempty"` // cycle positions, by key context
+	Library Library             `json:"library"`          // the Property Inspector's last copy
+	Groups  map[string][]string `json:"groups,omitempty"` // the Property Inspector's last copy of the light groups
+}
+
+// stateFile keeps the lights' last known state and the keys' cycle positions
+// across restarts of the plugin, with the startup policy, the library and the
+// light groups, which keys need before any Property Inspector forwards them. An empty path
+// keeps everything in memory.
+type stateFile struct {
+	path   string
+	cycles *cycles
+
+	mu      sync.Mutex
+	saved   savedState
+	written []byte // the content last written, to skip writes that change nothing
+	closed  bool
+
+	libraryWatchers []func(Library)
+}
+
+// stateFilePath is where the state file goes: the user's config directory,
+// or the plugin directory when there is none.
+func stateFilePath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "state.json"
+	}
+	return filepath.Join(dir, "ca.michaelabon.logitech-litra-lights", "state.json")
+}
+
+// openStateFile reads the state file at path. A missing or unreadable file
+// starts afresh, restoring the lights.
+func openStateFile(path string) *stateFile {
+	sf := &stateFile{
+		path:   path,
+		cycles: newCycles(),
+		saved:  savedState{Startup: StartupPolicy{Mode: startupRestore}},
+	}
+	if path == "" {
+		return sf
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("Unable to read the state file: %v\n", err)
+		}
+		return sf
+	}
+	if err := json.Unmarshal(data, &sf.saved); err != nil {
+		log.Printf("Unable to read the state file %s: %v\n", path, err)
+		sf.saved = savedState{Startup: StartupPolicy{Mode: startupRestore}}
+		return sf
+	}
+	sf.written = data
+	return sf
+}
+
+// Startup returns the startup policy.
+func (sf *stateFile) Startup() StartupPolicy {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.saved.Startup
+}
+
+// SetStartup changes the startup policy.
+func (sf *stateFile) SetStartup(policy StartupPolicy) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.saved.Startup = policy
+}
+
+// Library returns the library of presets and scenes.
+func (sf *stateFile) Library() Library {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.saved.Library
+}
+
+// SetLibrary replaces the library and tells the watchers.
+func (sf *stateFile) SetLibrary(lib Library) {
+	sf.mu.Lock()
+	sf.saved.Library = lib
+	watchers := slices.Clone(sf.libraryWatchers)
+	sf.mu.Unlock()
+	for _, fn := range watchers {
+		fn(lib)
+	}
+}
+
+// WatchLibrary calls fn, on the caller of SetLibrary, whenever the library
+// changes, so keys showing its entries can redraw.
+func (sf *stateFile) WatchLibrary(fn func(Library)) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.libraryWatchers = append(sf.libraryWatchers, fn)
+}
+
+// SetGroups replaces the light groups kept for the next start.
+func (sf *stateFile) SetGroups(groups map[string][]string) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.saved.Groups = maps.Clone(groups)
+}
+
+// Apply gives dm the saved light groups, takes up the keys' cycle positions
+// and carries out the startup policy on dm's lights. It must be called before
+// dm first scans for lights.
+func (sf *stateFile) Apply(dm *DeviceManager) {
+	sf.mu.Lock()
+	saved := sf.saved
+	sf.mu.Unlock()
+
+	// The policy only decides what is sent to the lights: keys pick up where
+	// they left off whatever it is.
+	dm.SetGroups(saved.Groups)
+	sf.cycles.load(saved.Cycles)
+
+	switch saved.Startup.Mode {
+	case startupRestore:
+		log.Printf("Restoring the state of %d lights\n", len(saved.Lights))
+		dm.State.Load(saved.Lights)
+		dm.Restore(slices.Collect(maps.Keys(saved.Lights))...)
+		return
+	}
+
+	// The back light can't report its colours, so keep them for when it's
+	// turned back on.
+	colors := make(map[string]Scene, len(saved.Lights))
+	for id, scene := range saved.Lights {
+		colors[id] = Scene{Back: SceneLight{Colors: scene.Back.Colors}}
+	}
+	dm.State.Load(colors)
+
+	if saved.Startup.Mode != startupScene {
+		return
+	}
+	scene, ok := saved.Library.Scenes[saved.Startup.Scene]
+	if !ok {
+		log.Printf("No scene %q in the library to apply at startup\n", saved.Startup.Scene)
+		return
+	}
+	log.Printf("Applying the scene %q\n", scene.Name)
+	done := dm.Submit(context.Background(), Command{Target: AllLights, Attribute: "scene", Build: scene.commands})
+	go func() {
+		if err := <-done; err != nil {
+			log.Printf("Unable to apply the scene %q: %v\n", scene.Name, err)
+		}
+	}()
+}
+
+// Save writes the current state of dm's lights and the cycle positions to the
+// file, if they changed since it was last written.
+func (sf *stateFile) Save(dm *DeviceManager) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.closed {
+		return nil
+	}
+	return sf.save(dm)
+}
+
+// save is Save for callers holding mu.
+func (sf *stateFile) save(dm *DeviceManager) error {
+	sf.saved.Lights = dm.State.Snapshot()
+	sf.saved.Cycles = sf.cycles.snapshot()
+	if sf.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(sf.saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, sf.written) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(sf.path), 0o755); err != nil {
+		return err
+	}
+	// Write a copy and rename it over the file, so a crash never leaves half a file.
+	tmp := sf.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, sf.path); err != nil {
+		return err
+	}
+	sf.written = data
+	return nil
+}
+
+// Persist saves the state every saveInterval until ctx is done.
+func (sf *stateFile) Persist(ctx context.Context, dm *DeviceManager) {
+	go func() {
+		ticker := time.NewTicker(saveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := sf.Save(dm); err != nil {
+					log.Printf("Unable to save the state file: %v\n", err)
+				}
+			}
+		}
+	}()
+}
+
+// Close saves the state one last time, before the plugin turns the lights
+// off as it exits, and stops any later saves.
+func (sf *stateFile) Close(dm *DeviceManager) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if sf.closed {
+		return nil
+	}
+	err := sf.save(dm)
+	sf.closed = true
+	return err
+}
+
+// currentScene describes the known state of the lights target selects as a
+// scene named name.
+func currentScene(dm *DeviceManager, target, name string) Scene {
+	scene := Scene{Name: name}
+	for _, which := range []logitech.LightTarget{logitech.FrontLight, logitech.BackLight} {
+		state, ok := dm.KnownState(target, which)
+		if !ok {
+			continue
+		}
+		sl := SceneLight{Power: "off", Brightness: state.Brightness, Temperature: state.Temperature, Colors: hexColors(state.Colors)}
+		if state.On {
+			sl.Power = "on"
+		}
+		if which == logitech.BackLight {
+			scene.Back = sl
+		} else {
+			scene.Front = sl
+		}
+	}
+	return scene
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+)
+
+func TestStateFileRestoresLightsAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	m := logitech.LitraBeamLX
+	red := color.RGBA{R: 255, A: 255}
+
+	dm, _ := newTestManager(testLight)
+	err := dm.Apply(AllLights, func(l *Light) ([][]byte, error) {
+		colors := mustAll(m.Color(logitech.BackLight, 255, 0, 0))
+		l.rememberBackColors(colors)
+		return append([][]byte{
+			mustBytes(m.Brightness(logitech.FrontLight, 70)),
+			mustBytes(m.LightsOn(logitech.BackLight)),
+		}, colors...), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf := openStateFile(path)
+	sf.cycles.set("key", 2)
+	sf.SetGroups(map[string][]string{"desk": {testLight.SerialNbr}})
+	if err := sf.Close(dm); err != nil {
+		t.Fatal(err)
+	}
+
+	// The plugin starts again, with the light in its default state.
+	restarted, fake := newTestManager(testLight)
+	sf = openStateFile(path)
+	sf.Apply(restarted)
+	restarted.Lights()
+
+	want := [][]byte{
+		mustBytes(m.Brightness(logitech.FrontLight, 70)),
+		mustBytes(m.LightsOn(logitech.BackLight)),
+	}
+	want = append(want, mustAll(m.Frame(logitech.BackLight, logitech.Fill(red)))...)
+	assertReports(t, fake.Written(testPath), want...)
+
+	if !sf.cycles.resume("key") {
+		t.Fatal("Expected the key's cycle position back")
+	}
+	if got := sf.cycles.advance("key", 5); got != 2 {
+		t.Errorf("Expected position 2, got %d", got)
+	}
+	if sf.cycles.resume("key") {
+		t.Error("Expected the position to be resumed only once")
+	}
+
+	// Group keys work before any Property Inspector forwards the groups.
+	if err := restarted.WriteTo("group:desk", mustBytes(m.LightsOff(logitech.FrontLight))); err != nil {
+		t.Errorf("Expected the saved group back: %v", err)
+	}
+}
+
+func TestStartupScene(t *testing.T) {
+	m := logitech.LitraBeamLX
+	sf := openStateFile("")
+	sf.SetLibrary(Library{Scenes: map[string]Scene{"evening": {
+		Name:  "Evening",
+		Front: SceneLight{Power: "on", Brightness: 30, Temperature: 2700},
+		Back:  SceneLight{Power: "off"},
+	}}})
+	sf.SetStartup(StartupPolicy{Mode: startupScene, Scene: "evening"})
+
+	dm, fake := newTestManager(testLight)
+	sf.Apply(dm)
+
+	waitFor(t, func() bool { return len(fake.Written(testPath)) == 4 })
+	assertReports(t, fake.Written(testPath),
+		mustBytes(m.Brightness(logitech.FrontLight, 30)),
+		mustBytes(m.Temperature(logitech.FrontLight, 2700)),
+		mustBytes(m.LightsOn(logitech.FrontLight)),
+		mustBytes(m.LightsOff(logitech.BackLight)),
+	)
+}
+
+func TestStartupUntouchedKeepsOnlyColors(t *testing.T) {
+	sf := openStateFile("")
+	sf.saved.Lights = map[string]Scene{testLight.SerialNbr: {
+		Front: SceneLight{Power: "on", Brightness: 70},
+		Back:  SceneLight{Power: "on", Colors: []string{"#ff0000"}},
+	}}
+	sf.saved.Cycles = map[string]int{"key": 2}
+	sf.SetStartup(StartupPolicy{Mode: startupUntouched})
+
+	dm, fake := newTestManager(testLight)
+	sf.Apply(dm)
+	dm.Lights()
+	if written := fake.Written(testPath); len(written) != 0 {
+		t.Errorf("Expected the light left alone, got %d reports", len(written))
+	}
+	if !sf.cycles.resume("key") {
+		t.Error("Expected the key's cycle position back")
+	}
+
+	if _, ok := dm.State.Recall(testLight.SerialNbr, logitech.FrontLight, logitech.BrightnessChanged); ok {
+		t.Error("Expected the saved brightness to be ignored")
+	}
+	if _, colors := dm.State.BackColors(testLight.SerialNbr); len(colors) != 1 || colors[0] != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("Expected the saved colour kept, got %v", colors)
+	}
+}
+
+func TestLightsTurnOffOnExitUnlessUntouched(t *testing.T) {
+	dm, fake := newTestManager(testLight)
+	prev := deviceMgr
+	deviceMgr = dm
+	t.Cleanup(func() { deviceMgr = prev })
+
+	sf := openStateFile("")
+	sf.SetStartup(StartupPolicy{Mode: startupUntouched})
+	turnOffOnExit(sf)
+	if written := fake.Written(testPath); len(written) != 0 {
+		t.Errorf("Expected the light left alone, got %d reports", len(written))
+	}
+
+	sf.SetStartup(StartupPolicy{Mode: startupRestore})
+	turnOffOnExit(sf)
+	assertReports(t, fake.Written(testPath),
+		mustBytes(logitech.LitraBeamLX.LightsOff(logitech.FrontLight)),
+		mustBytes(logitech.LitraBeamLX.LightsOff(logitech.BackLight)),
+	)
+}
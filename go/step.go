@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/samwho/streamdeck"
+)
+
+const (
+	stepFrontBrightnessUUID  = "ca.michaelabon.logitech-litra-lights.step.front.brightness"
+	stepFrontTemperatureUUID = "ca.michaelabon.logitech-litra-lights.step.front.temperature"
+	stepBackBrightnessUUID   = "ca.michaelabon.logitech-litra-lights.step.back.brightness"
+)
+
+// How a step key repeats while it's held: after a pause, so a tap takes one
+// step, then steadily until it's let go or the value reaches its limit.
+var (
+	stepRepeatDelay    = 400 * time.Millisecond
+	stepRepeatInterval = 150 * time.Millisecond
+)
+
+// stepControl is what a step key changes. It shares its range, reading and
+// fading with the dial for the same setting.
+type stepControl struct {
+	uuid string
+	dial string // the UUID of the dial for the same setting
+	step int    // until the key is given its own
+}
+
+var stepControls = []stepControl{
+	{uuid: stepFrontBrightnessUUID, dial: dialFrontBrightnessUUID, step: 10},
+	{uuid: stepFrontTemperatureUUID, dial: dialFrontTemperatureUUID, step: 500},
+	{uuid: stepBackBrightnessUUID, dial: dialBackBrightnessUUID, step: 10},
+}
+
+// StepSettings for the step actions
+type StepSettings struct {
+	TargetSettings
+	Direction string `json:"direction"` // "up" (default): brighter or cooler; "down": dimmer or warmer
+	Step      string `json:"step"`      // percent or kelvin per step; empty takes the action's own
+}
+
+// delta returns how far one step of the key moves the value.
+func (s StepSettings) delta(c stepControl) (int, error) {
+	step := c.step
+	if s.Step != "" {
+		var err error
+		if step, err = strconv.Atoi(s.Step); err != nil || step < 1 {
+			return 0, fmt.Errorf("step %q: not a positive number", s.Step)
+		}
+	}
+	if s.Direction == "down" {
+		return -step, nil
+	}
+	return step, nil
+}
+
+// stepSettingsFromEvent reads the settings of a step key from an event carrying them.
+func stepSettingsFromEvent(event streamdeck.Event) (StepSettings, error) {
+	p := streamdeck.KeyDownPayload{}
+	s := StepSettings{}
+	if err := json.Unmarshal(event.Payload, &p); err != nil {
+		return s, err
+	}
+	if len(p.Settings) > 0 {
+		if err := json.Unmarshal(p.Settings, &s); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
+
+// currentValue returns the value of c on the lights of target: what the
+// shared state knows, or else what the light reports.
+func currentValue(c dialControl, target string) (int, error) {
+	state, ok := deviceMgr.KnownState(target, c.which)
+	if v, known := c.read(state); ok && known {
+		return v, nil
+	}
+	state, err := deviceMgr.ReadState(target, c.which)
+	if err != nil {
+		return 0, err
+	}
+	if v, known := c.read(state); known {
+		return v, nil
+	}
+	return c.presets[0], nil
+}
+
+// --- Step actions ---
+func setupStepActions(client *streamdeck.Client) {
+	for _, c := range stepControls {
+		setupStepAction(client, c)
+	}
+}
+
+func setupStepAction(client *streamdeck.Client, s stepControl) {
+	action := client.Action(s.uuid)
+	c := dialControlFor(s.dial)
+	what := "stepping " + c.title
+	held := make(map[string]context.CancelFunc) // stops the repeat of each held key, by context
+
+	action.RegisterHandler(
+		streamdeck.WillAppear,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			return showState(event, c.which, func(state LightState, ok bool) error {
+				if !ok {
+					return nil
+				}
+				return client.SetTitle(ctx, liveTitles[s.uuid].title(state), streamdeck.HardwareAndSoftware)
+			})
+		},
+	)
+
+	release := func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+		if stop, ok := held[event.Context]; ok {
+			stop()
+			delete(held, event.Context)
+		}
+		return nil
+	}
+	action.RegisterHandler(streamdeck.KeyUp, release)
+	action.RegisterHandler(streamdeck.WillDisappear, release)
+
+	action.RegisterHandler(
+		streamdeck.KeyDown,
+		func(ctx context.Context, client *streamdeck.Client, event streamdeck.Event) error {
+			settings, err := stepSettingsFromEvent(event)
+			if err != nil {
+				return err
+			}
+			delta, err := settings.delta(s)
+			if err != nil {
+				log.Printf("Invalid step: %v\n", err)
+				return client.SetTitle(ctx, "Check\nStep", streamdeck.HardwareAndSoftware)
+			}
+			value, err := currentValue(c, settings.Device)
+			if err != nil {
+				return showError(ctx, client, what, err)
+			}
+
+			// step moves the lights on from value, reporting false at the limit.
+			// Its result comes on done, after which the key shows title.
+			step := func() (done <-chan error, title string, ok bool) {
+				next := c.turnBy(value, delta)
+				if next == value {
+					return nil, "", false
+				}
+				value = next
+				log.Printf("%s: %d%s\n", c.title, value, c.unit)
+				fade := Fade{Target: settings.Device, Attribute: c.attribute, Fader: c.fader(value)}
+				takeOver(fade.Target, fade.Attribute)
+				return deviceMgr.SubmitFade(ctx, fade, Transition{}), strconv.Itoa(value) + c.unit, true
+			}
+			// settled shows how a step went, reporting whether the repeat may go on.
+			settled := func(done <-chan error, title string) bool {
+				var err error
+				result := <-done
+				switch {
+				case errors.Is(result, ErrSuperseded):
+					return false // another key or dial took over
+				case result != nil:
+					err = errors.Join(showError(ctx, client, what, result), client.ShowAlert(ctx))
+				default:
+					err = client.SetTitle(ctx, title, streamdeck.HardwareAndSoftware)
+				}
+				if err != nil {
+					log.Printf("Unable to update the key after %s: %v\n", what, err)
+				}
+				return result == nil
+			}
+
+			done, title, ok := step()
+			if !ok {
+				return nil
+			}
+
+			// The key repeats from the value it set, since the lights may not
+			// have reported the last step yet. Each step waits for the one
+			// before, and the first that fails stops the repeat, rather than a
+			// failing step being queued every interval while a light is unplugged.
+			repeat, stop := context.WithCancel(context.Background())
+			if earlier, ok := held[event.Context]; ok {
+				earlier()
+			}
+			held[event.Context] = stop
+			delay, interval := stepRepeatDelay, stepRepeatInterval
+			go func() {
+				timer := time.NewTimer(delay)
+				defer timer.Stop()
+				for settled(done, title) && repeat.Err() == nil {
+					select {
+					case <-repeat.Done():
+						return
+					case <-timer.C:
+					}
+					// Both are ready when the key was let go as the timer
+					// fired, and a let-go key mustn't take another step.
+					if repeat.Err() != nil {
+						return
+					}
+					if done, title, ok = step(); !ok {
+						return
+					}
+					timer.Reset(interval)
+				}
+			}()
+			return nil
+		},
+	)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	logitech "github.com/michaelabon/streamdeck-logitech-litra/internal/logitech_hid"
+	"github.com/samwho/streamdeck"
+)
+
+func TestStepActions(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	m := logitech.LitraBeamLX
+
+	d.send(stepFrontBrightnessUUID, streamdeck.WillAppear, "brighter", nil)
+	if title := d.expectTitle("brighter"); title != "50%" {
+		t.Fatalf("Expected 50%%, got %q", title)
+	}
+	read := len(d.fake.Written(testPath))
+
+	d.send(stepFrontBrightnessUUID, streamdeck.KeyDown, "brighter", nil)
+	d.send(stepFrontBrightnessUUID, streamdeck.KeyUp, "brighter", nil)
+	if title := d.expectTitle("brighter"); title != "60%" {
+		t.Errorf("Expected 60%%, got %q", title)
+	}
+	waitFor(t, func() bool {
+		state, _ := deviceMgr.KnownState(AllLights, logitech.FrontLight)
+		return state.Brightness == 60
+	})
+
+	settings := StepSettings{Direction: "down", Step: "25"}
+	d.send(stepFrontBrightnessUUID, streamdeck.KeyDown, "dimmer", settings)
+	d.send(stepFrontBrightnessUUID, streamdeck.KeyUp, "dimmer", settings)
+	if title := d.expectTitle("dimmer"); title != "35%" {
+		t.Errorf("Expected 35%%, got %q", title)
+	}
+	assertReports(t, d.waitReports(read + 2)[read:],
+		mustBytes(m.Brightness(logitech.FrontLight, 60)),
+		mustBytes(m.Brightness(logitech.FrontLight, 35)),
+	)
+
+	d.send(stepFrontBrightnessUUID, streamdeck.KeyDown, "dimmer", StepSettings{Step: "-5"})
+	if title := d.expectTitle("dimmer"); title != "Check\nStep" {
+		t.Errorf("Expected the key to refuse the step, got %q", title)
+	}
+}
+
+func TestStepActionRepeatsWhileHeld(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	m := logitech.LitraBeamLX
+	delay, interval := stepRepeatDelay, stepRepeatInterval
+	stepRepeatDelay, stepRepeatInterval = 5*time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { stepRepeatDelay, stepRepeatInterval = delay, interval })
+
+	d.send(stepBackBrightnessUUID, streamdeck.WillAppear, t.Name(), nil)
+	if title := d.expectTitle(t.Name()); title != "40%" {
+		t.Fatalf("Expected 40%%, got %q", title)
+	}
+	read := len(d.fake.Written(testPath))
+
+	// Held, the key steps on from what the light reported until it stops at the limit.
+	d.send(stepBackBrightnessUUID, streamdeck.KeyDown, t.Name(), StepSettings{Step: "20"})
+	d.waitTitle(t.Name(), "100%")
+	assertReports(t, d.waitReports(read + 3)[read:],
+		mustBytes(m.Brightness(logitech.BackLight, 60)),
+		mustBytes(m.Brightness(logitech.BackLight, 80)),
+		mustBytes(m.Brightness(logitech.BackLight, 100)),
+	)
+	d.send(stepBackBrightnessUUID, streamdeck.KeyUp, t.Name(), nil)
+
+	time.Sleep(20 * time.Millisecond)
+	if written := d.fake.Written(testPath); len(written) != read+3 {
+		t.Errorf("Expected no steps past the limit, got %d reports", len(written)-read)
+	}
+}
+
+func TestStepActionStopsRepeatingOnError(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	delay, interval := stepRepeatDelay, stepRepeatInterval
+	stepRepeatDelay, stepRepeatInterval = 5*time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { stepRepeatDelay, stepRepeatInterval = delay, interval })
+
+	d.send(stepBackBrightnessUUID, streamdeck.WillAppear, t.Name(), nil)
+	d.expectTitle(t.Name())
+
+	// With the light unplugged, the first step fails and the key alerts
+	// rather than going on queueing steps while held.
+	d.fake.FailWrites(maxRetries+1, errors.New("unplugged"))
+	d.send(stepBackBrightnessUUID, streamdeck.KeyDown, t.Name(), StepSettings{Step: "20"})
+	d.expect(streamdeck.ShowAlert, t.Name())
+	failed := len(d.fake.Written(testPath))
+
+	time.Sleep(20 * time.Millisecond)
+	if written := d.fake.Written(testPath); len(written) != failed {
+		t.Errorf("Expected no steps after the failure, got %d more reports", len(written)-failed)
+	}
+	d.send(stepBackBrightnessUUID, streamdeck.KeyUp, t.Name(), nil)
+}
+
+func TestStepActionTapTakesOneStep(t *testing.T) {
+	d := newTestDeck(t, testLight)
+	m := logitech.LitraBeamLX
+	delay, interval := stepRepeatDelay, stepRepeatInterval
+	stepRepeatDelay, stepRepeatInterval = 5*time.Millisecond, 5*time.Millisecond
+	t.Cleanup(func() { stepRepeatDelay, stepRepeatInterval = delay, interval })
+
+	d.send(stepBackBrightnessUUID, streamdeck.WillAppear, t.Name(), nil)
+	d.expectTitle(t.Name())
+	read := len(d.fake.Written(testPath))
+
+	// The step is retried past the repeat delay, by when the key is let go.
+	deviceMgr.retryDelay = 20 * time.Millisecond
+	d.fake.FailWrites(1, errors.New("stalled"))
+	d.send(stepBackBrightnessUUID, streamdeck.KeyDown, t.Name(), StepSettings{Step: "20"})
+	d.send(stepBackBrightnessUUID, streamdeck.KeyUp, t.Name(), nil)
+	d.waitTitle(t.Name(), "60%")
+
+	time.Sleep(20 * time.Millisecond)
+	assertReports(t, d.fake.Written(testPath)[read:], mustBytes(m.Brightness(logitech.BackLight, 60)))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	// Windows has no time zone database of its own.
+	_ "time/tzdata"
+)
+
+// daylight says whether the sun rises and sets on a day, or stays up or down
+// all day, as it does near the poles.
+type daylight int
+
+const (
+	sunRisesAndSets daylight = iota
+	midnightSun
+	polarNight
+)
+
+// sunTimes returns when the sun rises and sets on date's day at a latitude
+// and longitude in degrees (north and east positive), using NOAA's general
+// solar position equations. They are accurate to a few minutes, which is
+// plenty for warming a light.
+func sunTimes(date time.Time, latitude, longitude float64) (rise, set time.Time, d daylight) {
+	y, m, day := date.Date()
+	midnight := time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+
+	// The fractional year, in radians
+	g := 2 * math.Pi / 365 * float64(date.YearDay()-1)
+	// The equation of time, in minutes, and the sun's declination
+	eqTime := 229.18 * (0.000075 + 0.001868*math.Cos(g) - 0.032077*math.Sin(g) -
+		0.014615*math.Cos(2*g) - 0.040849*math.Sin(2*g))
+	decl := 0.006918 - 0.399912*math.Cos(g) + 0.070257*math.Sin(g) -
+		0.006758*math.Cos(2*g) + 0.000907*math.Sin(2*g) -
+		0.002697*math.Cos(3*g) + 0.00148*math.Sin(3*g)
+
+	// The hour angle of sunrise, with the sun's centre 0.833° below the
+	// horizon to allow for refraction and the sun's size.
+	lat := latitude * math.Pi / 180
+	cosHA := math.Cos(90.833*math.Pi/180)/(math.Cos(lat)*math.Cos(decl)) - math.Tan(lat)*math.Tan(decl)
+	switch {
+	case cosHA > 1:
+		return time.Time{}, time.Time{}, polarNight
+	case cosHA < -1:
+		return time.Time{}, time.Time{}, midnightSun
+	}
+	ha := math.Acos(cosHA) * 180 / math.Pi
+
+	minutes := func(m float64) time.Time {
+		return midnight.Add(time.Duration(m * float64(time.Minute)))
+	}
+	return minutes(720 - 4*(longitude+ha) - eqTime), minutes(720 - 4*(longitude-ha) - eqTime), sunRisesAndSets
+}
+
+// sunRamp is how long the front light takes to go from warm to cool after
+// sunrise, and back before sunset.
+const sunRamp = time.Hour
+
+// Schedule gives the front light's temperature at a moment.
+type Schedule interface {
+	Temperature(now time.Time) uint16
+}
+
+// Circadian follows the sun at a place: the front light is Warm at night and
+// Cool during the day, easing between the two over the hour after sunrise and
+// the hour before sunset.
+type Circadian struct {
+	Latitude, Longitude float64
+	Location            *time.Location // whose calendar day the sun is followed on
+	Warm, Cool          uint16         // Kelvin
+}
+
+func (c Circadian) Temperature(now time.Time) uint16 {
+	if c.Location != nil {
+		now = now.In(c.Location)
+	}
+	rise, set, d := sunTimes(now, c.Latitude, c.Longitude)
+
+	var day float64 // how far into the day, from 0 at night to 1 at full daylight
+	switch d {
+	case midnightSun:
+		day = 1
+	case sunRisesAndSets:
+		day = min(now.Sub(rise).Hours()/sunRamp.Hours(), set.Sub(now).Hours()/sunRamp.Hours())
+		day = easings["ease-in-out"](min(max(day, 0), 1))
+	}
+
+	switch {
+	case day <= 0:
+		return c.Warm
+	case day >= 1:
+		return c.Cool
+	}
+	// In 100K steps, so the light isn't written every minute for a change
+	// no one can see.
+	kelvin := between(float64(c.Warm), float64(c.Cool), day)
+	return uint16(math.Round(kelvin/100) * 100)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSunTimes(t *testing.T) {
+	tests := []struct {
+		place     string
+		lat, lon  float64
+		date      string
+		rise, set string // UTC, from published almanacs
+	}{
+		{"Ottawa", 45.4215, -75.6972, "2024-06-21", "2024-06-21T09:15:00Z", "2024-06-22T00:51:00Z"},
+		{"London", 51.5074, -0.1278, "2024-12-21", "2024-12-21T08:04:00Z", "2024-12-21T15:53:00Z"},
+		// East of Greenwich the sun rises the UTC day before.
+		{"Tokyo", 35.6762, 139.6503, "2024-03-20", "2024-03-19T20:45:00Z", "2024-03-20T08:54:00Z"},
+	}
+	for _, tt := range tests {
+		date, _ := time.Parse(time.DateOnly, tt.date)
+		rise, set, d := sunTimes(date, tt.lat, tt.lon)
+		if d != sunRisesAndSets {
+			t.Errorf("%s: expected the sun to rise and set, got %v", tt.place, d)
+			continue
+		}
+		for _, got := range []struct {
+			name string
+			at   time.Time
+			want string
+		}{{"sunrise", rise, tt.rise}, {"sunset", set, tt.set}} {
+			want, _ := time.Parse(time.RFC3339, got.want)
+			if diff := got.at.Sub(want).Abs(); diff > 5*time.Minute {
+				t.Errorf("%s: expected %s at %s, got %s", tt.place, got.name, want, got.at)
+			}
+		}
+	}
+}
+
+func TestSunTimesNearThePoles(t *testing.T) {
+	const lat, lon = 69.6492, 18.9553 // Tromsø
+	if _, _, d := sunTimes(time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC), lat, lon); d != polarNight {
+		t.Errorf("Expected polar night in December, got %v", d)
+	}
+	if _, _, d := sunTimes(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), lat, lon); d != midnightSun {
+		t.Errorf("Expected the midnight sun in June, got %v", d)
+	}
+}
+
+func TestCircadianTemperature(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Circadian{Latitude: 43.65, Longitude: -79.38, Location: toronto, Warm: 2700, Cool: 6500}
+	at := func(clock string) time.Time {
+		when, err := time.ParseInLocation(time.DateTime, "2024-06-21 "+clock, toronto)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return when
+	}
+	// The sun rises at about 5:36 and sets at about 21:03.
+	rise, set, _ := sunTimes(at("12:00:00"), c.Latitude, c.Longitude)
+
+	for _, tt := range []struct {
+		name string
+		at   time.Time
+		want uint16
+	}{
+		{"night", at("02:00:00"), 2700},
+		{"sunrise", rise, 2700},
+		{"half an hour after sunrise", rise.Add(sunRamp / 2), 4600},
+		{"noon", at("12:00:00"), 6500},
+		{"half an hour before sunset", set.Add(-sunRamp / 2), 4600},
+		{"evening", at("23:00:00"), 2700},
+	} {
+		if got := c.Temperature(tt.at); got != tt.want {
+			t.Errorf("%s: expected %dK, got %dK", tt.name, tt.want, got)
+		}
+	}
+
+	// Midnight sun and polar night stay cool and warm all day.
+	polar := Circadian{Latitude: 69.65, Longitude: 18.96, Location: time.UTC, Warm: 3000, Cool: 5000}
+	if got := polar.Temperature(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC)); got != 5000 {
+		t.Errorf("Expected the midnight sun cool, got %dK", got)
+	}
+	if got := polar.Temperature(time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC)); got != 3000 {
+		t.Errorf("Expected polar night warm, got %dK", got)
+	}
+}
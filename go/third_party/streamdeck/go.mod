@@ -0,0 +1,5 @@
+module github.com/samwho/streamdeck
+
+go 1.24.6
+
+require github.com/gorilla/websocket v1.5.3